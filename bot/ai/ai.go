@@ -20,6 +20,7 @@
 package ai
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -30,16 +31,18 @@ import (
 type AIProvider string
 
 const (
-	ProviderOpenAI   AIProvider = "OpenAI"
-	ProviderDeepSeek AIProvider = "DeepSeek"
+	ProviderOpenAI           AIProvider = "OpenAI"
+	ProviderDeepSeek         AIProvider = "DeepSeek"
+	ProviderOpenAICompatible AIProvider = "OpenAICompatible"
 )
 
 type AI interface {
-	ExtractCalendarEvents(messages *[]model.TextMessage) (*AiResponse[[]model.Event], model.Error)
+	ExtractCalendarEvents(messages *[]model.TextMessage, location *time.Location) (*AiResponse[[]model.Event], model.Error)
+	EditCalendarEvent(event *model.Event, instruction string, location *time.Location) (*AiResponse[model.Event], model.Error)
 	Provider() AIProvider
 }
 
-func extractCalendarEventsPrompt() string {
+func extractCalendarEventsPrompt(location *time.Location) string {
 	return fmt.Sprintf(`
 		You are an AI assistant that extracts structured event details from user input 
 		(such as announcements, tickets, advertisements, or related content) and converts 
@@ -55,18 +58,48 @@ func extractCalendarEventsPrompt() string {
 		• End Date/Time (required if available; otherwise set a default).
 		• Location (if provided).
 		• Event Type – Must be one of: "event", "reminder", "meeting", "birthday", "holiday", "other".
-
-		2. Resolve Relative Dates
-		Use the provided reference date (e.g., "Today is %s") to convert relative expressions 
-		like “tomorrow” or “next Friday” into absolute dates.
-
-		3. Handle Incomplete Data
+		• All Day – Whether the event takes whole days rather than a time slot.
+		• Recurrence (if the event repeats).
+		• Time Zone (if the input names one explicitly).
+
+		2. Handle Recurring Events
+		• Return a repeating event (e.g., "every Tuesday at 7pm until June") as a single event 
+			whose start and end are those of the first occurrence.
+		• Set the recurrence to an RFC 5545 RRULE without the "RRULE:" prefix, 
+			e.g. "FREQ=WEEKLY;BYDAY=TU;UNTIL=20250630".
+		• Use only FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, COUNT, UNTIL, BYDAY, 
+			BYMONTHDAY, BYMONTH, BYSETPOS and WKST. Never use COUNT and UNTIL together.
+		• List skipped occurrences (e.g., "except April 15") as exception dates, 
+			using the start date/time of each skipped occurrence.
+		• For single events, the recurrence must be an empty string and the exception dates an empty array.
+
+		3. Handle All-Day and Multi-Day Events
+		• Birthdays, holidays and events given only by date (e.g., festivals, vacations, 
+			conferences without a schedule) are all-day events.
+		• For all-day events, set all day to true and give the start and end as dates 
+			in the format "YYYY-MM-DD". The end is the last day of the event, 
+			so a one-day event has the same start and end date.
+		• An event spanning several dates (e.g., a festival from May 1 to May 3) is a single 
+			all-day event from its first to its last day, not one event per day.
+
+		4. Resolve Relative Dates and Time Zones
+		Today is %s in the user's time zone %s.
+		• Every message comes with its own reference date: the date it was originally posted. 
+			Convert relative expressions like “tomorrow” or “next Friday” into absolute dates 
+			against the reference date of the message they appear in, not against today. 
+			A forwarded post from last week that says “tomorrow” means the day after it was posted.
+		• Give times as the wall-clock time stated in the input, without converting them.
+		• If the input explicitly names a time zone (e.g., "19:00 CET", "3pm EST", "Moscow time"), 
+			set the time zone to its IANA name (e.g., "Europe/Paris", "America/New_York", "Europe/Moscow").
+		• Otherwise, the time zone must be an empty string and the user's time zone is assumed.
+
+		5. Handle Incomplete Data
 		• At a minimum, extract the title, start time, and end time.
 		• If the end time is missing:
 			– Use a known default duration for the event type,
 			– Otherwise, assume a one-hour duration.
 
-		4. Fallback Handling
+		6. Fallback Handling
 		• If no event details are found, return an empty JSON array.
 		• Provide a brief explanation of the result.
 
@@ -76,15 +109,71 @@ func extractCalendarEventsPrompt() string {
 		Parse all text to identify event-related information.
 
 		Output Format
-		• Dates/times must follow the format: "YYYY-MM-DD HH:MM:SS".
+		• Dates/times must follow the format: "YYYY-MM-DD HH:MM:SS", dates of all-day events "YYYY-MM-DD".
 		• Prices must be numeric or "free".
 		• Links must be valid URLs.
 		• The output must include a brief explanation of the result.
 	`,
-		time.Now().Format(time.DateTime),
+		referenceDate(location),
+		location.String(),
 	)
 }
 
+func editCalendarEventPrompt(event *model.Event, location *time.Location) (string, error) {
+	current, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`
+		You are an AI assistant that edits an existing calendar event 
+		according to the user's instruction (e.g. "move it to 19:30", "make it two hours", 
+		"the location is the city park").
+
+		Current event (JSON):
+		%s
+
+		Tasks
+
+		1. Apply Only the Requested Changes
+		• Change only the fields the instruction refers to.
+		• Keep every other field exactly as it is in the current event.
+
+		2. Keep the Event Consistent
+		• If only the start time changes, keep the original duration.
+		• If only the duration or end time changes, keep the original start.
+		• The end must not be before the start.
+		• All-day events have dates in the format "YYYY-MM-DD" and their end is the last day 
+			of the event. Set all day to false when the instruction gives the event a time.
+		• The recurrence is an RFC 5545 RRULE without the "RRULE:" prefix, 
+			exception dates are the starts of skipped occurrences. Keep them unless asked to change.
+
+		3. Resolve Relative Dates and Time Zones
+		Today is %s in the user's time zone %s. Use the current event's dates and this 
+		reference date to convert relative expressions like “a day later” or “next Friday” into absolute dates.
+		• Times are wall-clock times in the event's time zone (an IANA name), 
+			or in the user's time zone when it is empty.
+		• Set the time zone only if the instruction names one explicitly, e.g. "it's 19:00 CET".
+
+		Output Format
+		• Return the complete modified event, not only the changed fields.
+		• Dates/times must follow the format: "YYYY-MM-DD HH:MM:SS", dates of all-day events "YYYY-MM-DD".
+		• The output must include a brief explanation of the changes.
+	`,
+		string(current),
+		referenceDate(location),
+		location.String(),
+	), nil
+}
+
+// referenceDate formats the current time in the user's time zone,
+// including the weekday so that the model can resolve “next Friday”.
+func referenceDate(location *time.Location) string {
+	return time.Now().In(location).Format(referenceDateFormat)
+}
+
+const referenceDateFormat = "Monday, " + time.DateTime
+
 func removeJsonFormattingMarkers(text string) string {
 	// Remove formatting markers (```json and trailing backticks)
 	text = strings.TrimPrefix(text, "```json")
@@ -93,16 +182,26 @@ func removeJsonFormattingMarkers(text string) string {
 }
 
 // messagesToText converts an array of TextMessages into a single string.
-// It uses formatMessageText to include entity markers correctly.
-func messagesToText(messages *[]model.TextMessage) string {
+// Each message carries its reference date in the user's time zone,
+// so that relative dates in forwarded posts resolve against the original post.
+func messagesToText(messages *[]model.TextMessage, location *time.Location) string {
 	var sb strings.Builder
 
 	for _, msg := range *messages {
+		reference := referenceDate(location)
+		if !msg.Date.IsZero() {
+			reference = msg.Date.In(location).Format(referenceDateFormat)
+		}
+
 		switch msg.MessageType {
 		case model.UserMessage:
-			sb.WriteString(fmt.Sprintf("The user's message: %s\n", msg.Text))
+			sb.WriteString(fmt.Sprintf("The user's message (reference date: %s): %s\n", reference, msg.Text))
 		case model.ForwardedMessage:
-			sb.WriteString(fmt.Sprintf("Forwarded from %s: %s\n", msg.From, msg.Text))
+			from := msg.ForwardedFrom
+			if from == "" {
+				from = "an unknown source"
+			}
+			sb.WriteString(fmt.Sprintf("Forwarded from %s (reference date: %s): %s\n", from, reference, msg.Text))
 		}
 
 		sb.WriteString("\n")
@@ -123,4 +222,10 @@ type EventSchema struct {
 	End         string `json:"end"`
 	Location    string `json:"location"`
 	EventType   string `json:"eventType"`
+	AllDay      bool   `json:"allDay"`
+	// TimeZone is the IANA name of the time zone named in the message, if any.
+	TimeZone   string `json:"timeZone"`
+	Recurrence string `json:"recurrence"`
+	// ExceptionDates are the starts of skipped occurrences of a recurring event.
+	ExceptionDates []string `json:"exceptionDates"`
 }
@@ -22,6 +22,7 @@ package ai
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/ivgag/schedulr/model"
 	"github.com/rs/zerolog/log"
@@ -49,21 +50,58 @@ func (d *DeepSeekAI) Provider() AIProvider {
 	return ProviderDeepSeek
 }
 
-func (d *DeepSeekAI) ExtractCalendarEvents(messages *[]model.TextMessage) (*AiResponse[[]model.Event], model.Error) {
+func (d *DeepSeekAI) ExtractCalendarEvents(messages *[]model.TextMessage, location *time.Location) (*AiResponse[[]model.Event], model.Error) {
 	var response AiResponse[[]model.Event]
 	var schema AiResponse[[]EventSchema]
+
+	err := d.createChatCompletion(extractCalendarEventsPrompt(location), messagesToText(messages, location), schema, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+func (d *DeepSeekAI) EditCalendarEvent(event *model.Event, instruction string, location *time.Location) (*AiResponse[model.Event], model.Error) {
+	var response AiResponse[model.Event]
+	var schema AiResponse[EventSchema]
+
+	prompt, err := editCalendarEventPrompt(event, location)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.createChatCompletion(prompt, instruction, schema, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// createChatCompletion sends the prompt and the user's content to the model
+// and unmarshals the JSON reply into response.
+func (d *DeepSeekAI) createChatCompletion(
+	prompt string,
+	content string,
+	schema any,
+	response any,
+) error {
 	responseSchema, err := jsonschema.GenerateSchemaForType(schema)
+	if err != nil {
+		return err
+	}
 	jsonSchema, err := responseSchema.MarshalJSON()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	request := &deepseek.ChatCompletionRequest{
 		Model: d.config.Model,
 		Messages: []deepseek.ChatCompletionMessage{
-			{Role: constants.ChatMessageRoleSystem, Content: extractCalendarEventsPrompt()},
+			{Role: constants.ChatMessageRoleSystem, Content: prompt},
 			{Role: constants.ChatMessageRoleSystem, Content: "Response JSON Format: " + string(jsonSchema)},
-			{Role: constants.ChatMessageRoleUser, Content: messagesToText(messages)},
+			{Role: constants.ChatMessageRoleUser, Content: content},
 		},
 		ResponseFormat: &deepseek.ResponseFormat{
 			Type: "json_object",
@@ -73,39 +111,27 @@ func (d *DeepSeekAI) ExtractCalendarEvents(messages *[]model.TextMessage) (*AiRe
 
 	ctx := context.Background()
 	rawResponse, err := d.client.CreateChatCompletion(ctx, request)
-	if err != nil {
-		return nil, err
-	}
 
 	e := &deepseek.APIError{}
 	if errors.As(err, &e) {
-		switch e.StatusCode {
-		case 500, 503:
-			return nil, ApiError{
-				Message:      e.Message,
-				ResponseCode: e.StatusCode,
-				Retryable:    true,
-			}
-		default:
-			return nil, ApiError{
-				Message:      e.Message,
-				ResponseCode: e.StatusCode,
-				Retryable:    false,
-			}
-		}
-	} else {
-		responseContent := rawResponse.Choices[0].Message.Content
-
-		err = responseSchema.Unmarshal(responseContent, &response)
-		if err != nil {
-			log.Error().
-				Interface("messages", messages).
-				Str("responseContent", responseContent).
-				Err(err).Msg("Failed to unmarshal OpenAI response")
-		}
-
-		return &response, err
+		return newApiError(e.Message, e.StatusCode)
+	} else if err != nil {
+		return err
+	} else if len(rawResponse.Choices) == 0 {
+		return ApiError{Message: "empty response from DeepSeek", Retryable: true}
 	}
+
+	responseContent := rawResponse.Choices[0].Message.Content
+
+	err = responseSchema.Unmarshal(responseContent, response)
+	if err != nil {
+		log.Error().
+			Str("content", content).
+			Str("responseContent", responseContent).
+			Err(err).Msg("Failed to unmarshal DeepSeek response")
+	}
+
+	return err
 }
 
 type DeepseekConfig struct {
@@ -28,3 +28,22 @@ type ApiError struct {
 func (e ApiError) Error() string {
 	return e.Message
 }
+
+// newApiError creates an ApiError for an HTTP response code,
+// marking server-side failures as retryable.
+func newApiError(message string, responseCode int) ApiError {
+	switch responseCode {
+	case 500, 503:
+		return ApiError{
+			Message:      message,
+			ResponseCode: responseCode,
+			Retryable:    true,
+		}
+	default:
+		return ApiError{
+			Message:      message,
+			ResponseCode: responseCode,
+			Retryable:    false,
+		}
+	}
+}
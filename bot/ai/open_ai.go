@@ -22,6 +22,8 @@ package ai
 import (
 	"context"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/ivgag/schedulr/model"
 	"github.com/rs/zerolog/log"
@@ -46,65 +48,143 @@ func (o *OpenAI) Provider() AIProvider {
 	return ProviderOpenAI
 }
 
-func (o *OpenAI) ExtractCalendarEvents(messages *[]model.TextMessage) (*AiResponse[[]model.Event], model.Error) {
+func (o *OpenAI) ExtractCalendarEvents(messages *[]model.TextMessage, location *time.Location) (*AiResponse[[]model.Event], model.Error) {
 	var response AiResponse[[]model.Event]
 	var schema AiResponse[[]EventSchema]
+
+	err := o.createChatCompletion(
+		extractCalendarEventsPrompt(location),
+		messagesToText(messages, location),
+		"extracted_events",
+		schema,
+		&response,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+func (o *OpenAI) EditCalendarEvent(event *model.Event, instruction string, location *time.Location) (*AiResponse[model.Event], model.Error) {
+	var response AiResponse[model.Event]
+	var schema AiResponse[EventSchema]
+
+	prompt, err := editCalendarEventPrompt(event, location)
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.createChatCompletion(prompt, instruction, "edited_event", schema, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// createChatCompletion sends the prompt and the user's content to the model
+// and unmarshals the structured reply into response.
+func (o *OpenAI) createChatCompletion(
+	prompt string,
+	content string,
+	schemaName string,
+	schema any,
+	response any,
+) error {
+	return createOpenAIChatCompletion(o.client, o.config.Model, true, prompt, content, schemaName, schema, response)
+}
+
+// createOpenAIChatCompletion sends the prompt and the user's content to a model
+// served through the OpenAI chat completions API and unmarshals the structured
+// reply into response. Servers that do not support JSON-schema mode get the
+// schema in the prompt and are asked for a plain JSON object instead.
+func createOpenAIChatCompletion(
+	client *openai.Client,
+	model string,
+	jsonSchemaMode bool,
+	prompt string,
+	content string,
+	schemaName string,
+	schema any,
+	response any,
+) error {
 	responseSchema, err := jsonschema.GenerateSchemaForType(schema)
+	if err != nil {
+		return err
+	}
+
+	messages := []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: prompt,
+		},
+	}
 
-	resp, err := o.client.CreateChatCompletion(
+	var responseFormat *openai.ChatCompletionResponseFormat
+	if jsonSchemaMode {
+		responseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   schemaName,
+				Schema: responseSchema,
+				Strict: true,
+			},
+		}
+	} else {
+		jsonSchema, err := responseSchema.MarshalJSON()
+		if err != nil {
+			return err
+		}
+
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: "Response JSON Format: " + string(jsonSchema),
+		})
+		responseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		}
+	}
+
+	messages = append(messages, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: content,
+	})
+
+	resp, err := client.CreateChatCompletion(
 		context.Background(),
 		openai.ChatCompletionRequest{
-			Model: o.config.Model,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: extractCalendarEventsPrompt(),
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: messagesToText(messages),
-				},
-			},
-			ResponseFormat: &openai.ChatCompletionResponseFormat{
-				Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
-				JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
-					Name:   "extracted_events",
-					Schema: responseSchema,
-					Strict: true,
-				},
-			},
+			Model:          model,
+			Messages:       messages,
+			ResponseFormat: responseFormat,
 		},
 	)
 
 	e := &openai.APIError{}
+	requestErr := &openai.RequestError{}
 	if errors.As(err, &e) {
-		switch e.HTTPStatusCode {
-		case 500, 503:
-			return nil, ApiError{
-				Message:      e.Message,
-				ResponseCode: e.HTTPStatusCode,
-				Retryable:    true,
-			}
-		default:
-			return nil, ApiError{
-				Message:      e.Message,
-				ResponseCode: e.HTTPStatusCode,
-				Retryable:    false,
-			}
-		}
-	} else {
-		responseContent := resp.Choices[0].Message.Content
+		return newApiError(e.Message, e.HTTPStatusCode)
+	} else if errors.As(err, &requestErr) {
+		// The error page of a proxy in front of the server, e.g. 502 Bad Gateway.
+		return newApiError(requestErr.Error(), requestErr.HTTPStatusCode)
+	} else if err != nil {
+		return err
+	} else if len(resp.Choices) == 0 {
+		return ApiError{Message: "empty response from the model", Retryable: true}
+	}
 
-		err = responseSchema.Unmarshal(responseContent, &response)
-		if err != nil {
-			log.Error().
-				Interface("messages", messages).
-				Str("responseContent", responseContent).
-				Err(err).Msg("Failed to unmarshal OpenAI response")
-		}
+	responseContent := removeJsonFormattingMarkers(strings.TrimSpace(resp.Choices[0].Message.Content))
 
-		return &response, err
+	err = responseSchema.Unmarshal(responseContent, response)
+	if err != nil {
+		log.Error().
+			Str("model", model).
+			Str("content", content).
+			Str("responseContent", responseContent).
+			Err(err).Msg("Failed to unmarshal chat completion response")
 	}
+
+	return err
 }
 
 type OpenAIConfig struct {
@@ -0,0 +1,113 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ai
+
+import (
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// NewOpenAICompatible creates a provider for any server that implements the
+// OpenAI chat completions API, e.g. Ollama, vLLM or LM Studio.
+func NewOpenAICompatible(config *OpenAICompatibleConfig) *OpenAICompatible {
+	clientConfig := openai.DefaultConfig(config.APIKey)
+	clientConfig.BaseURL = config.BaseURL
+
+	return &OpenAICompatible{
+		config: config,
+		client: openai.NewClientWithConfig(clientConfig),
+	}
+}
+
+type OpenAICompatible struct {
+	config *OpenAICompatibleConfig
+	client *openai.Client
+}
+
+func (o *OpenAICompatible) Provider() AIProvider {
+	return ProviderOpenAICompatible
+}
+
+func (o *OpenAICompatible) ExtractCalendarEvents(messages *[]model.TextMessage, location *time.Location) (*AiResponse[[]model.Event], model.Error) {
+	var response AiResponse[[]model.Event]
+	var schema AiResponse[[]EventSchema]
+
+	err := o.createChatCompletion(
+		extractCalendarEventsPrompt(location),
+		messagesToText(messages, location),
+		"extracted_events",
+		schema,
+		&response,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+func (o *OpenAICompatible) EditCalendarEvent(event *model.Event, instruction string, location *time.Location) (*AiResponse[model.Event], model.Error) {
+	var response AiResponse[model.Event]
+	var schema AiResponse[EventSchema]
+
+	prompt, err := editCalendarEventPrompt(event, location)
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.createChatCompletion(prompt, instruction, "edited_event", schema, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+func (o *OpenAICompatible) createChatCompletion(
+	prompt string,
+	content string,
+	schemaName string,
+	schema any,
+	response any,
+) error {
+	return createOpenAIChatCompletion(
+		o.client,
+		o.config.Model,
+		o.config.JSONSchema,
+		prompt,
+		content,
+		schemaName,
+		schema,
+		response,
+	)
+}
+
+type OpenAICompatibleConfig struct {
+	// BaseURL is the API root including the version, e.g. http://localhost:11434/v1.
+	BaseURL string `mapstructure:"base_url"`
+	// APIKey is optional; no Authorization header is sent when it is empty.
+	APIKey string `mapstructure:"api_key"`
+	Model  string `mapstructure:"model"`
+	// JSONSchema tells whether the server supports the json_schema response format.
+	// Otherwise the schema is sent in the prompt and json_object mode is used.
+	JSONSchema bool `mapstructure:"json_schema"`
+}
@@ -0,0 +1,223 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ai_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivgag/schedulr/ai"
+	"github.com/ivgag/schedulr/model"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const extractedEventsReply = `{"explanation":"","result":[{"title":"Standup","description":"",` +
+	`"start":"2025-02-18 10:00:00","end":"2025-02-18 10:15:00","location":"","eventType":"work","allDay":false,"timeZone":"",` +
+	`"recurrence":"","exceptionDates":[]}]}`
+
+func TestOpenAICompatible_ExtractCalendarEvents(t *testing.T) {
+	tests := []struct {
+		name               string
+		config             ai.OpenAICompatibleConfig
+		reply              string
+		wantResponseFormat openai.ChatCompletionResponseFormatType
+		wantAuthorization  string
+		wantSchemaInPrompt bool
+	}{
+		{
+			name:               "json schema mode",
+			config:             ai.OpenAICompatibleConfig{Model: "llama3", APIKey: "secret", JSONSchema: true},
+			reply:              extractedEventsReply,
+			wantResponseFormat: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			wantAuthorization:  "Bearer secret",
+		},
+		{
+			name:               "json object mode without api key",
+			config:             ai.OpenAICompatibleConfig{Model: "llama3"},
+			reply:              "```json\n" + extractedEventsReply + "\n```",
+			wantResponseFormat: openai.ChatCompletionResponseFormatTypeJSONObject,
+			wantSchemaInPrompt: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request chatCompletionRequest
+			var authorization string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/chat/completions" {
+					http.NotFound(w, r)
+					return
+				}
+
+				authorization = r.Header.Get("Authorization")
+				if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+					t.Errorf("failed to decode request: %v", err)
+				}
+
+				writeChatCompletion(t, w, tt.reply)
+			}))
+			defer server.Close()
+
+			config := tt.config
+			config.BaseURL = server.URL + "/v1"
+			provider := ai.NewOpenAICompatible(&config)
+
+			messages := []model.TextMessage{{MessageID: 1, Text: "Standup tomorrow at 10"}}
+			response, err := provider.ExtractCalendarEvents(&messages, time.UTC)
+			if err != nil {
+				t.Fatalf("ExtractCalendarEvents() error = %v", err)
+			}
+
+			if request.Model != tt.config.Model {
+				t.Errorf("model = %q, want %q", request.Model, tt.config.Model)
+			}
+			if authorization != tt.wantAuthorization {
+				t.Errorf("Authorization = %q, want %q", authorization, tt.wantAuthorization)
+			}
+			if request.ResponseFormat == nil || request.ResponseFormat.Type != tt.wantResponseFormat {
+				t.Errorf("response format = %+v, want %q", request.ResponseFormat, tt.wantResponseFormat)
+			}
+
+			schemaInPrompt := false
+			for _, message := range request.Messages {
+				if message.Role == openai.ChatMessageRoleSystem && strings.HasPrefix(message.Content, "Response JSON Format:") {
+					schemaInPrompt = true
+				}
+			}
+			if schemaInPrompt != tt.wantSchemaInPrompt {
+				t.Errorf("schema in prompt = %v, want %v", schemaInPrompt, tt.wantSchemaInPrompt)
+			}
+
+			if len(response.Result) != 1 || response.Result[0].Title != "Standup" {
+				t.Errorf("unexpected response: %+v", response)
+			}
+		})
+	}
+}
+
+func TestOpenAICompatible_SendsReferenceDatePerMessage(t *testing.T) {
+	var request chatCompletionRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		writeChatCompletion(t, w, extractedEventsReply)
+	}))
+	defer server.Close()
+
+	provider := ai.NewOpenAICompatible(&ai.OpenAICompatibleConfig{BaseURL: server.URL + "/v1", Model: "llama3"})
+
+	location, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := []model.TextMessage{{
+		MessageID:     1,
+		Text:          "Concert tomorrow at 19:00",
+		MessageType:   model.ForwardedMessage,
+		ForwardedFrom: "City Hall",
+		Date:          time.Date(2025, 2, 10, 23, 30, 0, 0, time.UTC),
+	}}
+	if _, err := provider.ExtractCalendarEvents(&messages, location); err != nil {
+		t.Fatalf("ExtractCalendarEvents() error = %v", err)
+	}
+
+	want := "Forwarded from City Hall (reference date: Tuesday, 2025-02-11 00:30:00): Concert tomorrow at 19:00"
+	user := request.Messages[len(request.Messages)-1]
+	if !strings.Contains(user.Content, want) {
+		t.Errorf("user message = %q, want it to contain %q", user.Content, want)
+	}
+}
+
+func TestOpenAICompatible_ServerErrorIsRetryable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"error":{"message":"model is loading","type":"server_error"}}`))
+	}))
+	defer server.Close()
+
+	provider := ai.NewOpenAICompatible(&ai.OpenAICompatibleConfig{BaseURL: server.URL + "/v1", Model: "llama3"})
+
+	messages := []model.TextMessage{{MessageID: 1, Text: "Standup tomorrow at 10"}}
+	_, err := provider.ExtractCalendarEvents(&messages, time.UTC)
+
+	var apiErr ai.ApiError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error = %v, want ai.ApiError", err)
+	}
+	if !apiErr.Retryable {
+		t.Errorf("error %v should be retryable", apiErr)
+	}
+}
+
+func TestOpenAICompatible_ProxyErrorKeepsStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte("<html><body>502 Bad Gateway</body></html>"))
+	}))
+	defer server.Close()
+
+	provider := ai.NewOpenAICompatible(&ai.OpenAICompatibleConfig{BaseURL: server.URL + "/v1", Model: "llama3"})
+
+	messages := []model.TextMessage{{MessageID: 1, Text: "Standup tomorrow at 10"}}
+	_, err := provider.ExtractCalendarEvents(&messages, time.UTC)
+
+	var apiErr ai.ApiError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error = %v, want ai.ApiError", err)
+	}
+	if apiErr.ResponseCode != http.StatusBadGateway {
+		t.Errorf("ResponseCode = %d, want %d", apiErr.ResponseCode, http.StatusBadGateway)
+	}
+}
+
+// chatCompletionRequest is the part of the request the tests inspect.
+// openai.ChatCompletionRequest cannot be decoded because of its schema field.
+type chatCompletionRequest struct {
+	Model          string                         `json:"model"`
+	Messages       []openai.ChatCompletionMessage `json:"messages"`
+	ResponseFormat *struct {
+		Type openai.ChatCompletionResponseFormatType `json:"type"`
+	} `json:"response_format"`
+}
+
+func writeChatCompletion(t *testing.T, w http.ResponseWriter, content string) {
+	t.Helper()
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{{
+			Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content},
+		}},
+	})
+	if err != nil {
+		t.Errorf("failed to write response: %v", err)
+	}
+}
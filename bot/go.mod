@@ -5,6 +5,7 @@ go 1.23.3
 replace (
 	github.com/ivgag/schedulr/ai => ./ai
 	github.com/ivgag/schedulr/google => ./google
+	github.com/ivgag/schedulr/ical => ./ical
 	github.com/ivgag/schedulr/model => ./model
 	github.com/ivgag/schedulr/rest => ./rest
 	github.com/ivgag/schedulr/service => ./service
@@ -87,6 +88,7 @@ require github.com/rs/zerolog v1.33.0
 require (
 	github.com/fsnotify/fsnotify v1.7.0 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
+	github.com/ivgag/schedulr/ical v0.0.0 // indirect
 	github.com/magiconair/properties v1.8.7 // indirect
 	github.com/mitchellh/mapstructure v1.5.0 // indirect
 	github.com/sagikazarmark/locafero v0.4.0 // indirect
@@ -96,6 +98,7 @@ require (
 	github.com/spf13/cast v1.6.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/subosito/gotenv v1.6.0 // indirect
+	github.com/zsefvlol/timezonemapper v1.0.0 // indirect
 	go.uber.org/atomic v1.9.0 // indirect
 	go.uber.org/multierr v1.9.0 // indirect
 	golang.org/x/exp v0.0.0-20230905200255-921286631fa9 // indirect
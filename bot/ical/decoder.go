@@ -0,0 +1,397 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNoCalendar is returned for data that is not an iCalendar object.
+var ErrNoCalendar = errors.New("no VCALENDAR found")
+
+// Decode reads the events of an iCalendar object. Times are returned as wall-clock
+// times like the ones Encode takes: zoned times keep their TZID as TimeZone, UTC
+// times get the "UTC" zone and floating times none. Times in zones that are not
+// IANA names are converted to UTC with the offsets of their VTIMEZONE, or read as
+// floating times without one. Cancelled events are left out, and the occurrences
+// a recurring event overrides become its exception dates.
+func Decode(data []byte) ([]Event, error) {
+	lines, err := unfold(data)
+	if err != nil {
+		return nil, err
+	}
+
+	props := make([]property, len(lines))
+	for i, raw := range lines {
+		if props[i], err = parseLine(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	// Time zones may follow the events that use them.
+	zones, err := readTimeZones(props)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{zones: zones}
+
+	var events []*decodedEvent
+	var event *decodedEvent
+	var components []string
+	seenCalendar := false
+
+	for _, prop := range props {
+		switch prop.name {
+		case "BEGIN":
+			components = append(components, strings.ToUpper(prop.value))
+			if strings.EqualFold(prop.value, "VCALENDAR") {
+				seenCalendar = true
+			} else if strings.EqualFold(prop.value, "VEVENT") {
+				event = &decodedEvent{}
+			}
+			continue
+		case "END":
+			if len(components) == 0 || components[len(components)-1] != strings.ToUpper(prop.value) {
+				return nil, fmt.Errorf("unexpected END:%s", prop.value)
+			}
+			components = components[:len(components)-1]
+			if strings.EqualFold(prop.value, "VEVENT") && event != nil {
+				finishEvent(event)
+				events = append(events, event)
+				event = nil
+			}
+			continue
+		}
+
+		if event == nil {
+			continue
+		}
+		// Of the components nested in the event, only the alarms' triggers are read.
+		if component := components[len(components)-1]; component == "VALARM" {
+			if before, ok := alarmTrigger(prop); ok {
+				event.Alarms = append(event.Alarms, before)
+			}
+			continue
+		} else if component != "VEVENT" {
+			continue
+		}
+		if err := d.setProperty(event, prop); err != nil {
+			return nil, fmt.Errorf("%s: %w", prop.name, err)
+		}
+	}
+
+	if !seenCalendar {
+		return nil, ErrNoCalendar
+	} else if len(components) > 0 {
+		return nil, fmt.Errorf("unterminated %s", components[len(components)-1])
+	}
+	return applyOverrides(events), nil
+}
+
+// decoder reads the properties of events, converting their times
+// with the time zones of the object.
+type decoder struct {
+	zones map[string]*timeZone
+}
+
+// decodedEvent is an event being read, together with the properties that are
+// only needed to complete it.
+type decodedEvent struct {
+	Event
+	duration time.Duration
+	// recurrenceID is the start of the occurrence of a recurring event this one overrides.
+	recurrenceID time.Time
+	cancelled    bool
+}
+
+// applyOverrides drops the cancelled events and adds the occurrences that other
+// events override to the exception dates of their recurring event, so that the
+// overriding events do not duplicate them.
+func applyOverrides(decoded []*decodedEvent) []Event {
+	masters := make(map[string]*decodedEvent)
+	for _, event := range decoded {
+		if event.Recurrence != "" && event.recurrenceID.IsZero() {
+			masters[event.UID] = event
+		}
+	}
+
+	for _, event := range decoded {
+		if master, ok := masters[event.UID]; ok && !event.recurrenceID.IsZero() {
+			master.ExceptionDates = append(master.ExceptionDates, event.recurrenceID)
+		}
+	}
+
+	var events []Event
+	for _, event := range decoded {
+		if !event.cancelled {
+			events = append(events, event.Event)
+		}
+	}
+	return events
+}
+
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// unfold joins the folded content lines.
+func unfold(data []byte) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// parseLine splits a content line into its name, parameters and value.
+// Colons and semicolons inside quoted parameter values do not end them.
+func parseLine(line string) (property, error) {
+	prop := property{params: make(map[string]string)}
+
+	quoted := false
+	start := 0
+	var fields []string
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '"':
+			quoted = !quoted
+		case c == ';' && !quoted:
+			fields = append(fields, line[start:i])
+			start = i + 1
+		case c == ':' && !quoted:
+			fields = append(fields, line[start:i])
+			prop.value = line[i+1:]
+
+			prop.name = strings.ToUpper(fields[0])
+			for _, param := range fields[1:] {
+				name, value, _ := strings.Cut(param, "=")
+				prop.params[strings.ToUpper(name)] = strings.Trim(value, `"`)
+			}
+			return prop, nil
+		}
+	}
+	return property{}, fmt.Errorf("malformed content line %q", line)
+}
+
+func (d *decoder) setProperty(event *decodedEvent, prop property) error {
+	switch prop.name {
+	case "UID":
+		event.UID = prop.value
+	case "DTSTAMP":
+		stamp, err := time.Parse(utcDateTimeFormat, prop.value)
+		if err != nil {
+			return err
+		}
+		event.Stamp = stamp
+	case "SUMMARY":
+		event.Title = unescapeText(prop.value)
+	case "DESCRIPTION":
+		event.Description = unescapeText(prop.value)
+	case "LOCATION":
+		event.Location = unescapeText(prop.value)
+	case "CATEGORIES":
+		if event.EventType == "" {
+			event.EventType = strings.ToLower(unescapeText(splitList(prop.value)[0]))
+		}
+	case "STATUS":
+		event.cancelled = strings.EqualFold(prop.value, "CANCELLED")
+	case "RECURRENCE-ID":
+		recurrenceID, _, _, err := d.parseTime(prop)
+		if err != nil {
+			return err
+		}
+		event.recurrenceID = recurrenceID
+	case "DTSTART":
+		start, allDay, timeZone, err := d.parseTime(prop)
+		if err != nil {
+			return err
+		}
+		event.Start, event.AllDay, event.TimeZone = start, allDay, timeZone
+	case "DTEND":
+		end, allDay, _, err := d.parseTime(prop)
+		if err != nil {
+			return err
+		}
+		// DTEND of an all-day event is the day after its last day.
+		if allDay {
+			end = end.AddDate(0, 0, -1)
+		}
+		event.End = end
+	case "DURATION":
+		duration, err := parseDuration(prop.value)
+		if err != nil {
+			return err
+		}
+		event.duration = duration
+	case "RRULE":
+		event.Recurrence = prop.value
+	case "EXDATE":
+		for _, value := range splitList(prop.value) {
+			exdate, _, _, err := d.parseTime(property{name: prop.name, params: prop.params, value: value})
+			if err != nil {
+				return err
+			}
+			event.ExceptionDates = append(event.ExceptionDates, exdate)
+		}
+	}
+	return nil
+}
+
+// finishEvent derives the end of events that have a duration or no end at all.
+func finishEvent(event *decodedEvent) {
+	if !event.End.IsZero() {
+		if event.AllDay && event.End.Before(event.Start) {
+			event.End = event.Start
+		}
+		return
+	}
+
+	switch {
+	case event.duration > 0 && event.AllDay:
+		event.End = event.Start.Add(event.duration).AddDate(0, 0, -1)
+	case event.duration > 0:
+		event.End = event.Start.Add(event.duration)
+	default:
+		event.End = event.Start
+	}
+	if event.End.Before(event.Start) {
+		event.End = event.Start
+	}
+}
+
+// parseTime reads a DATE or DATE-TIME value as a wall-clock time.
+func (d *decoder) parseTime(prop property) (t time.Time, allDay bool, timeZone string, err error) {
+	value := prop.value
+	if strings.EqualFold(prop.params["VALUE"], "DATE") || len(value) == len(dateFormat) {
+		t, err = time.Parse(dateFormat, value)
+		return t, true, "", err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse(utcDateTimeFormat, value)
+		return t, false, "UTC", err
+	}
+
+	t, err = time.Parse(localDateTimeFormat, value)
+	if err != nil {
+		return time.Time{}, false, "", err
+	}
+
+	tzid := prop.params["TZID"]
+	if tzid == "" {
+		return t, false, "", nil
+	} else if name, ok := ianaName(tzid); ok {
+		return t, false, name, nil
+	} else if zone, ok := d.zones[tzid]; ok {
+		return t.Add(-zone.offsetAt(t)), false, "UTC", nil
+	}
+	return t, false, "", nil
+}
+
+// alarmTrigger returns how long before the start of the event an alarm goes off.
+// Triggers at an absolute time or relative to the end are skipped.
+func alarmTrigger(prop property) (time.Duration, bool) {
+	if prop.name != "TRIGGER" || strings.EqualFold(prop.params["VALUE"], "DATE-TIME") ||
+		strings.EqualFold(prop.params["RELATED"], "END") {
+		return 0, false
+	}
+
+	value, negative := strings.CutPrefix(prop.value, "-")
+	offset, err := parseDuration(value)
+	if err != nil {
+		return 0, false
+	}
+	if negative {
+		return offset, true
+	}
+	return -offset, true
+}
+
+// parseDuration reads an RFC 5545 duration such as "PT1H30M" or "P1D".
+func parseDuration(value string) (time.Duration, error) {
+	rest := strings.TrimPrefix(strings.TrimPrefix(value, "+"), "P")
+	if rest == value || strings.HasPrefix(value, "-") {
+		return 0, fmt.Errorf("unsupported duration %q", value)
+	}
+
+	var duration time.Duration
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour, 'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+	number := 0
+	inTime := false
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+		switch {
+		case c == 'T':
+			inTime = true
+		case c >= '0' && c <= '9':
+			number = number*10 + int(c-'0')
+		case units[c] != 0 && (c != 'M' || inTime):
+			duration += time.Duration(number) * units[c]
+			number = 0
+		default:
+			return 0, fmt.Errorf("unsupported duration %q", value)
+		}
+	}
+	return duration, nil
+}
+
+// splitList splits a comma separated value, leaving escaped commas in place.
+func splitList(value string) []string {
+	var items []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' {
+			i++
+		} else if value[i] == ',' {
+			items = append(items, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, value[start:])
+}
+
+var textUnescaper = strings.NewReplacer(
+	`\\`, `\`,
+	`\;`, ";",
+	`\,`, ",",
+	`\n`, "\n",
+	`\N`, "\n",
+)
+
+func unescapeText(value string) string {
+	return textUnescaper.Replace(value)
+}
@@ -0,0 +1,191 @@
+package ical_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ivgag/schedulr/ical"
+	"github.com/ivgag/schedulr/model"
+)
+
+func TestDecodeRoundTrip(t *testing.T) {
+	events := []ical.Event{
+		{
+			UID:    "event-1@schedulr",
+			Stamp:  stamp,
+			Alarms: []time.Duration{15 * time.Minute},
+			Event: model.Event{
+				Title:          "Concert; Jazz, Blues",
+				Description:    "Doors open at 18:00\nTickets at the door",
+				Start:          time.Date(2025, 3, 4, 19, 0, 0, 0, time.UTC),
+				End:            time.Date(2025, 3, 4, 21, 0, 0, 0, time.UTC),
+				Location:       "Club",
+				EventType:      "event",
+				TimeZone:       "Europe/Berlin",
+				Recurrence:     "FREQ=WEEKLY;COUNT=4;BYDAY=TU",
+				ExceptionDates: []time.Time{time.Date(2025, 3, 11, 19, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			UID:    "event-2@schedulr",
+			Stamp:  stamp,
+			Alarms: []time.Duration{-9 * time.Hour},
+			Event: model.Event{
+				Title:  "Festival",
+				Start:  time.Date(2025, 7, 11, 0, 0, 0, 0, time.UTC),
+				End:    time.Date(2025, 7, 13, 0, 0, 0, 0, time.UTC),
+				AllDay: true,
+			},
+		},
+	}
+
+	data, err := ical.Encode(events...)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	decoded, err := ical.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded, events) {
+		t.Errorf("Decode() = %+v, want %+v", decoded, events)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want model.Event
+	}{
+		{
+			name: "UTC times, folded lines and alarms",
+			data: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:1\r\nDTSTART:20250304T180000Z\r\n" +
+				"DTEND:20250304T200000Z\r\nSUMMARY:Book club at the \r\n library\r\n" +
+				"BEGIN:VALARM\r\nACTION:DISPLAY\r\nDESCRIPTION:Reminder\r\nTRIGGER:-PT15M\r\nEND:VALARM\r\n" +
+				"END:VEVENT\r\nEND:VCALENDAR\r\n",
+			want: model.Event{
+				Title:    "Book club at the library",
+				Start:    time.Date(2025, 3, 4, 18, 0, 0, 0, time.UTC),
+				End:      time.Date(2025, 3, 4, 20, 0, 0, 0, time.UTC),
+				TimeZone: "UTC",
+			},
+		},
+		{
+			name: "duration and unknown time zone",
+			data: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;TZID=\"W. Europe Standard Time\":20250304T180000\n" +
+				"DURATION:PT1H30M\nSUMMARY:Standup\nEND:VEVENT\nEND:VCALENDAR\n",
+			want: model.Event{
+				Title: "Standup",
+				Start: time.Date(2025, 3, 4, 18, 0, 0, 0, time.UTC),
+				End:   time.Date(2025, 3, 4, 19, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "time zone described by VTIMEZONE",
+			data: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;TZID=\"W. Europe Standard Time\":20250704T180000\n" +
+				"DTEND;TZID=\"W. Europe Standard Time\":20251027T090000\nSUMMARY:Exhibition\nEND:VEVENT\n" +
+				"BEGIN:VTIMEZONE\nTZID:W. Europe Standard Time\n" +
+				"BEGIN:STANDARD\nDTSTART:16010101T030000\nTZOFFSETFROM:+0200\nTZOFFSETTO:+0100\n" +
+				"RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=10\nEND:STANDARD\n" +
+				"BEGIN:DAYLIGHT\nDTSTART:16010101T020000\nTZOFFSETFROM:+0100\nTZOFFSETTO:+0200\n" +
+				"RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=3\nEND:DAYLIGHT\n" +
+				"END:VTIMEZONE\nEND:VCALENDAR\n",
+			want: model.Event{
+				Title:    "Exhibition",
+				Start:    time.Date(2025, 7, 4, 16, 0, 0, 0, time.UTC),
+				End:      time.Date(2025, 10, 27, 8, 0, 0, 0, time.UTC),
+				TimeZone: "UTC",
+			},
+		},
+		{
+			name: "IANA name behind a path",
+			data: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;TZID=/mozilla.org/20050126_1/Europe/Berlin:20250304T180000\n" +
+				"SUMMARY:Standup\nEND:VEVENT\nEND:VCALENDAR\n",
+			want: model.Event{
+				Title:    "Standup",
+				Start:    time.Date(2025, 3, 4, 18, 0, 0, 0, time.UTC),
+				End:      time.Date(2025, 3, 4, 18, 0, 0, 0, time.UTC),
+				TimeZone: "Europe/Berlin",
+			},
+		},
+		{
+			name: "date without end",
+			data: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;VALUE=DATE:20250512\nSUMMARY:Birthday\n" +
+				"CATEGORIES:Birthday,Family\nEND:VEVENT\nEND:VCALENDAR\n",
+			want: model.Event{
+				Title:     "Birthday",
+				Start:     time.Date(2025, 5, 12, 0, 0, 0, 0, time.UTC),
+				End:       time.Date(2025, 5, 12, 0, 0, 0, 0, time.UTC),
+				AllDay:    true,
+				EventType: "birthday",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := ical.Decode([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			} else if len(events) != 1 {
+				t.Fatalf("Decode() returned %d events, want 1", len(events))
+			}
+			if !reflect.DeepEqual(events[0].Event, tt.want) {
+				t.Errorf("Decode() = %+v, want %+v", events[0].Event, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecode_Overrides(t *testing.T) {
+	data := "BEGIN:VCALENDAR\n" +
+		"BEGIN:VEVENT\nUID:standup\nDTSTART;TZID=Europe/Berlin:20250303T090000\nSUMMARY:Standup\n" +
+		"RRULE:FREQ=WEEKLY;BYDAY=MO\nEND:VEVENT\n" +
+		"BEGIN:VEVENT\nUID:standup\nRECURRENCE-ID;TZID=Europe/Berlin:20250310T090000\n" +
+		"DTSTART;TZID=Europe/Berlin:20250310T100000\nSUMMARY:Standup (moved)\nEND:VEVENT\n" +
+		"BEGIN:VEVENT\nUID:standup\nRECURRENCE-ID;TZID=Europe/Berlin:20250317T090000\n" +
+		"DTSTART;TZID=Europe/Berlin:20250317T090000\nSUMMARY:Standup\nSTATUS:CANCELLED\nEND:VEVENT\n" +
+		"BEGIN:VEVENT\nUID:party\nDTSTART;TZID=Europe/Berlin:20250320T200000\nSUMMARY:Party\n" +
+		"STATUS:CANCELLED\nEND:VEVENT\n" +
+		"END:VCALENDAR\n"
+
+	events, err := ical.Decode([]byte(data))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	var titles []string
+	for _, event := range events {
+		titles = append(titles, event.Title)
+	}
+	if want := []string{"Standup", "Standup (moved)"}; !reflect.DeepEqual(titles, want) {
+		t.Fatalf("Decode() titles = %v, want %v", titles, want)
+	}
+
+	want := []time.Time{
+		time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 17, 9, 0, 0, 0, time.UTC),
+	}
+	if !reflect.DeepEqual(events[0].ExceptionDates, want) {
+		t.Errorf("ExceptionDates = %v, want %v", events[0].ExceptionDates, want)
+	}
+	if events[1].Recurrence != "" {
+		t.Errorf("override Recurrence = %q, want none", events[1].Recurrence)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"not a calendar",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:yesterday\nEND:VEVENT\nEND:VCALENDAR\n",
+	} {
+		if _, err := ical.Decode([]byte(data)); err == nil {
+			t.Errorf("Decode(%q) error = nil, want an error", data)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ical reads and writes events as RFC 5545 iCalendar objects.
+package ical
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/ivgag/schedulr/model"
+)
+
+const (
+	ProdID = "-//Schedulr//Schedulr//EN"
+
+	dateFormat          = "20060102"
+	localDateTimeFormat = "20060102T150405"
+	utcDateTimeFormat   = "20060102T150405Z"
+	maxLineLength       = 75
+)
+
+// Event is an event together with the properties that identify it in a calendar.
+type Event struct {
+	// UID identifies the event across updates.
+	UID string
+	// Stamp is the time the object was created.
+	Stamp time.Time
+	// Alarms are how long before the start the calendar reminds of the event.
+	// Negative ones go off after the start, e.g. at 9:00 on the day of an all-day event.
+	Alarms []time.Duration
+	model.Event
+}
+
+// Encode writes the events as a single iCalendar object. Events with a time zone
+// are written with a TZID and a VTIMEZONE describing it, events without one
+// as floating times and all-day events as dates.
+func Encode(events ...Event) ([]byte, error) {
+	w := &writer{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:" + ProdID)
+	w.line("CALSCALE:GREGORIAN")
+
+	zones, err := timeZones(events)
+	if err != nil {
+		return nil, err
+	}
+	for _, zone := range zones {
+		writeTimeZone(w, zone.location, zone.year)
+	}
+
+	for i := range events {
+		if err := writeEvent(w, &events[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	w.line("END:VCALENDAR")
+	return w.buf.Bytes(), nil
+}
+
+type zoneYear struct {
+	location *time.Location
+	year     int
+}
+
+// timeZones returns the zones the events use, each with the year of its first event.
+func timeZones(events []Event) ([]zoneYear, error) {
+	years := make(map[string]zoneYear)
+	for _, event := range events {
+		if event.AllDay || event.TimeZone == "" {
+			continue
+		}
+
+		loc, err := time.LoadLocation(event.TimeZone)
+		if err != nil {
+			return nil, err
+		}
+		if zone, ok := years[loc.String()]; !ok || event.Start.Year() < zone.year {
+			years[loc.String()] = zoneYear{location: loc, year: event.Start.Year()}
+		}
+	}
+
+	zones := make([]zoneYear, 0, len(years))
+	for _, zone := range years {
+		zones = append(zones, zone)
+	}
+	sort.Slice(zones, func(i, j int) bool { return zones[i].location.String() < zones[j].location.String() })
+	return zones, nil
+}
+
+func writeEvent(w *writer, event *Event) error {
+	var loc *time.Location
+	if !event.AllDay && event.TimeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(event.TimeZone); err != nil {
+			return err
+		}
+	}
+
+	w.line("BEGIN:VEVENT")
+	w.line("UID:" + escapeText(event.UID))
+	w.line("DTSTAMP:" + event.Stamp.UTC().Format(utcDateTimeFormat))
+
+	if event.AllDay {
+		start := toDate(event.Start)
+		end := toDate(event.End)
+		if end.Before(start) {
+			end = start
+		}
+		// DTEND of an all-day event is the day after its last day.
+		w.line("DTSTART;VALUE=DATE:" + start.Format(dateFormat))
+		w.line("DTEND;VALUE=DATE:" + end.AddDate(0, 0, 1).Format(dateFormat))
+	} else {
+		w.line("DTSTART" + dateTime(event.Start, loc))
+		w.line("DTEND" + dateTime(event.End, loc))
+	}
+
+	w.line("SUMMARY:" + escapeText(event.Title))
+	if event.Description != "" {
+		w.line("DESCRIPTION:" + escapeText(event.Description))
+	}
+	if event.Location != "" {
+		w.line("LOCATION:" + escapeText(event.Location))
+	}
+	if event.EventType != "" {
+		w.line("CATEGORIES:" + escapeText(event.EventType))
+	}
+
+	if err := writeRecurrence(w, event, loc); err != nil {
+		return err
+	}
+
+	for _, before := range event.Alarms {
+		w.line("BEGIN:VALARM")
+		w.line("ACTION:DISPLAY")
+		w.line("DESCRIPTION:" + escapeText(event.Title))
+		w.line("TRIGGER:" + formatDuration(-before))
+		w.line("END:VALARM")
+	}
+
+	w.line("END:VEVENT")
+	return nil
+}
+
+// writeRecurrence writes the RRULE and EXDATE properties. The value type of UNTIL
+// and EXDATE has to match DTSTART: dates for all-day events, UTC for zoned times
+// and local times for floating ones.
+func writeRecurrence(w *writer, event *Event, loc *time.Location) error {
+	rule, ok, err := event.RRule()
+	if err != nil || !ok {
+		return err
+	}
+
+	until := rule.Until
+	untilDate := rule.UntilDate
+	rule.Until = time.Time{}
+	value := rule.String()
+
+	if !until.IsZero() {
+		switch {
+		case event.AllDay && untilDate:
+			value += ";UNTIL=" + until.Format(dateFormat)
+		case event.AllDay:
+			value += ";UNTIL=" + until.In(locationOrUTC(loc)).Format(dateFormat)
+		case untilDate:
+			endOfDay := time.Date(until.Year(), until.Month(), until.Day(), 23, 59, 59, 0, locationOrUTC(loc))
+			value += ";UNTIL=" + formatUntil(endOfDay, loc)
+		default:
+			value += ";UNTIL=" + formatUntil(until, loc)
+		}
+	}
+	w.line("RRULE:" + value)
+
+	if len(event.ExceptionDates) == 0 {
+		return nil
+	}
+
+	dates := make([]string, len(event.ExceptionDates))
+	for i, date := range event.ExceptionDates {
+		switch {
+		case event.AllDay:
+			dates[i] = date.Format(dateFormat)
+		default:
+			dates[i] = date.Format(localDateTimeFormat)
+		}
+	}
+
+	switch {
+	case event.AllDay:
+		w.line("EXDATE;VALUE=DATE:" + strings.Join(dates, ","))
+	case loc != nil:
+		w.line("EXDATE;TZID=" + loc.String() + ":" + strings.Join(dates, ","))
+	default:
+		w.line("EXDATE:" + strings.Join(dates, ","))
+	}
+	return nil
+}
+
+func formatUntil(until time.Time, loc *time.Location) string {
+	if loc == nil {
+		return until.Format(localDateTimeFormat)
+	}
+	return until.UTC().Format(utcDateTimeFormat)
+}
+
+// dateTime formats the wall-clock time t as a property value, including the colon.
+func dateTime(t time.Time, loc *time.Location) string {
+	if loc == nil {
+		return ":" + t.Format(localDateTimeFormat)
+	}
+	return ";TZID=" + loc.String() + ":" + t.Format(localDateTimeFormat)
+}
+
+// writeTimeZone describes the offsets the zone uses in the given year.
+func writeTimeZone(w *writer, loc *time.Location, year int) {
+	w.line("BEGIN:VTIMEZONE")
+	w.line("TZID:" + loc.String())
+
+	t := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	yearEnd := t.AddDate(1, 0, 0)
+	for t.Before(yearEnd) {
+		start, end := t.ZoneBounds()
+		name, offset := t.Zone()
+
+		fromOffset := offset
+		dtstart := "19700101T000000"
+		if !start.IsZero() {
+			_, fromOffset = start.Add(-time.Second).Zone()
+			dtstart = start.In(time.FixedZone("", fromOffset)).Format(localDateTimeFormat)
+		}
+
+		component := "STANDARD"
+		if t.IsDST() {
+			component = "DAYLIGHT"
+		}
+		w.line("BEGIN:" + component)
+		w.line("DTSTART:" + dtstart)
+		w.line("TZOFFSETFROM:" + formatOffset(fromOffset))
+		w.line("TZOFFSETTO:" + formatOffset(offset))
+		w.line("TZNAME:" + escapeText(name))
+		w.line("END:" + component)
+
+		if end.IsZero() {
+			break
+		}
+		t = end
+	}
+
+	w.line("END:VTIMEZONE")
+}
+
+// formatDuration writes d as an RFC 5545 duration such as "-PT15M" or "P1D".
+func formatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+
+	if d%(24*time.Hour) == 0 && d > 0 {
+		return fmt.Sprintf("%sP%dD", sign, d/(24*time.Hour))
+	}
+
+	value := sign + "PT"
+	if hours := d / time.Hour; hours > 0 {
+		value += fmt.Sprintf("%dH", hours)
+	}
+	if minutes := d % time.Hour / time.Minute; minutes > 0 {
+		value += fmt.Sprintf("%dM", minutes)
+	}
+	if seconds := d % time.Minute / time.Second; seconds > 0 || d < time.Minute {
+		value += fmt.Sprintf("%dS", seconds)
+	}
+	return value
+}
+
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds%3600/60)
+}
+
+func locationOrUTC(loc *time.Location) *time.Location {
+	if loc == nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func toDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+func escapeText(value string) string {
+	return textEscaper.Replace(value)
+}
+
+type writer struct {
+	buf bytes.Buffer
+}
+
+// line writes a content line, folding it into lines of at most 75 octets
+// without splitting UTF-8 sequences.
+func (w *writer) line(content string) {
+	limit := maxLineLength
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(content[cut]) {
+			cut--
+		}
+		w.buf.WriteString(content[:cut])
+		w.buf.WriteString("\r\n ")
+		content = content[cut:]
+		// Continuation lines start with a space, which counts towards the limit.
+		limit = maxLineLength - 1
+	}
+	w.buf.WriteString(content)
+	w.buf.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,202 @@
+package ical_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ivgag/schedulr/ical"
+	"github.com/ivgag/schedulr/model"
+)
+
+var stamp = time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name  string
+		event model.Event
+		want  []string
+	}{
+		{
+			name: "zoned event",
+			event: model.Event{
+				Title:       "Concert; Jazz, Blues",
+				Description: "Doors open at 18:00\nTickets at the door",
+				Start:       time.Date(2025, 3, 4, 19, 0, 0, 0, time.UTC),
+				End:         time.Date(2025, 3, 4, 21, 0, 0, 0, time.UTC),
+				Location:    "Club",
+				EventType:   "event",
+				TimeZone:    "Europe/Berlin",
+			},
+			want: []string{
+				"BEGIN:VTIMEZONE\r\nTZID:Europe/Berlin\r\n",
+				"BEGIN:DAYLIGHT\r\nDTSTART:20250330T020000\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0200\r\n",
+				"BEGIN:STANDARD\r\nDTSTART:20251026T030000\r\nTZOFFSETFROM:+0200\r\nTZOFFSETTO:+0100\r\n",
+				"UID:event-1@schedulr\r\n",
+				"DTSTAMP:20250201T100000Z\r\n",
+				"DTSTART;TZID=Europe/Berlin:20250304T190000\r\n",
+				"DTEND;TZID=Europe/Berlin:20250304T210000\r\n",
+				`SUMMARY:Concert\; Jazz\, Blues` + "\r\n",
+				`DESCRIPTION:Doors open at 18:00\nTickets at the door` + "\r\n",
+				"LOCATION:Club\r\n",
+				"CATEGORIES:event\r\n",
+			},
+		},
+		{
+			name: "floating event",
+			event: model.Event{
+				Title: "Call",
+				Start: time.Date(2025, 3, 4, 9, 0, 0, 0, time.UTC),
+				End:   time.Date(2025, 3, 4, 9, 30, 0, 0, time.UTC),
+			},
+			want: []string{
+				"DTSTART:20250304T090000\r\n",
+				"DTEND:20250304T093000\r\n",
+			},
+		},
+		{
+			name: "all-day event",
+			event: model.Event{
+				Title:  "Festival",
+				Start:  time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC),
+				End:    time.Date(2025, 5, 3, 0, 0, 0, 0, time.UTC),
+				AllDay: true,
+			},
+			want: []string{
+				"DTSTART;VALUE=DATE:20250501\r\n",
+				"DTEND;VALUE=DATE:20250504\r\n",
+			},
+		},
+		{
+			name: "zoned recurring event",
+			event: model.Event{
+				Title:          "Training",
+				Start:          time.Date(2025, 3, 4, 19, 0, 0, 0, time.UTC),
+				End:            time.Date(2025, 3, 4, 20, 0, 0, 0, time.UTC),
+				TimeZone:       "Europe/Berlin",
+				Recurrence:     "FREQ=WEEKLY;BYDAY=TU;UNTIL=20250630",
+				ExceptionDates: []time.Time{time.Date(2025, 4, 15, 19, 0, 0, 0, time.UTC)},
+			},
+			want: []string{
+				"RRULE:FREQ=WEEKLY;BYDAY=TU;UNTIL=20250630T215959Z\r\n",
+				"EXDATE;TZID=Europe/Berlin:20250415T190000\r\n",
+			},
+		},
+		{
+			name: "floating recurring event",
+			event: model.Event{
+				Title:      "Standup",
+				Start:      time.Date(2025, 3, 4, 9, 0, 0, 0, time.UTC),
+				End:        time.Date(2025, 3, 4, 9, 15, 0, 0, time.UTC),
+				Recurrence: "FREQ=DAILY;UNTIL=20250310",
+			},
+			want: []string{
+				"RRULE:FREQ=DAILY;UNTIL=20250310T235959\r\n",
+			},
+		},
+		{
+			name: "all-day recurring event",
+			event: model.Event{
+				Title:          "Birthday",
+				Start:          time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+				End:            time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+				AllDay:         true,
+				Recurrence:     "FREQ=YEARLY;COUNT=3",
+				ExceptionDates: []time.Time{time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)},
+			},
+			want: []string{
+				"RRULE:FREQ=YEARLY;COUNT=3\r\n",
+				"EXDATE;VALUE=DATE:20260601\r\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := ical.Encode(ical.Event{UID: "event-1@schedulr", Stamp: stamp, Event: tt.event})
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+
+			got := string(data)
+			if !strings.HasPrefix(got, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:"+ical.ProdID+"\r\n") ||
+				!strings.HasSuffix(got, "END:VEVENT\r\nEND:VCALENDAR\r\n") {
+				t.Errorf("Encode() = %q, want a calendar with a single event", got)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Encode() = %q, want it to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEncode_FoldsLongLines(t *testing.T) {
+	description := strings.Repeat("Привет, мир! ", 20)
+	data, err := ical.Encode(ical.Event{
+		UID:   "event-1@schedulr",
+		Stamp: stamp,
+		Event: model.Event{
+			Title:       "Long",
+			Description: description,
+			Start:       time.Date(2025, 3, 4, 9, 0, 0, 0, time.UTC),
+			End:         time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	for _, line := range strings.Split(string(data), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line %q is %d octets long, want at most 75", line, len(line))
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line %q splits a UTF-8 sequence", line)
+		}
+	}
+
+	unfolded := strings.ReplaceAll(string(data), "\r\n ", "")
+	if !strings.Contains(unfolded, `DESCRIPTION:`+strings.ReplaceAll(description, ",", `\,`)) {
+		t.Errorf("unfolded object does not contain the description: %q", unfolded)
+	}
+}
+
+func TestEncode_Alarms(t *testing.T) {
+	data, err := ical.Encode(ical.Event{
+		UID:    "event-1@schedulr",
+		Stamp:  stamp,
+		Alarms: []time.Duration{15 * time.Minute, 24 * time.Hour, 0, -9 * time.Hour},
+		Event: model.Event{
+			Title: "Dentist",
+			Start: time.Date(2025, 3, 4, 9, 0, 0, 0, time.UTC),
+			End:   time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	got := string(data)
+	for _, trigger := range []string{"-PT15M", "-P1D", "PT0S", "PT9H"} {
+		want := "BEGIN:VALARM\r\nACTION:DISPLAY\r\nDESCRIPTION:Dentist\r\nTRIGGER:" + trigger + "\r\nEND:VALARM\r\n"
+		if !strings.Contains(got, want) {
+			t.Errorf("Encode() = %q, want it to contain %q", got, want)
+		}
+	}
+}
+
+func TestEncode_RejectsUnknownTimeZone(t *testing.T) {
+	_, err := ical.Encode(ical.Event{
+		UID: "event-1@schedulr",
+		Event: model.Event{
+			Title:    "Somewhere",
+			TimeZone: "Nowhere/Unknown",
+		},
+	})
+	if err == nil {
+		t.Error("Encode() error = nil, want an error for an unknown time zone")
+	}
+}
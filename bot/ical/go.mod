@@ -0,0 +1,7 @@
+module github.com/ivgag/schedulr/ical
+
+go 1.23.3
+
+replace github.com/ivgag/schedulr/model v0.0.0 => ../model
+
+require github.com/ivgag/schedulr/model v0.0.0
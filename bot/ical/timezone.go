@@ -0,0 +1,243 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+)
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// timeZone is a VTIMEZONE whose TZID is not an IANA name, such as the Windows
+// names Outlook uses. Times in it are converted with the offsets it describes.
+type timeZone struct {
+	observances []observance
+}
+
+// observance is a STANDARD or DAYLIGHT part of a VTIMEZONE.
+type observance struct {
+	// start is the wall-clock time of the first onset.
+	start time.Time
+	// offset is the UTC offset from the onset on.
+	offset time.Duration
+	// rule repeats the onset every year, if set.
+	rule *model.RRule
+}
+
+// ianaName returns the IANA name of a TZID. Besides plain names, it accepts
+// the ones prefixed with a path, like "/mozilla.org/20050126_1/Europe/Berlin".
+func ianaName(tzid string) (string, bool) {
+	segments := strings.Split(strings.Trim(tzid, "/"), "/")
+	for i := range segments {
+		name := strings.Join(segments[i:], "/")
+		if name == "" || name == "Local" {
+			continue
+		}
+		if _, err := time.LoadLocation(name); err == nil {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// readTimeZones reads the VTIMEZONE components by their TZID.
+func readTimeZones(props []property) (map[string]*timeZone, error) {
+	zones := make(map[string]*timeZone)
+
+	var zone *timeZone
+	var current *observance
+	var tzid string
+	for _, prop := range props {
+		switch {
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VTIMEZONE"):
+			zone, tzid = &timeZone{}, ""
+		case zone == nil:
+			continue
+		case prop.name == "BEGIN":
+			current = &observance{}
+		case prop.name == "END" && strings.EqualFold(prop.value, "VTIMEZONE"):
+			if tzid != "" && len(zone.observances) > 0 {
+				zones[tzid] = zone
+			}
+			zone = nil
+		case prop.name == "END" && current != nil:
+			zone.observances = append(zone.observances, *current)
+			current = nil
+		case prop.name == "TZID" && current == nil:
+			tzid = prop.value
+		case current != nil:
+			if err := setObservanceProperty(current, prop); err != nil {
+				return nil, fmt.Errorf("VTIMEZONE %s: %s: %w", tzid, prop.name, err)
+			}
+		}
+	}
+	return zones, nil
+}
+
+func setObservanceProperty(o *observance, prop property) error {
+	switch prop.name {
+	case "DTSTART":
+		start, err := time.Parse(localDateTimeFormat, prop.value)
+		if err != nil {
+			return err
+		}
+		o.start = start
+	case "TZOFFSETTO":
+		offset, err := parseOffset(prop.value)
+		if err != nil {
+			return err
+		}
+		o.offset = offset
+	case "RRULE":
+		rule, err := model.ParseRRule(prop.value)
+		if err != nil {
+			return err
+		}
+		if rule.Freq != "YEARLY" {
+			return fmt.Errorf("unsupported rule %q", prop.value)
+		}
+		o.rule = &rule
+	}
+	return nil
+}
+
+// parseOffset reads a UTC offset such as "+0100" or "-053000".
+func parseOffset(value string) (time.Duration, error) {
+	if len(value) != 5 && len(value) != 7 || value[0] != '+' && value[0] != '-' {
+		return 0, fmt.Errorf("malformed offset %q", value)
+	}
+
+	var offset time.Duration
+	units := []time.Duration{time.Hour, time.Minute, time.Second}
+	for i := 1; i < len(value); i += 2 {
+		n, err := strconv.Atoi(value[i : i+2])
+		if err != nil {
+			return 0, fmt.Errorf("malformed offset %q", value)
+		}
+		offset += time.Duration(n) * units[i/2]
+	}
+	if value[0] == '-' {
+		offset = -offset
+	}
+	return offset, nil
+}
+
+// offsetAt returns the UTC offset in effect at the wall-clock time t: the one of
+// the observance with the latest onset before t. Times before every onset get
+// the offset of the earliest observance.
+func (z *timeZone) offsetAt(t time.Time) time.Duration {
+	var latest, earliest time.Time
+	var offset, earliestOffset time.Duration
+	found := false
+	for _, o := range z.observances {
+		if onset, ok := o.lastOnset(t); ok && (!found || onset.After(latest)) {
+			latest, offset, found = onset, o.offset, true
+		}
+		if earliest.IsZero() || o.start.Before(earliest) {
+			earliest, earliestOffset = o.start, o.offset
+		}
+	}
+	if !found {
+		return earliestOffset
+	}
+	return offset
+}
+
+// lastOnset returns the latest onset of the observance not after t.
+func (o observance) lastOnset(t time.Time) (time.Time, bool) {
+	if o.start.After(t) {
+		return time.Time{}, false
+	} else if o.rule == nil {
+		return o.start, true
+	}
+
+	for year := t.Year(); year > o.start.Year(); year-- {
+		onset, ok := o.onsetIn(year)
+		if !ok || onset.After(t) {
+			continue
+		}
+		if !o.rule.Until.IsZero() && onset.After(o.rule.Until.Add(o.offset)) {
+			continue
+		}
+		return onset, true
+	}
+	return o.start, true
+}
+
+// onsetIn returns the onset of a yearly observance in the given year,
+// such as the last Sunday of March for "FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU".
+func (o observance) onsetIn(year int) (time.Time, bool) {
+	month := o.start.Month()
+	if len(o.rule.ByMonth) > 0 {
+		month = time.Month(o.rule.ByMonth[0])
+	}
+	hour, minute, second := o.start.Clock()
+
+	day := o.start.Day()
+	if len(o.rule.ByMonthDay) > 0 {
+		day = o.rule.ByMonthDay[0]
+	}
+	if len(o.rule.ByDay) == 0 {
+		return time.Date(year, month, day, hour, minute, second, 0, time.UTC), true
+	}
+
+	byDay := o.rule.ByDay[0]
+	weekday, ok := weekdays[byDay[len(byDay)-2:]]
+	if !ok {
+		return time.Time{}, false
+	}
+	n, err := strconv.Atoi(byDay[:len(byDay)-2])
+	if err != nil {
+		// Without an ordinal the weekday falls on or after the month day,
+		// like "BYMONTHDAY=8,9,10,11,12,13,14;BYDAY=SU" for the second Sunday.
+		if len(o.rule.ByMonthDay) == 0 {
+			day = 1
+		}
+		n = 1
+	} else {
+		day = 1
+	}
+
+	var date time.Time
+	if n > 0 {
+		date = time.Date(year, month, day, hour, minute, second, 0, time.UTC)
+		date = date.AddDate(0, 0, (int(weekday)-int(date.Weekday())+7)%7+7*(n-1))
+	} else {
+		date = time.Date(year, month+1, 0, hour, minute, second, 0, time.UTC)
+		date = date.AddDate(0, 0, -(int(date.Weekday())-int(weekday)+7)%7+7*(n+1))
+	}
+	if date.Month() != month {
+		return time.Time{}, false
+	}
+	return date, true
+}
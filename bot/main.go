@@ -48,33 +48,70 @@ func main() {
 	db := initDatabase(cfg.Database.URL)
 	defer db.Close()
 
+	tokenCipher, err := storage.NewTokenCipher(cfg.Database.Encryption)
+	if err != nil {
+		log.Panic().Err(err).Msg("Failed to initialize token encryption")
+	} else if !tokenCipher.Enabled() {
+		log.Warn().Msg("No token encryption keys configured, OAuth tokens are stored as plaintext")
+	}
+
 	// Initialize repositories.
 	userRepo := storage.NewUserRepository(db)
-	linkedAccountRepo := storage.NewLinkedAccountRepository(db)
+	linkedAccountRepo := storage.NewLinkedAccountRepository(db, tokenCipher)
+
+	// "schedulr reencrypt" re-encrypts stored tokens with the active key and exits.
+	if len(os.Args) > 1 && os.Args[1] == "reencrypt" {
+		reencryptTokens(tokenCipher, linkedAccountRepo)
+		return
+	}
+
+	eventDraftRepo := storage.NewEventDraftRepository(db)
+	scheduledEventRepo := storage.NewScheduledEventRepository(db)
+	oauthStateRepo := storage.NewOAuthStateRepository(db)
+	calendarPreferenceRepo := storage.NewCalendarPreferenceRepository(db)
+	messageBufferRepo := storage.NewMessageBufferRepository(db)
+	calDAVLinkRepo := storage.NewCalDAVLinkRepository(db)
 
 	// Initialize AI services.
 	aiSvc := initAIService(&cfg.AIConfig)
 
-	// Initialize Google token and user services.
-	googleTokenSvc := service.NewGoogleTokenService(&cfg.Google, linkedAccountRepo)
+	// Initialize token and calendar services of each provider.
+	oauthStateSvc := service.NewOAuthStateService(cfg.OAuth, oauthStateRepo)
+	googleTokenSvc := service.NewGoogleTokenService(&cfg.Google, oauthStateSvc, linkedAccountRepo)
 	tokenServices := map[model.Provider]service.TokenService{
 		model.ProviderGoogle: googleTokenSvc,
 	}
-	userSvc := service.NewUserService(userRepo, tokenServices)
-
-	// Initialize calendar and event services.
-	googleCalendarSvc := service.NewGoogleCalendarService(googleTokenSvc)
 	calendarServices := map[model.Provider]service.CalendarService{
-		model.ProviderGoogle: googleCalendarSvc,
+		model.ProviderGoogle: service.NewGoogleCalendarService(googleTokenSvc),
+	}
+
+	// Microsoft is optional, as it needs an app registered in Azure AD.
+	if cfg.Microsoft.ClientID != "" {
+		microsoftTokenSvc := service.NewMicrosoftTokenService(&cfg.Microsoft, oauthStateSvc, linkedAccountRepo)
+		tokenServices[model.ProviderMicrosoft] = microsoftTokenSvc
+		calendarServices[model.ProviderMicrosoft] = service.NewMicrosoftCalendarService(&cfg.Microsoft, microsoftTokenSvc)
 	}
-	eventSvc := service.NewEventService(*aiSvc, *userSvc, calendarServices)
+
+	calDAVSvc := service.NewCalDAVCalendarService(cfg.CalDAV, linkedAccountRepo)
+	calendarServices[model.ProviderCalDAV] = calDAVSvc
+	calendarServices[model.ProviderICS] = service.NewICSCalendarService()
+
+	// Initialize user, event, feed and buffer services.
+	userSvc := service.NewUserService(userRepo, linkedAccountRepo, tokenServices, calDAVSvc)
+	eventSvc := service.NewEventService(
+		*aiSvc, *userSvc, calendarServices, eventDraftRepo, scheduledEventRepo, calendarPreferenceRepo,
+	)
+
+	feedSvc := service.NewFeedService(cfg.Feed, userRepo, scheduledEventRepo)
+	messageBufferSvc := service.NewMessageBufferService(cfg.Buffer, messageBufferRepo)
+	calDAVLinkSvc := service.NewCalDAVLinkService(calDAVLinkRepo)
 
 	// Start Telegram bot.
-	bot := tgbot.NewBot(ctx, &cfg.TelegramBot, userSvc, eventSvc)
+	bot := tgbot.NewBot(ctx, &cfg.TelegramBot, userSvc, eventSvc, oauthStateSvc, feedSvc, messageBufferSvc, calDAVLinkSvc)
 	go startTelegramBot(bot)
 
 	// Initialize REST router and server.
-	router := rest.NewRouter(&cfg.TelegramBot, userSvc)
+	router := rest.NewRouter(&cfg.TelegramBot, userSvc, oauthStateSvc, feedSvc, bot)
 	srv := initHTTPServer(cfg.Rest, router)
 	go startHTTPServer(srv, cfg.Rest)
 
@@ -98,10 +135,35 @@ func initDatabase(dbURL string) *sql.DB {
 	return db
 }
 
+func reencryptTokens(tokenCipher *storage.TokenCipher, linkedAccountRepo storage.LinkedAccountRepository) {
+	if !tokenCipher.Enabled() {
+		log.Panic().Msg("Configure the token encryption keys before re-encrypting tokens")
+	}
+
+	updated, err := linkedAccountRepo.ReencryptTokens()
+	if err != nil {
+		log.Panic().
+			Int("updated", updated).
+			Err(err).
+			Msg("Failed to re-encrypt tokens")
+	}
+
+	log.Info().
+		Int("updated", updated).
+		Msg("Tokens re-encrypted")
+}
+
 func initAIService(aiConfig *service.AIConfig) *service.AIService {
 	openAi := ai.NewOpenAI(&aiConfig.OpenAI)
 	deepseek := ai.NewDeepSeekAI(&aiConfig.Deepseek)
-	return service.NewAIService([]ai.AI{openAi, deepseek}, aiConfig)
+	ais := []ai.AI{openAi, deepseek}
+
+	// The self-hosted provider is optional and only registered when configured.
+	if aiConfig.OpenAICompatible.BaseURL != "" {
+		ais = append(ais, ai.NewOpenAICompatible(&aiConfig.OpenAICompatible))
+	}
+
+	return service.NewAIService(ais, aiConfig)
 }
 
 func createAutocertManager(restCfg rest.RestConfig) autocert.Manager {
@@ -166,6 +228,11 @@ type AppConfig struct {
 	TelegramBot tgbot.TelegramBotConfig `mapstructure:"telegram_bot"`
 	AIConfig    service.AIConfig        `mapstructure:"ai"`
 	Google      service.GoogleConfig    `mapstructure:"google"`
+	Microsoft   service.MicrosoftConfig `mapstructure:"microsoft"`
+	CalDAV      service.CalDAVConfig    `mapstructure:"caldav"`
+	OAuth       service.OAuthConfig     `mapstructure:"oauth"`
+	Feed        service.FeedConfig      `mapstructure:"feed"`
+	Buffer      service.BufferConfig    `mapstructure:"buffer"`
 	Database    storage.DatabaseConfig  `mapstructure:"database"`
 	Rest        rest.RestConfig         `mapstructure:"rest"`
 }
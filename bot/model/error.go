@@ -43,3 +43,15 @@ type NotFoundError struct {
 func (e NotFoundError) Error() string {
 	return e.Message
 }
+
+// RelinkRequiredError is returned when the provider no longer accepts the user's grant,
+// e.g. because the user has revoked access. The account has to be linked again.
+type RelinkRequiredError struct {
+	Provider Provider
+	Reason   string
+}
+
+// Error implements error.
+func (e RelinkRequiredError) Error() string {
+	return string(e.Provider) + " account has to be linked again: " + e.Reason
+}
@@ -25,10 +25,26 @@ import (
 )
 
 type ScheduledEvent struct {
-	Event Event  `json:"event"`
-	Link  string `json:"link"`
+	ID         string   `json:"id"`
+	Provider   Provider `json:"provider"`
+	CalendarID string   `json:"calendarId"`
+	Event      Event    `json:"event"`
+	Link       string   `json:"link"`
+	// Key is the dedupe key the bot tagged the event with, empty for events created elsewhere.
+	Key string `json:"key,omitempty"`
 }
 
+// Calendar is a calendar of the user's account that events can be created in.
+type Calendar struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Primary is the calendar events are created in when no other one is chosen.
+	Primary bool `json:"primary"`
+}
+
+// EventTypes are the types the AI assigns to extracted events.
+var EventTypes = []string{"event", "reminder", "meeting", "birthday", "holiday", "other"}
+
 type Event struct {
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
@@ -36,18 +52,44 @@ type Event struct {
 	End         time.Time `json:"end"`
 	Location    string    `json:"location"`
 	EventType   string    `json:"eventType"`
+	// AllDay events have no start and end time. Start and End are then
+	// midnights of the first and the last (inclusive) day of the event.
+	AllDay bool `json:"allDay"`
+	// TimeZone is the IANA name of the zone Start and End are given in.
+	// It is empty when the event uses the zone of the calendar.
+	TimeZone string `json:"timeZone"`
+	// Recurrence is an RFC 5545 RRULE without the "RRULE:" prefix.
+	// It is empty for single events.
+	Recurrence string `json:"recurrence"`
+	// ExceptionDates are the starts of occurrences excluded from the recurrence.
+	ExceptionDates []time.Time `json:"exceptionDates"`
+}
+
+// RRule parses the event's recurrence rule. It returns false for single events.
+func (e *Event) RRule() (RRule, bool, error) {
+	if e.Recurrence == "" {
+		return RRule{}, false, nil
+	}
+
+	rule, err := ParseRRule(e.Recurrence)
+	if err != nil {
+		return RRule{}, false, err
+	}
+	return rule, true, nil
 }
 
 func (e *Event) MarshalJSON() ([]byte, error) {
 	type Alias Event
 	return json.Marshal(&struct {
 		*Alias
-		Start string `json:"start"`
-		End   string `json:"end"`
+		Start          string   `json:"start"`
+		End            string   `json:"end"`
+		ExceptionDates []string `json:"exceptionDates"`
 	}{
-		Alias: (*Alias)(e),
-		Start: e.Start.Format(time.DateTime),
-		End:   e.End.Format(time.DateTime),
+		Alias:          (*Alias)(e),
+		Start:          formatEventTime(e.Start, e.AllDay),
+		End:            formatEventTime(e.End, e.AllDay),
+		ExceptionDates: formatEventTimes(e.ExceptionDates, e.AllDay),
 	})
 }
 
@@ -55,15 +97,55 @@ func (e *Event) UnmarshalJSON(data []byte) error {
 	type Alias Event
 	aux := &struct {
 		*Alias
-		Start string `json:"start"`
-		End   string `json:"end"`
+		Start          string   `json:"start"`
+		End            string   `json:"end"`
+		ExceptionDates []string `json:"exceptionDates"`
 	}{
 		Alias: (*Alias)(e),
 	}
 	if err := json.Unmarshal(data, &aux); err != nil {
 		return err
 	}
-	e.Start, _ = time.Parse(time.DateTime, aux.Start)
-	e.End, _ = time.Parse(time.DateTime, aux.End)
+	e.Start, _ = parseEventTime(aux.Start)
+	e.End, _ = parseEventTime(aux.End)
+
+	e.ExceptionDates = nil
+	for _, date := range aux.ExceptionDates {
+		if t, err := parseEventTime(date); err == nil {
+			e.ExceptionDates = append(e.ExceptionDates, t)
+		}
+	}
 	return nil
 }
+
+// Days returns the number of days an all-day event spans.
+func (e *Event) Days() int {
+	days := int(e.End.Sub(e.Start).Hours()/24) + 1
+	if days < 1 {
+		return 1
+	}
+	return days
+}
+
+func formatEventTime(t time.Time, allDay bool) string {
+	if allDay {
+		return t.Format(time.DateOnly)
+	}
+	return t.Format(time.DateTime)
+}
+
+func formatEventTimes(times []time.Time, allDay bool) []string {
+	formatted := make([]string, len(times))
+	for i, t := range times {
+		formatted[i] = formatEventTime(t, allDay)
+	}
+	return formatted
+}
+
+// parseEventTime accepts both date-times and dates, as all-day events carry only the date.
+func parseEventTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateTime, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
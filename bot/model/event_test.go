@@ -0,0 +1,88 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+)
+
+func TestEventJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		json      string
+		wantStart time.Time
+		wantEnd   time.Time
+		wantDays  int
+	}{
+		{
+			name:      "Timed event",
+			json:      `{"title":"Standup","start":"2025-02-18 10:00:00","end":"2025-02-18 10:15:00"}`,
+			wantStart: time.Date(2025, 2, 18, 10, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2025, 2, 18, 10, 15, 0, 0, time.UTC),
+			wantDays:  1,
+		},
+		{
+			name:      "All-day event",
+			json:      `{"title":"Birthday","start":"2025-03-01","end":"2025-03-01","allDay":true}`,
+			wantStart: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			wantDays:  1,
+		},
+		{
+			name:      "Multi-day event",
+			json:      `{"title":"Festival","start":"2025-05-01","end":"2025-05-03","allDay":true}`,
+			wantStart: time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2025, 5, 3, 0, 0, 0, 0, time.UTC),
+			wantDays:  3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var event model.Event
+			if err := json.Unmarshal([]byte(tt.json), &event); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !event.Start.Equal(tt.wantStart) || !event.End.Equal(tt.wantEnd) {
+				t.Errorf("Unmarshal() = %v - %v, want %v - %v", event.Start, event.End, tt.wantStart, tt.wantEnd)
+			}
+			if event.AllDay && event.Days() != tt.wantDays {
+				t.Errorf("Days() = %d, want %d", event.Days(), tt.wantDays)
+			}
+
+			data, err := json.Marshal(&event)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+
+			var roundTrip model.Event
+			if err := json.Unmarshal(data, &roundTrip); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !roundTrip.Start.Equal(event.Start) || !roundTrip.End.Equal(event.End) || roundTrip.AllDay != event.AllDay {
+				t.Errorf("round trip = %+v, want %+v", roundTrip, event)
+			}
+		})
+	}
+}
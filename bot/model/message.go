@@ -19,6 +19,8 @@
 
 package model
 
+import "time"
+
 type MessageType string
 
 const (
@@ -27,7 +29,14 @@ const (
 )
 
 type TextMessage struct {
+	MessageID   int
 	From        string
 	Text        string
 	MessageType MessageType
+	// ForwardedFrom is the name of the user, chat or channel
+	// the forwarded message was originally posted by.
+	ForwardedFrom string
+	// Date is when the message was originally posted. Relative dates
+	// in the text ("tomorrow") are resolved against it.
+	Date time.Time
 }
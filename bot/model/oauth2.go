@@ -31,5 +31,13 @@ type Token struct {
 type Provider string
 
 const (
-	ProviderGoogle Provider = "google"
+	ProviderGoogle    Provider = "google"
+	ProviderMicrosoft Provider = "microsoft"
+	ProviderCalDAV    Provider = "caldav"
+	// ProviderICS sends events to the user as iCalendar files. It needs no linked
+	// account, so it is not among the Providers.
+	ProviderICS Provider = "ics"
 )
+
+// Providers lists the calendar providers in the order they are offered to the user.
+var Providers = []Provider{ProviderGoogle, ProviderMicrosoft, ProviderCalDAV}
@@ -0,0 +1,307 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	untilDateFormat     = "20060102"
+	untilDateTimeFormat = "20060102T150405Z"
+)
+
+var frequencyUnits = map[string]string{
+	"DAILY":   "day",
+	"WEEKLY":  "week",
+	"MONTHLY": "month",
+	"YEARLY":  "year",
+}
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// RRule is a parsed RFC 5545 recurrence rule. Only the parts that make sense
+// for calendar events are supported: sub-daily frequencies and BYHOUR-like
+// parts are rejected.
+type RRule struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    time.Time
+	// UntilDate tells whether UNTIL was given as a date without a time.
+	UntilDate  bool
+	ByDay      []string
+	ByMonthDay []int
+	ByMonth    []int
+	BySetPos   []int
+	WeekStart  string
+}
+
+// ParseRRule parses and validates a recurrence rule such as
+// "FREQ=WEEKLY;BYDAY=TU;UNTIL=20250630". The "RRULE:" prefix is optional.
+func ParseRRule(value string) (RRule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" {
+		return RRule{}, ErrorForMessage("empty recurrence rule")
+	}
+
+	rule := RRule{Interval: 1}
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(value, ";") {
+		name, val, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		val = strings.ToUpper(strings.TrimSpace(val))
+		if !ok || name == "" || val == "" {
+			return RRule{}, fmt.Errorf("malformed recurrence rule part %q", part)
+		} else if seen[name] {
+			return RRule{}, fmt.Errorf("duplicate recurrence rule part %s", name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			if _, ok := frequencyUnits[val]; !ok {
+				return RRule{}, fmt.Errorf("unsupported recurrence frequency %s", val)
+			}
+			rule.Freq = val
+		case "INTERVAL":
+			rule.Interval, err = parsePositive(val)
+		case "COUNT":
+			rule.Count, err = parsePositive(val)
+		case "UNTIL":
+			rule.Until, rule.UntilDate, err = parseUntil(val)
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(val)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseIntList(val, -31, 31)
+		case "BYMONTH":
+			rule.ByMonth, err = parseIntList(val, 1, 12)
+		case "BYSETPOS":
+			rule.BySetPos, err = parseIntList(val, -366, 366)
+		case "WKST":
+			if _, ok := weekdays[val]; !ok {
+				err = fmt.Errorf("invalid week start %s", val)
+			}
+			rule.WeekStart = val
+		default:
+			err = fmt.Errorf("unsupported recurrence rule part %s", name)
+		}
+		if err != nil {
+			return RRule{}, err
+		}
+	}
+
+	if rule.Freq == "" {
+		return RRule{}, ErrorForMessage("recurrence rule must have a FREQ")
+	} else if rule.Count > 0 && !rule.Until.IsZero() {
+		return RRule{}, ErrorForMessage("recurrence rule must not have both COUNT and UNTIL")
+	}
+
+	return rule, nil
+}
+
+// String formats the rule without the "RRULE:" prefix.
+func (r RRule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		if r.UntilDate {
+			parts = append(parts, "UNTIL="+r.Until.Format(untilDateFormat))
+		} else {
+			parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilDateTimeFormat))
+		}
+	}
+	if len(r.ByDay) > 0 {
+		parts = append(parts, "BYDAY="+strings.Join(r.ByDay, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(r.ByMonth))
+	}
+	if len(r.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinInts(r.BySetPos))
+	}
+	if r.WeekStart != "" {
+		parts = append(parts, "WKST="+r.WeekStart)
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// Describe returns a human-readable summary of the rule,
+// e.g. "every week on Tuesday until 30 Jun 2025".
+func (r RRule) Describe() string {
+	var sb strings.Builder
+
+	unit := frequencyUnits[r.Freq]
+	if r.Interval > 1 {
+		sb.WriteString(fmt.Sprintf("every %d %ss", r.Interval, unit))
+	} else {
+		sb.WriteString("every " + unit)
+	}
+
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = describeByDay(day)
+		}
+		sb.WriteString(" on " + strings.Join(days, ", "))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = ordinal(day)
+		}
+		sb.WriteString(" on the " + strings.Join(days, ", ") + " day")
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]string, len(r.ByMonth))
+		for i, month := range r.ByMonth {
+			months[i] = time.Month(month).String()
+		}
+		sb.WriteString(" in " + strings.Join(months, ", "))
+	}
+
+	if r.Count == 1 {
+		sb.WriteString(", once")
+	} else if r.Count > 1 {
+		sb.WriteString(fmt.Sprintf(", %d times", r.Count))
+	} else if !r.Until.IsZero() {
+		sb.WriteString(" until " + r.Until.Format("2 Jan 2006"))
+	}
+
+	return sb.String()
+}
+
+func parsePositive(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid positive number %s", value)
+	}
+	return n, nil
+}
+
+func parseUntil(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(untilDateFormat, value); err == nil {
+		return t, true, nil
+	}
+	// A floating date-time is treated as UTC.
+	if t, err := time.Parse("20060102T150405", strings.TrimSuffix(value, "Z")); err == nil {
+		return t, false, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid UNTIL value %s", value)
+}
+
+func parseByDay(value string) ([]string, error) {
+	days := strings.Split(value, ",")
+	for _, day := range days {
+		if len(day) < 2 {
+			return nil, fmt.Errorf("invalid BYDAY value %s", day)
+		}
+		if _, ok := weekdays[day[len(day)-2:]]; !ok {
+			return nil, fmt.Errorf("invalid BYDAY value %s", day)
+		}
+		if prefix := day[:len(day)-2]; prefix != "" {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("invalid BYDAY value %s", day)
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseIntList(value string, min, max int) ([]int, error) {
+	var list []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(item)
+		if err != nil || n == 0 || n < min || n > max {
+			return nil, fmt.Errorf("invalid value %s", item)
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+func joinInts(values []int) string {
+	items := make([]string, len(values))
+	for i, v := range values {
+		items[i] = strconv.Itoa(v)
+	}
+	return strings.Join(items, ",")
+}
+
+func describeByDay(day string) string {
+	weekday := weekdays[day[len(day)-2:]].String()
+	prefix := day[:len(day)-2]
+	if prefix == "" {
+		return weekday
+	}
+
+	n, _ := strconv.Atoi(prefix)
+	return "the " + ordinal(n) + " " + weekday
+}
+
+func ordinal(n int) string {
+	switch {
+	case n == -1:
+		return "last"
+	case n < -1:
+		return ordinal(-n) + " to last"
+	}
+
+	suffix := "th"
+	switch n % 10 {
+	case 1:
+		if n%100 != 11 {
+			suffix = "st"
+		}
+	case 2:
+		if n%100 != 12 {
+			suffix = "nd"
+		}
+	case 3:
+		if n%100 != 13 {
+			suffix = "rd"
+		}
+	}
+	return strconv.Itoa(n) + suffix
+}
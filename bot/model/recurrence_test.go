@@ -0,0 +1,96 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model_test
+
+import (
+	"testing"
+
+	"github.com/ivgag/schedulr/model"
+)
+
+func TestParseRRule(t *testing.T) {
+	tests := []struct {
+		name         string
+		rule         string
+		wantString   string
+		wantDescribe string
+		wantErr      bool
+	}{
+		{
+			name:         "Weekly until date",
+			rule:         "FREQ=WEEKLY;BYDAY=TU;UNTIL=20250630",
+			wantString:   "FREQ=WEEKLY;UNTIL=20250630;BYDAY=TU",
+			wantDescribe: "every week on Tuesday until 30 Jun 2025",
+		},
+		{
+			name:         "Prefix and lower case",
+			rule:         "RRULE:freq=daily;count=5",
+			wantString:   "FREQ=DAILY;COUNT=5",
+			wantDescribe: "every day, 5 times",
+		},
+		{
+			name:         "Interval and UTC until",
+			rule:         "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;UNTIL=20250630T215959Z",
+			wantString:   "FREQ=WEEKLY;INTERVAL=2;UNTIL=20250630T215959Z;BYDAY=MO,WE",
+			wantDescribe: "every 2 weeks on Monday, Wednesday until 30 Jun 2025",
+		},
+		{
+			name:         "Last Friday of the month",
+			rule:         "FREQ=MONTHLY;BYDAY=-1FR",
+			wantString:   "FREQ=MONTHLY;BYDAY=-1FR",
+			wantDescribe: "every month on the last Friday",
+		},
+		{
+			name:         "Yearly by month and day",
+			rule:         "FREQ=YEARLY;BYMONTH=3;BYMONTHDAY=1",
+			wantString:   "FREQ=YEARLY;BYMONTHDAY=1;BYMONTH=3",
+			wantDescribe: "every year on the 1st day in March",
+		},
+		{name: "Empty", rule: "", wantErr: true},
+		{name: "Missing frequency", rule: "BYDAY=TU", wantErr: true},
+		{name: "Sub-daily frequency", rule: "FREQ=HOURLY", wantErr: true},
+		{name: "Count and until", rule: "FREQ=DAILY;COUNT=3;UNTIL=20250630", wantErr: true},
+		{name: "Invalid weekday", rule: "FREQ=WEEKLY;BYDAY=XX", wantErr: true},
+		{name: "Zero interval", rule: "FREQ=DAILY;INTERVAL=0", wantErr: true},
+		{name: "Unsupported part", rule: "FREQ=DAILY;BYHOUR=9", wantErr: true},
+		{name: "Malformed part", rule: "FREQ=DAILY;COUNT", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := model.ParseRRule(tt.rule)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseRRule(%q) = %v, want error", tt.rule, rule)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("ParseRRule(%q) error = %v", tt.rule, err)
+			}
+
+			if got := rule.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+			if got := rule.Describe(); got != tt.wantDescribe {
+				t.Errorf("Describe() = %q, want %q", got, tt.wantDescribe)
+			}
+		})
+	}
+}
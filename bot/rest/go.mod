@@ -6,6 +6,7 @@ toolchain go1.23.6
 
 replace (
 	github.com/ivgag/schedulr/ai => ../ai
+	github.com/ivgag/schedulr/ical => ../ical
 	github.com/ivgag/schedulr/model => ../model
 	github.com/ivgag/schedulr/service => ../service
 	github.com/ivgag/schedulr/storage => ../storage
@@ -17,7 +18,9 @@ require (
 	github.com/gin-gonic/gin v1.10.0
 	github.com/ivgag/schedulr/model v0.0.0
 	github.com/ivgag/schedulr/service v0.0.0
+	github.com/ivgag/schedulr/storage v0.0.0
 	github.com/ivgag/schedulr/tgbot v0.0.0
+	github.com/rs/zerolog v1.33.0
 )
 
 require (
@@ -45,7 +48,7 @@ require (
 	github.com/googleapis/enterprise-certificate-proxy v0.3.4 // indirect
 	github.com/googleapis/gax-go/v2 v2.14.1 // indirect
 	github.com/ivgag/schedulr/ai v0.0.0 //indirect
-	github.com/ivgag/schedulr/storage v0.0.0 // indirect
+	github.com/ivgag/schedulr/ical v0.0.0 // indirect
 	github.com/joho/godotenv v1.5.1 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/klauspost/cpuid/v2 v2.2.9 // indirect
@@ -55,10 +58,10 @@ require (
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/pelletier/go-toml/v2 v2.2.3 // indirect
-	github.com/rs/zerolog v1.33.0 // indirect
 	github.com/sashabaranov/go-openai v1.37.0 // indirect
 	github.com/twitchyliquid64/golang-asm v0.15.1 // indirect
 	github.com/ugorji/go/codec v1.2.12 // indirect
+	github.com/zsefvlol/timezonemapper v1.0.0 // indirect
 	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
 	go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.58.0 // indirect
 	go.opentelemetry.io/otel v1.34.0 // indirect
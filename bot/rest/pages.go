@@ -0,0 +1,48 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rest
+
+import "html/template"
+
+const oauthResultPage = "oauth_result"
+
+// oauthResultTemplate is the page shown in the browser at the end of account linking.
+var oauthResultTemplate = template.Must(template.New(oauthResultPage).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<title>{{.Title}} · Schedulr</title>
+	<style>
+		body { font-family: -apple-system, "Segoe UI", Roboto, sans-serif; background: #f4f5f7; margin: 0; }
+		main { max-width: 28rem; margin: 15vh auto; padding: 2rem; background: #fff; border-radius: 12px; text-align: center; }
+		h1 { font-size: 1.4rem; color: {{if .Success}}#1b7f3b{{else}}#b3261e{{end}}; }
+		a { display: inline-block; margin-top: 1rem; padding: .6rem 1.2rem; border-radius: 8px; background: #2481cc; color: #fff; text-decoration: none; }
+	</style>
+</head>
+<body>
+	<main>
+		<h1>{{.Title}}</h1>
+		<p>{{.Message}}</p>
+		{{if .BotURL}}<a href="{{.BotURL}}">Back to Telegram</a>{{end}}
+	</main>
+</body>
+</html>
+`))
@@ -20,11 +20,14 @@
 package rest
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/ivgag/schedulr/model"
 	"github.com/ivgag/schedulr/service"
 	"github.com/ivgag/schedulr/tgbot"
+	"github.com/rs/zerolog/log"
 
 	"github.com/gin-gonic/gin"
 )
@@ -32,25 +35,131 @@ import (
 func NewRouter(
 	tgBotConfig *tgbot.TelegramBotConfig,
 	userService *service.UserService,
+	oauthStates *service.OAuthStateService,
+	feeds *service.FeedService,
+	telegramBot *tgbot.Bot,
 ) *gin.Engine {
 	router := gin.Default()
+	router.SetHTMLTemplate(oauthResultTemplate)
 
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 
-	router.GET("/oauth2callback/google", func(c *gin.Context) {
-		code := c.Query("code")
+	router.GET("/oauth2callback/google", oauthCallback(tgBotConfig, userService, oauthStates, model.ProviderGoogle, "Google"))
+	router.GET("/oauth2callback/microsoft", oauthCallback(tgBotConfig, userService, oauthStates, model.ProviderMicrosoft, "Microsoft"))
+
+	router.GET("/feeds/:file", calendarFeed(feeds))
+	router.HEAD("/feeds/:file", calendarFeed(feeds))
+
+	if telegramBot != nil && telegramBot.WebhookEnabled() {
+		router.POST(telegramBot.WebhookPath(), gin.WrapF(telegramBot.WebhookHandler()))
+	}
+
+	return router
+}
+
+// oauthCallback completes the authorization request the user was sent to the provider with.
+func oauthCallback(
+	tgBotConfig *tgbot.TelegramBotConfig,
+	userService *service.UserService,
+	oauthStates *service.OAuthStateService,
+	provider model.Provider,
+	providerName string,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
 		state := c.Query("state")
+		code := c.Query("code")
 
-		err := userService.LinkAccount(state, model.ProviderGoogle, code)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if denied := c.Query("error"); denied != "" {
+			err := oauthStates.Reject(state, provider, "access was not granted ("+denied+")")
+			if err != nil && !isOAuthStateError(err) {
+				log.Error().Err(err).Msg("Failed to record the rejected authorization request")
+			}
+			renderOAuthResult(c, tgBotConfig, http.StatusOK, false, "Access was not granted, so your calendar is not linked.")
+			return
+		} else if state == "" || code == "" {
+			renderOAuthResult(c, tgBotConfig, http.StatusBadRequest, false, "The link is incomplete. Request a new one in Telegram.")
 			return
 		}
 
-		c.Redirect(http.StatusFound, tgBotConfig.URL)
-	})
+		err := userService.LinkAccount(state, provider, code)
+		switch {
+		case errors.Is(err, service.ErrOAuthStateExpired):
+			renderOAuthResult(c, tgBotConfig, http.StatusBadRequest, false, "The link has expired. Request a new one in Telegram.")
+		case errors.Is(err, service.ErrOAuthStateUsed):
+			renderOAuthResult(c, tgBotConfig, http.StatusBadRequest, false, "The link was already used. Request a new one in Telegram.")
+		case errors.Is(err, service.ErrOAuthStateNotFound):
+			renderOAuthResult(c, tgBotConfig, http.StatusBadRequest, false, "The link is not valid. Request a new one in Telegram.")
+		case errors.Is(err, service.ErrProviderUnavailable):
+			renderOAuthResult(c, tgBotConfig, http.StatusNotFound, false, providerName+" Calendar is not available.")
+		case err != nil:
+			log.Error().Str("provider", string(provider)).Err(err).Msg("Failed to link account")
+			renderOAuthResult(c, tgBotConfig, http.StatusBadGateway, false, providerName+" did not confirm the access. Try again later.")
+		default:
+			renderOAuthResult(c, tgBotConfig, http.StatusOK, true, "Your "+providerName+" Calendar is linked. You can go back to Telegram.")
+		}
+	}
+}
 
-	return router
+// calendarFeed serves the feed of the user with the token in the file name, such as
+// "/feeds/{token}.ics". Apps poll it, so unchanged feeds are answered with 304.
+func calendarFeed(feeds *service.FeedService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutSuffix(c.Param("file"), ".ics")
+		if !ok {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		feed, err := feeds.Feed(token)
+		var notFound model.NotFoundError
+		if errors.As(err, &notFound) {
+			c.Status(http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Error().Err(err).Msg("Failed to render calendar feed")
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+
+		c.Header("ETag", feed.ETag)
+		c.Header("Cache-Control", "private, no-cache")
+		if etagMatches(c.GetHeader("If-None-Match"), feed.ETag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+		c.Data(http.StatusOK, "text/calendar; charset=utf-8", feed.Data)
+	}
+}
+
+// etagMatches tells whether the If-None-Match header lists the ETag.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func isOAuthStateError(err error) bool {
+	return errors.Is(err, service.ErrOAuthStateNotFound) ||
+		errors.Is(err, service.ErrOAuthStateExpired) ||
+		errors.Is(err, service.ErrOAuthStateUsed)
+}
+
+func renderOAuthResult(c *gin.Context, tgBotConfig *tgbot.TelegramBotConfig, status int, success bool, message string) {
+	title := "Account linked"
+	if !success {
+		title = "Account not linked"
+	}
+
+	c.HTML(status, oauthResultPage, gin.H{
+		"Title":   title,
+		"Message": message,
+		"Success": success,
+		"BotURL":  tgBotConfig.URL,
+	})
 }
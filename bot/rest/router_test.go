@@ -0,0 +1,671 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rest_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/rest"
+	"github.com/ivgag/schedulr/service"
+	"github.com/ivgag/schedulr/storage"
+	"github.com/ivgag/schedulr/tgbot"
+)
+
+const (
+	telegramID = int64(42)
+	validCode  = "valid-code"
+)
+
+func TestGoogleOAuthFlow(t *testing.T) {
+	tests := []struct {
+		name        string
+		callback    func(state string) url.Values
+		prepare     func(env *oauthTestEnv, state string)
+		wantStatus  int
+		wantPage    string
+		wantLinked  bool
+		wantOutcome storage.OAuthStateStatus
+	}{
+		{
+			name:        "Links the account",
+			callback:    func(state string) url.Values { return url.Values{"state": {state}, "code": {validCode}} },
+			wantStatus:  http.StatusOK,
+			wantPage:    "Your Google Calendar is linked",
+			wantLinked:  true,
+			wantOutcome: storage.OAuthStateCompleted,
+		},
+		{
+			name:       "Rejects an unknown state",
+			callback:   func(state string) url.Values { return url.Values{"state": {"forged"}, "code": {validCode}} },
+			wantStatus: http.StatusBadRequest,
+			wantPage:   "The link is not valid",
+		},
+		{
+			name:     "Rejects an expired state",
+			callback: func(state string) url.Values { return url.Values{"state": {state}, "code": {validCode}} },
+			prepare: func(env *oauthTestEnv, state string) {
+				env.states.expire(state)
+			},
+			wantStatus: http.StatusBadRequest,
+			wantPage:   "The link has expired",
+		},
+		{
+			name:     "Rejects a used state",
+			callback: func(state string) url.Values { return url.Values{"state": {state}, "code": {validCode}} },
+			prepare: func(env *oauthTestEnv, state string) {
+				env.callback(url.Values{"state": {state}, "code": {validCode}})
+				env.accounts.reset()
+			},
+			wantStatus: http.StatusBadRequest,
+			wantPage:   "The link was already used",
+		},
+		{
+			name:        "Reports a failed code exchange",
+			callback:    func(state string) url.Values { return url.Values{"state": {state}, "code": {"wrong-code"}} },
+			wantStatus:  http.StatusBadGateway,
+			wantPage:    "Google did not confirm the access",
+			wantOutcome: storage.OAuthStateFailed,
+		},
+		{
+			name:        "Reports denied access",
+			callback:    func(state string) url.Values { return url.Values{"state": {state}, "error": {"access_denied"}} },
+			wantStatus:  http.StatusOK,
+			wantPage:    "Access was not granted",
+			wantOutcome: storage.OAuthStateFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newOAuthTestEnv(t)
+
+			link, err := env.userService.GetOAuth2Url(telegramID, model.ProviderGoogle)
+			if err != nil {
+				t.Fatalf("GetOAuth2Url() error = %v", err)
+			}
+			state := env.checkAuthURL(link)
+
+			if tt.prepare != nil {
+				tt.prepare(env, state)
+			}
+
+			recorder := env.callback(tt.callback(state))
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if body := recorder.Body.String(); !strings.Contains(body, tt.wantPage) || !strings.Contains(body, "<html") {
+				t.Errorf("page = %q, want an HTML page containing %q", body, tt.wantPage)
+			}
+
+			_, err = env.accounts.GetByUserIDAndProvider(env.user.ID, model.ProviderGoogle)
+			if linked := err == nil; linked != tt.wantLinked {
+				t.Errorf("account linked = %v, want %v", linked, tt.wantLinked)
+			}
+
+			if tt.wantOutcome != "" {
+				outcomes, err := env.stateService.ClaimNotifications(10)
+				if err != nil {
+					t.Fatalf("ClaimNotifications() error = %v", err)
+				}
+				if len(outcomes) != 1 || outcomes[0].Status != tt.wantOutcome || outcomes[0].UserID != env.user.ID {
+					t.Errorf("outcomes = %+v, want one %q outcome of the user", outcomes, tt.wantOutcome)
+				}
+			}
+		})
+	}
+}
+
+func TestReleaseNotification(t *testing.T) {
+	env := newOAuthTestEnv(t)
+
+	state, err := env.stateService.Create(env.user.ID, model.ProviderGoogle)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := env.stateService.Complete(state.State, nil); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	if outcomes, _ := env.stateService.ClaimNotifications(10); len(outcomes) != 1 {
+		t.Fatalf("outcomes = %+v, want one", outcomes)
+	}
+	if outcomes, _ := env.stateService.ClaimNotifications(10); len(outcomes) != 0 {
+		t.Fatalf("outcomes claimed twice = %+v, want none", outcomes)
+	}
+
+	if err := env.stateService.ReleaseNotification(state.State); err != nil {
+		t.Fatalf("ReleaseNotification() error = %v", err)
+	}
+	outcomes, _ := env.stateService.ClaimNotifications(10)
+	if len(outcomes) != 1 || outcomes[0].State != state.State {
+		t.Errorf("outcomes after release = %+v, want the released one", outcomes)
+	}
+}
+
+func TestCalendarFeed(t *testing.T) {
+	env := newOAuthTestEnv(t)
+	event := storage.ScheduledEvent{UserID: env.user.ID, Provider: model.ProviderGoogle, Event: model.Event{
+		Title: "Dentist",
+		Start: time.Date(2025, 3, 4, 9, 30, 0, 0, time.UTC),
+		End:   time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC),
+	}}
+	if err := env.events.Save(&event); err != nil {
+		t.Fatal(err)
+	}
+
+	feedURL, err := env.feeds.FeedURL(telegramID)
+	if err != nil {
+		t.Fatalf("FeedURL() error = %v", err)
+	}
+	path := strings.TrimPrefix(feedURL, "https://schedulr.example")
+
+	get := func(path string, ifNoneMatch string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		if ifNoneMatch != "" {
+			request.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		env.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := get(path, "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/calendar") {
+		t.Errorf("Content-Type = %q, want text/calendar", contentType)
+	}
+	if body := recorder.Body.String(); !strings.Contains(body, "SUMMARY:Dentist") {
+		t.Errorf("feed = %q, want the event", body)
+	}
+	etag := recorder.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("feed has no ETag")
+	}
+
+	if recorder := get(path, etag); recorder.Code != http.StatusNotModified || recorder.Body.Len() != 0 {
+		t.Errorf("status with a matching ETag = %d, want %d without a body", recorder.Code, http.StatusNotModified)
+	}
+	if recorder := get(path, `"stale", W/`+etag); recorder.Code != http.StatusNotModified {
+		t.Errorf("status with a weak matching ETag = %d, want %d", recorder.Code, http.StatusNotModified)
+	}
+	if recorder := get(path, `"stale"`); recorder.Code != http.StatusOK {
+		t.Errorf("status with a stale ETag = %d, want %d", recorder.Code, http.StatusOK)
+	}
+
+	for _, path := range []string{"/feeds/forged.ics", strings.TrimSuffix(path, ".ics")} {
+		if recorder := get(path, ""); recorder.Code != http.StatusNotFound {
+			t.Errorf("status of %s = %d, want %d", path, recorder.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestTelegramWebhook(t *testing.T) {
+	env := newOAuthTestEnv(t)
+	cfg := &tgbot.TelegramBotConfig{Token: "123:token", Webhook: tgbot.WebhookConfig{
+		Enabled:     true,
+		URL:         "https://schedulr.example",
+		SecretToken: "webhook-secret",
+	}}
+	// The bot is not started, so authenticated updates are asked to be retried.
+	bot := tgbot.NewBot(context.Background(), cfg, env.userService, nil, env.stateService, env.feeds, nil, nil)
+	router := rest.NewRouter(cfg, env.userService, env.stateService, env.feeds, bot)
+
+	if !strings.HasPrefix(bot.WebhookPath(), "/telegram/") || strings.Contains(bot.WebhookPath(), "token") {
+		t.Errorf("WebhookPath() = %q, want a secret path that does not reveal the token", bot.WebhookPath())
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		secret     string
+		wantStatus int
+	}{
+		{name: "Rejects a missing secret", path: bot.WebhookPath(), wantStatus: http.StatusUnauthorized},
+		{name: "Rejects a wrong secret", path: bot.WebhookPath(), secret: "guess", wantStatus: http.StatusUnauthorized},
+		{name: "Accepts the secret", path: bot.WebhookPath(), secret: "webhook-secret", wantStatus: http.StatusServiceUnavailable},
+		{name: "Serves no other path", path: "/telegram/webhook", secret: "webhook-secret", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(`{"update_id":1}`))
+			if tt.secret != "" {
+				request.Header.Set("X-Telegram-Bot-Api-Secret-Token", tt.secret)
+			}
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	// In polling mode the webhook path is not served at all.
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, bot.WebhookPath(), strings.NewReader(`{"update_id":1}`))
+	request.Header.Set("X-Telegram-Bot-Api-Secret-Token", "webhook-secret")
+	env.router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("status in polling mode = %d, want %d", recorder.Code, http.StatusNotFound)
+	}
+}
+
+type oauthTestEnv struct {
+	t            *testing.T
+	router       *gin.Engine
+	user         storage.User
+	userService  *service.UserService
+	stateService *service.OAuthStateService
+	states       *fakeOAuthStateRepository
+	accounts     *fakeLinkedAccountRepository
+	events       *fakeScheduledEventRepository
+	feeds        *service.FeedService
+}
+
+func newOAuthTestEnv(t *testing.T) *oauthTestEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	states := &fakeOAuthStateRepository{states: make(map[string]storage.OAuthState)}
+	oauthServer := httptest.NewServer(http.HandlerFunc(fakeTokenEndpoint(t, states)))
+	t.Cleanup(oauthServer.Close)
+
+	users := &fakeUserRepository{}
+	user := storage.User{TelegramID: telegramID, Username: "tester"}
+	if err := users.Save(&user); err != nil {
+		t.Fatal(err)
+	}
+
+	accounts := &fakeLinkedAccountRepository{}
+	events := &fakeScheduledEventRepository{}
+	stateService := service.NewOAuthStateService(service.OAuthConfig{}, states)
+
+	tokenService := service.NewGoogleTokenService(&service.GoogleConfig{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "https://schedulr.example/oauth2callback/google",
+		AuthURL:      oauthServer.URL + "/auth",
+		TokenURL:     oauthServer.URL + "/token",
+	}, stateService, accounts)
+	userService := service.NewUserService(users, accounts, map[model.Provider]service.TokenService{
+		model.ProviderGoogle: tokenService,
+	}, nil)
+
+	feeds := service.NewFeedService(service.FeedConfig{BaseURL: "https://schedulr.example"}, users, events)
+
+	return &oauthTestEnv{
+		t:            t,
+		router:       rest.NewRouter(&tgbot.TelegramBotConfig{URL: "https://t.me/schedulr_bot"}, userService, stateService, feeds, nil),
+		user:         user,
+		userService:  userService,
+		stateService: stateService,
+		states:       states,
+		accounts:     accounts,
+		events:       events,
+		feeds:        feeds,
+	}
+}
+
+// checkAuthURL verifies that the consent link carries a PKCE challenge and returns its state.
+func (env *oauthTestEnv) checkAuthURL(link string) string {
+	env.t.Helper()
+
+	parsed, err := url.Parse(link)
+	if err != nil {
+		env.t.Fatalf("invalid consent link %q: %v", link, err)
+	}
+
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		env.t.Errorf("consent link %q has no S256 PKCE challenge", link)
+	}
+
+	state := query.Get("state")
+	stored, err := env.states.GetByState(state)
+	if err != nil {
+		env.t.Fatalf("state %q is not stored: %v", state, err)
+	}
+	if stored.UserID != env.user.ID || pkceChallenge(stored.CodeVerifier) != query.Get("code_challenge") {
+		env.t.Errorf("stored state %+v does not match the consent link", stored)
+	}
+	return state
+}
+
+func (env *oauthTestEnv) callback(query url.Values) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/oauth2callback/google?"+query.Encode(), nil)
+	env.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// fakeTokenEndpoint accepts validCode only together with the PKCE verifier of a consent
+// link the bot has issued. The fake does not issue codes itself, so it looks the verifier
+// up among the stored states.
+func fakeTokenEndpoint(t *testing.T, states *fakeOAuthStateRepository) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/token" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse token request: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.Form.Get("code") != validCode || !states.hasVerifier(r.Form.Get("code_verifier")) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "access-token",
+			"refresh_token": "refresh-token",
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+		})
+	}
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+type fakeUserRepository struct {
+	users []storage.User
+}
+
+func (r *fakeUserRepository) GetByID(id int) (storage.User, error) {
+	for _, user := range r.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return storage.User{}, model.NotFoundError{Message: "user not found"}
+}
+
+func (r *fakeUserRepository) GetByTelegramID(telegramID int64) (storage.User, error) {
+	for _, user := range r.users {
+		if user.TelegramID == telegramID {
+			return user, nil
+		}
+	}
+	return storage.User{}, model.NotFoundError{Message: "user not found"}
+}
+
+func (r *fakeUserRepository) GetByFeedToken(token string) (storage.User, error) {
+	for _, user := range r.users {
+		if token != "" && user.FeedToken == token {
+			return user, nil
+		}
+	}
+	return storage.User{}, model.NotFoundError{Message: "user not found"}
+}
+
+func (r *fakeUserRepository) Save(user *storage.User) error {
+	user.ID = len(r.users) + 1
+	r.users = append(r.users, *user)
+	return nil
+}
+
+func (r *fakeUserRepository) UpdateTimeZone(id int, timeZone string) error {
+	return nil
+}
+
+func (r *fakeUserRepository) UpdateCalendarProviders(id int, defaultProvider model.Provider, allProviders bool) error {
+	return nil
+}
+
+func (r *fakeUserRepository) UpdateFeedToken(id int, token string) error {
+	for i := range r.users {
+		if r.users[i].ID == id {
+			r.users[i].FeedToken = token
+		}
+	}
+	return nil
+}
+
+// fakeScheduledEventRepository only lists the events of a user.
+type fakeScheduledEventRepository struct {
+	events []storage.ScheduledEvent
+}
+
+func (r *fakeScheduledEventRepository) Save(event *storage.ScheduledEvent) error {
+	event.ID = len(r.events) + 1
+	r.events = append(r.events, *event)
+	return nil
+}
+
+func (r *fakeScheduledEventRepository) ListByCardMessageID(chatID int64, messageID int) ([]storage.ScheduledEvent, error) {
+	return nil, nil
+}
+
+func (r *fakeScheduledEventRepository) ListByBatchID(batchID string) ([]storage.ScheduledEvent, error) {
+	return nil, nil
+}
+
+func (r *fakeScheduledEventRepository) ListByUserID(userID int) ([]storage.ScheduledEvent, error) {
+	var events []storage.ScheduledEvent
+	for _, event := range r.events {
+		if event.UserID == userID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (r *fakeScheduledEventRepository) ListByUserIDBetween(userID int, from time.Time, to time.Time) ([]storage.ScheduledEvent, error) {
+	return nil, nil
+}
+
+func (r *fakeScheduledEventRepository) Delete(id int) error {
+	return nil
+}
+
+func (r *fakeScheduledEventRepository) DeleteByProvider(userID int, provider model.Provider) ([]storage.ScheduledEvent, error) {
+	return nil, nil
+}
+
+type fakeLinkedAccountRepository struct {
+	mu       sync.Mutex
+	accounts []storage.LinkedAccount
+}
+
+func (r *fakeLinkedAccountRepository) Save(account storage.LinkedAccount) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.accounts = append(r.accounts, account)
+	return nil
+}
+
+func (r *fakeLinkedAccountRepository) GetByUserIDAndProvider(userID int, provider model.Provider) (storage.LinkedAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, account := range r.accounts {
+		if account.UserID == userID && account.Provider == provider {
+			return account, nil
+		}
+	}
+	return storage.LinkedAccount{}, model.NotFoundError{Message: "account not found"}
+}
+
+func (r *fakeLinkedAccountRepository) ListByUserID(userID int) ([]storage.LinkedAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var accounts []storage.LinkedAccount
+	for _, account := range r.accounts {
+		if account.UserID == userID {
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts, nil
+}
+
+func (r *fakeLinkedAccountRepository) UpdateStatus(
+	userID int,
+	provider model.Provider,
+	status storage.LinkedAccountStatus,
+	reason string,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, account := range r.accounts {
+		if account.UserID == userID && account.Provider == provider {
+			r.accounts[i].Status = status
+			r.accounts[i].StatusReason = reason
+		}
+	}
+	return nil
+}
+
+func (r *fakeLinkedAccountRepository) Delete(userID int, provider model.Provider) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, account := range r.accounts {
+		if account.UserID == userID && account.Provider == provider {
+			r.accounts = append(r.accounts[:i], r.accounts[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (r *fakeLinkedAccountRepository) ReencryptTokens() (int, error) {
+	return 0, nil
+}
+
+func (r *fakeLinkedAccountRepository) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.accounts = nil
+}
+
+type fakeOAuthStateRepository struct {
+	mu       sync.Mutex
+	states   map[string]storage.OAuthState
+	notified map[string]bool
+}
+
+func (r *fakeOAuthStateRepository) Save(state *storage.OAuthState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state.CreatedAt = time.Now().UTC()
+	r.states[state.State] = *state
+	return nil
+}
+
+func (r *fakeOAuthStateRepository) GetByState(state string) (storage.OAuthState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.states[state]
+	if !ok {
+		return storage.OAuthState{}, model.NotFoundError{Message: "authorization request not found"}
+	}
+	return stored, nil
+}
+
+func (r *fakeOAuthStateRepository) Consume(state string, provider model.Provider) (storage.OAuthState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.states[state]
+	if !ok || stored.Provider != provider || stored.Status != storage.OAuthStatePending ||
+		!stored.ExpiresAt.After(time.Now().UTC()) {
+		return storage.OAuthState{}, model.NotFoundError{Message: "authorization request not found"}
+	}
+	stored.Status = storage.OAuthStateConsumed
+	r.states[state] = stored
+	return stored, nil
+}
+
+func (r *fakeOAuthStateRepository) Complete(state string, status storage.OAuthStateStatus, errorMessage string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := r.states[state]
+	stored.Status = status
+	stored.Error = errorMessage
+	r.states[state] = stored
+	return nil
+}
+
+func (r *fakeOAuthStateRepository) ClaimUnnotified(limit int) ([]storage.OAuthState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.notified == nil {
+		r.notified = make(map[string]bool)
+	}
+
+	var claimed []storage.OAuthState
+	for key, state := range r.states {
+		if len(claimed) == limit {
+			break
+		}
+		if (state.Status == storage.OAuthStateCompleted || state.Status == storage.OAuthStateFailed) && !r.notified[key] {
+			r.notified[key] = true
+			claimed = append(claimed, state)
+		}
+	}
+	return claimed, nil
+}
+
+func (r *fakeOAuthStateRepository) Unclaim(state string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.notified, state)
+	return nil
+}
+
+func (r *fakeOAuthStateRepository) DeleteStale(before time.Time) error {
+	return nil
+}
+
+func (r *fakeOAuthStateRepository) hasVerifier(verifier string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, state := range r.states {
+		if verifier != "" && state.CodeVerifier == verifier {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *fakeOAuthStateRepository) expire(state string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := r.states[state]
+	stored.ExpiresAt = time.Now().UTC().Add(-time.Minute)
+	r.states[state] = stored
+}
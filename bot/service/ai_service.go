@@ -22,7 +22,9 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/cenkalti/backoff/v5"
 	"github.com/rs/zerolog/log"
@@ -36,67 +38,178 @@ func NewAIService(
 	config *AIConfig,
 ) *AIService {
 	aisMap := make(map[string]ai.AI)
+	breakers := make(map[string]*circuitBreaker)
 	for _, ai := range ais {
-		aisMap[strings.ToLower(string(ai.Provider()))] = ai
+		key := strings.ToLower(string(ai.Provider()))
+		aisMap[key] = ai
+		breakers[key] = newCircuitBreaker(config.CircuitBreaker)
 	}
 
 	return &AIService{
-		aisMap: aisMap,
-		config: config,
+		aisMap:   aisMap,
+		breakers: breakers,
+		config:   config,
 	}
 }
 
 type AIService struct {
-	aisMap map[string]ai.AI
-	config *AIConfig
+	aisMap   map[string]ai.AI
+	breakers map[string]*circuitBreaker
+	config   *AIConfig
 }
 
-func (s *AIService) ExtractCalendarEvents(messages *[]model.TextMessage) (*[]model.Event, model.Error) {
+func (s *AIService) ExtractCalendarEvents(messages *[]model.TextMessage, location *time.Location) (*[]model.Event, model.Error) {
+	log.Debug().
+		Interface("messages", messages).
+		Msg("Extracting events with AI providers")
+
+	response, err := withFailover(s, func(agent ai.AI) (*ai.AiResponse[[]model.Event], model.Error) {
+		return agent.ExtractCalendarEvents(messages, location)
+	})
+	if err != nil {
+		log.Warn().
+			Interface("messages", messages).
+			Err(err).
+			Msg("No AI provider was able to extract events from the message")
+		return nil, err
+	}
+
+	log.Debug().
+		Interface("messages", messages).
+		Interface("response", response).
+		Msg("AI provider successfully extracted events from the message")
+
+	for i := range response.Result {
+		normalizeEvent(&response.Result[i])
+	}
+
+	return &response.Result, nil
+}
+
+// EditCalendarEvent applies the user's instruction to the event and returns the modified event.
+func (s *AIService) EditCalendarEvent(event *model.Event, instruction string, location *time.Location) (*model.Event, model.Error) {
+	response, err := withFailover(s, func(agent ai.AI) (*ai.AiResponse[model.Event], model.Error) {
+		return agent.EditCalendarEvent(event, instruction, location)
+	})
+	if err != nil {
+		log.Warn().
+			Interface("event", event).
+			Str("instruction", instruction).
+			Err(err).
+			Msg("No AI provider was able to edit the event")
+		return nil, err
+	}
+
+	normalizeEvent(&response.Result)
+
+	return &response.Result, nil
+}
+
+// normalizeEvent validates the parts of the event that the model produces as free text.
+func normalizeEvent(event *model.Event) {
+	normalizeRecurrence(event)
+	normalizeTimeZone(event)
+}
+
+// normalizeTimeZone drops a time zone that is not a known IANA name,
+// so that the event falls back to the user's time zone.
+func normalizeTimeZone(event *model.Event) {
+	if event.TimeZone == "" {
+		return
+	} else if event.AllDay {
+		event.TimeZone = ""
+		return
+	}
+
+	if _, err := time.LoadLocation(event.TimeZone); err != nil {
+		log.Warn().
+			Str("timeZone", event.TimeZone).
+			Err(err).
+			Msg("AI provider returned an unknown time zone")
+
+		event.TimeZone = ""
+	}
+}
+
+// normalizeRecurrence validates the recurrence rule produced by the model.
+// An invalid rule is dropped, so the event is created as a single occurrence
+// and the card shows no repetition to the user.
+func normalizeRecurrence(event *model.Event) {
+	rule, ok, err := event.RRule()
+	if err != nil {
+		log.Warn().
+			Str("recurrence", event.Recurrence).
+			Err(err).
+			Msg("AI provider returned an invalid recurrence rule")
+
+		event.Recurrence = ""
+		event.ExceptionDates = nil
+	} else if ok {
+		event.Recurrence = rule.String()
+	} else {
+		event.ExceptionDates = nil
+	}
+}
+
+// withFailover calls the providers in priority order until one of them succeeds.
+// Providers whose circuit breaker is open are skipped. If every provider fails,
+// the returned AIProvidersError lists the failure of each of them.
+func withFailover[T any](
+	s *AIService,
+	call func(agent ai.AI) (*ai.AiResponse[T], model.Error),
+) (*ai.AiResponse[T], model.Error) {
+	var failures []ProviderFailure
+
 	for _, service := range s.config.Priority {
-		ai, ok := s.aisMap[strings.ToLower(service)]
+		key := strings.ToLower(service)
+		agent, ok := s.aisMap[key]
 		if !ok {
 			continue
 		}
 
-		log.Debug().
-			Interface("messages", messages).
-			Str("provider", string(ai.Provider())).
-			Msg("Extracting events with AI provider")
+		provider := string(agent.Provider())
+		breaker := s.breakers[key]
+		if !breaker.Allow() {
+			failures = append(failures, ProviderFailure{Provider: provider, Err: ErrCircuitOpen})
+			continue
+		}
 
-		response, err := s.extractEventsWithRetires(messages, ai)
+		response, err := withRetries(func() (*ai.AiResponse[T], model.Error) {
+			return call(agent)
+		})
 		if err != nil {
+			if tripsBreaker(err) {
+				breaker.RecordFailure()
+			} else {
+				breaker.RecordSuccess()
+			}
+			failures = append(failures, ProviderFailure{Provider: provider, Err: err})
+
 			log.Warn().
-				Interface("messages", messages).
-				Str("provider", string(ai.Provider())).
+				Str("provider", provider).
 				Err(err).
-				Msg("AI provider failed to extract events from the message")
+				Msg("AI provider failed, trying the next one")
+			continue
 		}
 
-		log.Debug().
-			Interface("messages", messages).
-			Interface("response", response).
-			Str("provider", string(ai.Provider())).
-			Msg("AI provider successfully extracted events from the message")
-
-		return &response.Result, nil
+		breaker.RecordSuccess()
+		return response, nil
 	}
 
-	return nil, model.ErrorForMessage("No AI provider was able to extract events from the message")
+	return nil, AIProvidersError{Failures: failures}
 }
 
-func (s *AIService) extractEventsWithRetires(
-	messages *[]model.TextMessage,
-	agent ai.AI,
-) (*ai.AiResponse[[]model.Event], model.Error) {
-	operation := func() (ai.AiResponse[[]model.Event], error) {
+// withRetries retries the AI call while it fails with a retryable API error.
+func withRetries[T any](call func() (*ai.AiResponse[T], model.Error)) (*ai.AiResponse[T], model.Error) {
+	operation := func() (ai.AiResponse[T], error) {
 		var apiError = ai.ApiError{}
-		response, err := agent.ExtractCalendarEvents(messages)
+		response, err := call()
 		if err == nil {
 			return *response, nil
 		} else if errors.As(err, &apiError) && apiError.Retryable {
-			return ai.AiResponse[[]model.Event]{}, err
+			return ai.AiResponse[T]{}, err
 		} else {
-			return *response, backoff.Permanent(err)
+			return ai.AiResponse[T]{}, backoff.Permanent(err)
 		}
 	}
 
@@ -113,8 +226,43 @@ func (s *AIService) extractEventsWithRetires(
 	return &response, nil
 }
 
+// ErrCircuitOpen marks a provider that was skipped because it kept failing recently.
+var ErrCircuitOpen = model.ErrorForMessage("circuit breaker is open")
+
+type ProviderFailure struct {
+	Provider string
+	Err      error
+}
+
+// AIProvidersError is returned when no AI provider could handle the request.
+type AIProvidersError struct {
+	Failures []ProviderFailure
+}
+
+func (e AIProvidersError) Error() string {
+	if len(e.Failures) == 0 {
+		return "no AI provider is configured"
+	}
+
+	failures := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		failures[i] = fmt.Sprintf("%s: %v", failure.Provider, failure.Err)
+	}
+	return "all AI providers failed: " + strings.Join(failures, "; ")
+}
+
+func (e AIProvidersError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, failure := range e.Failures {
+		errs[i] = failure.Err
+	}
+	return errs
+}
+
 type AIConfig struct {
-	Deepseek ai.DeepseekConfig `mapstructure:"deepseek"`
-	OpenAI   ai.OpenAIConfig   `mapstructure:"openai"`
-	Priority []string          `mapstructure:"priority"`
+	Deepseek         ai.DeepseekConfig         `mapstructure:"deepseek"`
+	OpenAI           ai.OpenAIConfig           `mapstructure:"openai"`
+	OpenAICompatible ai.OpenAICompatibleConfig `mapstructure:"openai_compatible"`
+	Priority         []string                  `mapstructure:"priority"`
+	CircuitBreaker   CircuitBreakerConfig      `mapstructure:"circuit_breaker"`
 }
@@ -0,0 +1,174 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ivgag/schedulr/ai"
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/service"
+)
+
+type fakeAI struct {
+	provider ai.AIProvider
+	err      model.Error
+	events   []model.Event
+	calls    int
+}
+
+func (f *fakeAI) ExtractCalendarEvents(messages *[]model.TextMessage, location *time.Location) (*ai.AiResponse[[]model.Event], model.Error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &ai.AiResponse[[]model.Event]{Result: f.events}, nil
+}
+
+func (f *fakeAI) EditCalendarEvent(event *model.Event, instruction string, location *time.Location) (*ai.AiResponse[model.Event], model.Error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &ai.AiResponse[model.Event]{Result: f.events[0]}, nil
+}
+
+func (f *fakeAI) Provider() ai.AIProvider {
+	return f.provider
+}
+
+// badRequest and rateLimited are not retryable, so the tests do not wait for backoff.
+var (
+	badRequest  = ai.ApiError{Message: "bad request", ResponseCode: 400}
+	rateLimited = ai.ApiError{Message: "rate limited", ResponseCode: 429}
+)
+
+func newTestAIService(breaker service.CircuitBreakerConfig, ais ...*fakeAI) *service.AIService {
+	agents := make([]ai.AI, len(ais))
+	priority := make([]string, len(ais))
+	for i, agent := range ais {
+		agents[i] = agent
+		priority[i] = string(agent.provider)
+	}
+
+	return service.NewAIService(agents, &service.AIConfig{
+		Priority:       priority,
+		CircuitBreaker: breaker,
+	})
+}
+
+func extract(s *service.AIService) (*[]model.Event, model.Error) {
+	return s.ExtractCalendarEvents(&[]model.TextMessage{{Text: "Concert tomorrow at 19:00"}}, time.UTC)
+}
+
+func TestExtractCalendarEventsFallsBackToNextProvider(t *testing.T) {
+	openAI := &fakeAI{provider: ai.ProviderOpenAI, err: badRequest}
+	deepSeek := &fakeAI{provider: ai.ProviderDeepSeek, events: []model.Event{{Title: "Concert"}}}
+	s := newTestAIService(service.CircuitBreakerConfig{}, openAI, deepSeek)
+
+	events, err := extract(s)
+	if err != nil {
+		t.Fatalf("ExtractCalendarEvents() error = %v", err)
+	}
+	if len(*events) != 1 || (*events)[0].Title != "Concert" {
+		t.Errorf("ExtractCalendarEvents() = %v, want the DeepSeek events", *events)
+	}
+}
+
+func TestExtractCalendarEventsAggregatesErrors(t *testing.T) {
+	openAI := &fakeAI{provider: ai.ProviderOpenAI, err: badRequest}
+	deepSeek := &fakeAI{provider: ai.ProviderDeepSeek, err: ai.ApiError{Message: "unauthorized", ResponseCode: 401}}
+	s := newTestAIService(service.CircuitBreakerConfig{}, openAI, deepSeek)
+
+	_, err := extract(s)
+
+	var providersErr service.AIProvidersError
+	if !errors.As(err, &providersErr) {
+		t.Fatalf("ExtractCalendarEvents() error = %v, want AIProvidersError", err)
+	}
+	if len(providersErr.Failures) != 2 {
+		t.Errorf("got %d failures, want 2", len(providersErr.Failures))
+	}
+	for _, want := range []string{"OpenAI: bad request", "DeepSeek: unauthorized"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err.Error(), want)
+		}
+	}
+}
+
+func TestCircuitBreakerSkipsFailingProvider(t *testing.T) {
+	openAI := &fakeAI{provider: ai.ProviderOpenAI, err: rateLimited}
+	deepSeek := &fakeAI{provider: ai.ProviderDeepSeek, events: []model.Event{{Title: "Concert"}}}
+	s := newTestAIService(service.CircuitBreakerConfig{FailureThreshold: 2, Cooldown: time.Hour}, openAI, deepSeek)
+
+	for i := 0; i < 5; i++ {
+		if _, err := extract(s); err != nil {
+			t.Fatalf("ExtractCalendarEvents() error = %v", err)
+		}
+	}
+
+	if openAI.calls != 2 {
+		t.Errorf("OpenAI was called %d times, want 2", openAI.calls)
+	}
+	if deepSeek.calls != 5 {
+		t.Errorf("DeepSeek was called %d times, want 5", deepSeek.calls)
+	}
+}
+
+func TestCircuitBreakerRetriesProviderAfterCooldown(t *testing.T) {
+	openAI := &fakeAI{provider: ai.ProviderOpenAI, err: rateLimited}
+	s := newTestAIService(service.CircuitBreakerConfig{FailureThreshold: 1, Cooldown: 20 * time.Millisecond}, openAI)
+
+	extract(s)
+	_, err := extract(s)
+	if !errors.Is(err, service.ErrCircuitOpen) {
+		t.Fatalf("ExtractCalendarEvents() error = %v, want the circuit to be open", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	openAI.err = nil
+	openAI.events = []model.Event{{Title: "Concert"}}
+
+	if _, err := extract(s); err != nil {
+		t.Errorf("ExtractCalendarEvents() after cooldown error = %v", err)
+	}
+	if openAI.calls != 2 {
+		t.Errorf("OpenAI was called %d times, want 2", openAI.calls)
+	}
+}
+
+func TestCircuitBreakerCountsOnlyProviderFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      model.Error
+		wantOpen bool
+	}{
+		{name: "bad request", err: badRequest, wantOpen: false},
+		{name: "unauthorized", err: ai.ApiError{Message: "unauthorized", ResponseCode: 401}, wantOpen: false},
+		{name: "rate limited", err: rateLimited, wantOpen: true},
+		{name: "bad gateway", err: ai.ApiError{Message: "bad gateway", ResponseCode: 502}, wantOpen: true},
+		{name: "transport error", err: &url.Error{Op: "Post", URL: "https://api.openai.com", Err: syscall.ECONNRESET}, wantOpen: true},
+		{name: "timeout", err: fmt.Errorf("request timed out: %w", context.DeadlineExceeded), wantOpen: true},
+		{name: "unreadable reply", err: &json.SyntaxError{Offset: 1}, wantOpen: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openAI := &fakeAI{provider: ai.ProviderOpenAI, err: tt.err}
+			s := newTestAIService(service.CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Hour}, openAI)
+
+			extract(s)
+			_, err := extract(s)
+
+			if open := errors.Is(err, service.ErrCircuitOpen); open != tt.wantOpen {
+				t.Errorf("circuit open = %v, want %v (error = %v)", open, tt.wantOpen, err)
+			}
+		})
+	}
+}
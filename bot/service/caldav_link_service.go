@@ -0,0 +1,61 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"time"
+
+	"github.com/ivgag/schedulr/storage"
+)
+
+// calDAVLinkTimeout bounds how long the bot waits for the next answer,
+// so that a forgotten conversation does not swallow later messages.
+const calDAVLinkTimeout = 5 * time.Minute
+
+func NewCalDAVLinkService(linkRepository storage.CalDAVLinkRepository) *CalDAVLinkService {
+	return &CalDAVLinkService{linkRepository: linkRepository}
+}
+
+// CalDAVLinkService keeps the /linkcaldav conversations in progress, which
+// collect the server and credentials of a CalDAV account one answer at a time.
+type CalDAVLinkService struct {
+	linkRepository storage.CalDAVLinkRepository
+}
+
+// Start begins a conversation in the chat, replacing the one in progress.
+func (s *CalDAVLinkService) Start(chatID int64) error {
+	return s.Update(storage.CalDAVLink{ChatID: chatID, Step: storage.CalDAVStepServer})
+}
+
+// Get returns the conversation in progress in the chat, or a NotFoundError.
+func (s *CalDAVLinkService) Get(chatID int64) (storage.CalDAVLink, error) {
+	return s.linkRepository.GetByChatID(chatID)
+}
+
+// Update saves the answers collected so far and waits for the next one.
+func (s *CalDAVLinkService) Update(link storage.CalDAVLink) error {
+	link.ExpiresAt = time.Now().Add(calDAVLinkTimeout)
+	return s.linkRepository.Save(link)
+}
+
+// Stop ends the conversation in the chat and reports whether one was in progress.
+func (s *CalDAVLinkService) Stop(chatID int64) (bool, error) {
+	return s.linkRepository.Delete(chatID)
+}
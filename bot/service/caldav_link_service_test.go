@@ -0,0 +1,97 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/service"
+	"github.com/ivgag/schedulr/storage"
+)
+
+func TestCalDAVLinkService(t *testing.T) {
+	links := &fakeCalDAVLinks{}
+	calDAVLinks := service.NewCalDAVLinkService(links)
+
+	if err := calDAVLinks.Start(eventTelegramID); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	link, err := calDAVLinks.Get(eventTelegramID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if link.Step != storage.CalDAVStepServer {
+		t.Errorf("Step = %v, want %v", link.Step, storage.CalDAVStepServer)
+	}
+	if wait := time.Until(link.ExpiresAt); wait < 4*time.Minute || wait > 5*time.Minute {
+		t.Errorf("ExpiresAt is %v from now, want about 5m", wait)
+	}
+
+	link.ServerURL = "https://caldav.example.com"
+	link.Step = storage.CalDAVStepUsername
+	if err := calDAVLinks.Update(link); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if got, _ := calDAVLinks.Get(eventTelegramID); got.ServerURL != link.ServerURL || got.Step != link.Step {
+		t.Errorf("Get() = %+v, want the updated conversation", got)
+	}
+
+	if stopped, err := calDAVLinks.Stop(eventTelegramID); err != nil || !stopped {
+		t.Fatalf("Stop() = %v, %v, want true", stopped, err)
+	}
+	if stopped, err := calDAVLinks.Stop(eventTelegramID); err != nil || stopped {
+		t.Errorf("second Stop() = %v, %v, want false", stopped, err)
+	}
+
+	var notFound model.NotFoundError
+	if _, err := calDAVLinks.Get(eventTelegramID); !errors.As(err, &notFound) {
+		t.Errorf("Get() after Stop() error = %v, want NotFoundError", err)
+	}
+}
+
+// fakeCalDAVLinks keeps the conversations in memory and forgets expired ones.
+type fakeCalDAVLinks struct {
+	links map[int64]storage.CalDAVLink
+}
+
+func (r *fakeCalDAVLinks) Save(link storage.CalDAVLink) error {
+	if r.links == nil {
+		r.links = make(map[int64]storage.CalDAVLink)
+	}
+	r.links[link.ChatID] = link
+	return nil
+}
+
+func (r *fakeCalDAVLinks) GetByChatID(chatID int64) (storage.CalDAVLink, error) {
+	link, ok := r.links[chatID]
+	if !ok || time.Now().After(link.ExpiresAt) {
+		return storage.CalDAVLink{}, model.NotFoundError{Message: "no CalDAV link in progress"}
+	}
+	return link, nil
+}
+
+func (r *fakeCalDAVLinks) Delete(chatID int64) (bool, error) {
+	link, ok := r.links[chatID]
+	delete(r.links, chatID)
+	return ok && time.Now().Before(link.ExpiresAt), nil
+}
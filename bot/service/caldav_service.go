@@ -0,0 +1,707 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cenkalti/backoff/v5"
+	"github.com/gofrs/uuid"
+	"github.com/ivgag/schedulr/ical"
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/storage"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultCalDAVTimeout = 20 * time.Second
+	maxCalDAVRedirects   = 5
+)
+
+var (
+	ErrCalDAVUnauthorized = model.ErrorForMessage("the CalDAV server rejected the credentials")
+	ErrCalDAVNoCalendar   = model.ErrorForMessage("no calendar for events was found on the CalDAV server")
+	ErrCalDAVForbiddenURL = model.ErrorForMessage("the CalDAV server address is not allowed")
+)
+
+// CalDAVError is an unexpected response of a CalDAV server.
+type CalDAVError struct {
+	StatusCode int
+	Method     string
+	URL        string
+}
+
+func (e *CalDAVError) Error() string {
+	return fmt.Sprintf("CalDAV %s %s failed with status %d", e.Method, e.URL, e.StatusCode)
+}
+
+// NewCalDAVCalendarService creates a CalendarService for CalDAV servers such as
+// Yandex, Nextcloud, Fastmail or iCloud.
+func NewCalDAVCalendarService(
+	config CalDAVConfig,
+	linkedAccountsRepository storage.LinkedAccountRepository,
+) *CalDAVCalendarService {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultCalDAVTimeout
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	if !config.AllowLocalServers {
+		// The address is checked after DNS resolution, so that a public name
+		// cannot point the bot to an internal service.
+		dialer.Control = func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return ErrCalDAVForbiddenURL
+			}
+			return nil
+		}
+	}
+
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: timeout},
+		// Redirects are followed by hand, as the client would turn a redirected PROPFIND into a GET.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return &CalDAVCalendarService{
+		config:                   config,
+		client:                   client,
+		linkedAccountsRepository: linkedAccountsRepository,
+	}
+}
+
+// CalDAVCalendarService creates events as iCalendar objects in the user's CalDAV calendar.
+// The linked account keeps the calendar home URL, the username and the password.
+type CalDAVCalendarService struct {
+	config                   CalDAVConfig
+	client                   *http.Client
+	linkedAccountsRepository storage.LinkedAccountRepository
+}
+
+// LinkAccount checks the credentials by discovering the user's calendar home and stores them.
+func (c *CalDAVCalendarService) LinkAccount(userID int, serverURL string, username string, password string) error {
+	base, err := c.parseServerURL(serverURL)
+	if err != nil {
+		return err
+	}
+
+	account := storage.LinkedAccount{
+		UserID:      userID,
+		Provider:    model.ProviderCalDAV,
+		AccessToken: password,
+		Username:    username,
+	}
+
+	home, err := c.discoverCalendarHome(&account, base)
+	if err != nil {
+		return err
+	}
+	account.ServerURL = home
+
+	if _, err := c.defaultCalendar(&account); err != nil {
+		return err
+	}
+
+	return c.linkedAccountsRepository.Save(account)
+}
+
+// ListCalendars implements CalendarService. The calendar IDs are the URLs of the calendar collections.
+func (c *CalDAVCalendarService) ListCalendars(userID int) ([]model.Calendar, error) {
+	account, err := c.account(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	calendars, err := c.listCalendars(&account)
+	if err != nil {
+		return nil, c.checkAuthError(userID, err)
+	}
+	return calendars, nil
+}
+
+// CreateEvent implements CalendarService.
+func (c *CalDAVCalendarService) CreateEvent(userID int, calendarURL string, event *model.Event) (model.ScheduledEvent, error) {
+	account, err := c.account(userID)
+	if err != nil {
+		return model.ScheduledEvent{}, err
+	}
+
+	if calendarURL == "" {
+		if calendarURL, err = c.defaultCalendar(&account); err != nil {
+			return model.ScheduledEvent{}, c.checkAuthError(userID, err)
+		}
+	} else if !sameServer(calendarURL, account.ServerURL) {
+		// The credentials are never sent anywhere but the server they were given for.
+		return model.ScheduledEvent{}, ErrCalDAVForbiddenURL
+	}
+
+	uid, err := uuid.NewV4()
+	if err != nil {
+		return model.ScheduledEvent{}, err
+	}
+
+	eventID := uid.String() + "@schedulr"
+	// If-None-Match makes sure an existing object is never overwritten.
+	if err := c.putEvent(&account, calendarURL, eventID, event, map[string]string{"If-None-Match": "*"}); err != nil {
+		log.Error().
+			Str("calendarURL", calendarURL).
+			Err(err).
+			Msg("Failed to create event")
+
+		return model.ScheduledEvent{}, c.checkAuthError(userID, err)
+	}
+
+	return toCalDAVScheduledEvent(eventID, calendarURL, event), nil
+}
+
+// UpdateEvent implements CalendarService. The whole object is replaced, keeping its UID.
+func (c *CalDAVCalendarService) UpdateEvent(
+	userID int,
+	calendarID string,
+	eventID string,
+	event *model.Event,
+) (model.ScheduledEvent, error) {
+	account, err := c.account(userID)
+	if err != nil {
+		return model.ScheduledEvent{}, err
+	}
+
+	if err := c.putEvent(&account, calendarID, eventID, event, nil); err != nil {
+		log.Error().
+			Str("eventID", eventID).
+			Err(err).
+			Msg("Failed to update event")
+
+		return model.ScheduledEvent{}, c.checkAuthError(userID, err)
+	}
+
+	return toCalDAVScheduledEvent(eventID, calendarID, event), nil
+}
+
+// DeleteEvent implements CalendarService. Events that are already gone are not an error.
+func (c *CalDAVCalendarService) DeleteEvent(userID int, calendarID string, eventID string) error {
+	account, err := c.account(userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.do(&account, http.MethodDelete, objectURL(calendarID, eventID), nil, nil)
+	var davErr *CalDAVError
+	if errors.As(err, &davErr) && (davErr.StatusCode == http.StatusNotFound || davErr.StatusCode == http.StatusGone) {
+		return nil
+	} else if err != nil {
+		log.Error().
+			Str("eventID", eventID).
+			Err(err).
+			Msg("Failed to delete event")
+
+		return c.checkAuthError(userID, err)
+	}
+
+	return nil
+}
+
+// ListEvents implements CalendarService. The server expands recurring events
+// into their occurrences, so that every event has its own times.
+func (c *CalDAVCalendarService) ListEvents(
+	userID int,
+	calendarURL string,
+	from time.Time,
+	to time.Time,
+) ([]model.ScheduledEvent, error) {
+	account, err := c.account(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if calendarURL == "" {
+		if calendarURL, err = c.defaultCalendar(&account); err != nil {
+			return nil, c.checkAuthError(userID, err)
+		}
+	} else if !sameServer(calendarURL, account.ServerURL) {
+		return nil, ErrCalDAVForbiddenURL
+	}
+
+	headers := map[string]string{
+		"Depth":        "1",
+		"Content-Type": "application/xml; charset=utf-8",
+	}
+	query := fmt.Sprintf(
+		eventsQuery,
+		from.UTC().Format(calDAVTimeFormat), to.UTC().Format(calDAVTimeFormat),
+		from.UTC().Format(calDAVTimeFormat), to.UTC().Format(calDAVTimeFormat),
+	)
+	resp, err := c.do(&account, "REPORT", calendarURL, headers, []byte(query))
+	if err != nil {
+		log.Error().
+			Str("calendarURL", calendarURL).
+			Err(err).
+			Msg("Failed to list events")
+
+		return nil, c.checkAuthError(userID, err)
+	}
+
+	var multistatus davMultistatus
+	if err := xml.Unmarshal(resp.body, &multistatus); err != nil {
+		return nil, err
+	}
+
+	var events []model.ScheduledEvent
+	for _, response := range multistatus.Responses {
+		prop, ok := response.prop()
+		if !ok || prop.CalendarData == "" {
+			continue
+		}
+
+		decoded, err := ical.Decode([]byte(prop.CalendarData))
+		if err != nil {
+			log.Warn().
+				Str("href", response.Href).
+				Err(err).
+				Msg("Skipping unreadable calendar object")
+			continue
+		}
+		for _, event := range decoded {
+			events = append(events, toCalDAVScheduledEvent(event.UID, calendarURL, &event.Event))
+		}
+	}
+	return events, nil
+}
+
+func (c *CalDAVCalendarService) account(userID int) (storage.LinkedAccount, error) {
+	account, err := c.linkedAccountsRepository.GetByUserIDAndProvider(userID, model.ProviderCalDAV)
+	if err != nil {
+		return storage.LinkedAccount{}, err
+	} else if account.Status == storage.LinkedAccountBroken {
+		return storage.LinkedAccount{}, model.RelinkRequiredError{Provider: model.ProviderCalDAV, Reason: account.StatusReason}
+	}
+	return account, nil
+}
+
+// checkAuthError marks the account as broken if the server no longer accepts its credentials.
+func (c *CalDAVCalendarService) checkAuthError(userID int, err error) error {
+	if !errors.Is(err, ErrCalDAVUnauthorized) {
+		return err
+	}
+
+	reason := "credentials rejected"
+	updateErr := c.linkedAccountsRepository.UpdateStatus(userID, model.ProviderCalDAV, storage.LinkedAccountBroken, reason)
+	if updateErr != nil {
+		log.Error().
+			Int("userID", userID).
+			Err(updateErr).
+			Msg("Failed to mark the linked account as broken")
+	}
+
+	return model.RelinkRequiredError{Provider: model.ProviderCalDAV, Reason: reason}
+}
+
+func (c *CalDAVCalendarService) putEvent(
+	account *storage.LinkedAccount,
+	calendarURL string,
+	eventID string,
+	event *model.Event,
+	headers map[string]string,
+) error {
+	data, err := ical.Encode(ical.Event{UID: eventID, Stamp: time.Now(), Event: *event})
+	if err != nil {
+		return err
+	}
+
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	headers["Content-Type"] = "text/calendar; charset=utf-8"
+
+	_, err = c.do(account, http.MethodPut, objectURL(calendarURL, eventID), headers, data)
+	return err
+}
+
+// discoverCalendarHome finds the calendar home of the user through the current user
+// principal (RFC 5397) and its calendar-home-set (RFC 4791), trying the well-known
+// URL (RFC 6764) if the given one does not answer.
+func (c *CalDAVCalendarService) discoverCalendarHome(account *storage.LinkedAccount, base *url.URL) (string, error) {
+	candidates := []string{base.String()}
+	if base.Path == "" || base.Path == "/" {
+		candidates = append([]string{base.ResolveReference(&url.URL{Path: "/.well-known/caldav"}).String()}, candidates...)
+	}
+
+	var lastErr error = ErrCalDAVNoCalendar
+	for _, candidate := range candidates {
+		responses, location, err := c.propfind(account, candidate, "0", principalPropfind)
+		if errors.Is(err, ErrCalDAVUnauthorized) || errors.Is(err, ErrCalDAVForbiddenURL) {
+			return "", err
+		} else if err != nil {
+			lastErr = err
+			continue
+		}
+
+		prop := firstProp(responses)
+		if href := prop.CalendarHomeSet.Href; href != "" {
+			return resolveHref(location, href)
+		}
+		if href := prop.CurrentUserPrincipal.Href; href != "" {
+			principal, err := resolveHref(location, href)
+			if err != nil {
+				return "", err
+			}
+
+			responses, location, err := c.propfind(account, principal, "0", principalPropfind)
+			if err != nil {
+				return "", err
+			}
+			if href := firstProp(responses).CalendarHomeSet.Href; href != "" {
+				return resolveHref(location, href)
+			}
+		}
+	}
+
+	return "", lastErr
+}
+
+// defaultCalendar returns the URL of the first calendar in the home that accepts events.
+func (c *CalDAVCalendarService) defaultCalendar(account *storage.LinkedAccount) (string, error) {
+	calendars, err := c.listCalendars(account)
+	if err != nil {
+		return "", err
+	} else if len(calendars) == 0 {
+		return "", ErrCalDAVNoCalendar
+	}
+	return calendars[0].ID, nil
+}
+
+// listCalendars returns the calendars in the home that accept events. CalDAV has
+// no notion of a default calendar, so the first one is reported as primary.
+func (c *CalDAVCalendarService) listCalendars(account *storage.LinkedAccount) ([]model.Calendar, error) {
+	responses, location, err := c.propfind(account, account.ServerURL, "1", calendarsPropfind)
+	if err != nil {
+		return nil, err
+	}
+
+	var calendars []model.Calendar
+	for _, response := range responses {
+		prop, ok := response.prop()
+		if !ok || prop.ResourceType.Calendar == nil || !prop.SupportedComponents.supports("VEVENT") {
+			continue
+		}
+
+		calendarURL, err := resolveHref(location, response.Href)
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimSpace(prop.DisplayName)
+		if name == "" {
+			name = path.Base(strings.TrimSuffix(response.Href, "/"))
+		}
+		calendars = append(calendars, model.Calendar{ID: calendarURL, Name: name, Primary: len(calendars) == 0})
+	}
+	return calendars, nil
+}
+
+// propfind sends a PROPFIND and returns its responses together with the URL
+// that answered, which relative hrefs are resolved against.
+func (c *CalDAVCalendarService) propfind(
+	account *storage.LinkedAccount,
+	target string,
+	depth string,
+	body string,
+) ([]davResponse, string, error) {
+	headers := map[string]string{
+		"Depth":        depth,
+		"Content-Type": "application/xml; charset=utf-8",
+	}
+
+	resp, err := c.do(account, "PROPFIND", target, headers, []byte(body))
+	if err != nil {
+		return nil, "", err
+	}
+
+	var multistatus davMultistatus
+	if err := xml.Unmarshal(resp.body, &multistatus); err != nil {
+		return nil, "", err
+	}
+	return multistatus.Responses, resp.url, nil
+}
+
+type calDAVResponse struct {
+	url  string
+	body []byte
+}
+
+// do sends an authenticated request, following redirects and retrying
+// throttled and failed requests.
+func (c *CalDAVCalendarService) do(
+	account *storage.LinkedAccount,
+	method string,
+	target string,
+	headers map[string]string,
+	body []byte,
+) (calDAVResponse, error) {
+	operation := func() (calDAVResponse, error) {
+		for redirects := 0; redirects <= maxCalDAVRedirects; redirects++ {
+			if _, err := c.parseServerURL(target); err != nil {
+				return calDAVResponse{}, backoff.Permanent(err)
+			}
+
+			req, err := http.NewRequest(method, target, bytes.NewReader(body))
+			if err != nil {
+				return calDAVResponse{}, backoff.Permanent(err)
+			}
+			req.SetBasicAuth(account.Username, account.AccessToken)
+			for name, value := range headers {
+				req.Header.Set(name, value)
+			}
+
+			resp, err := c.client.Do(req)
+			if errors.Is(err, ErrCalDAVForbiddenURL) {
+				return calDAVResponse{}, backoff.Permanent(ErrCalDAVForbiddenURL)
+			} else if err != nil {
+				return calDAVResponse{}, err
+			}
+
+			data, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return calDAVResponse{}, err
+			}
+
+			switch {
+			case resp.StatusCode >= 300 && resp.StatusCode < 400 && resp.Header.Get("Location") != "":
+				if target, err = resolveHref(target, resp.Header.Get("Location")); err != nil {
+					return calDAVResponse{}, backoff.Permanent(err)
+				}
+				continue
+			case resp.StatusCode == http.StatusUnauthorized:
+				return calDAVResponse{}, backoff.Permanent(ErrCalDAVUnauthorized)
+			case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+				return calDAVResponse{}, &CalDAVError{StatusCode: resp.StatusCode, Method: method, URL: target}
+			case resp.StatusCode >= http.StatusBadRequest:
+				return calDAVResponse{}, backoff.Permanent(&CalDAVError{StatusCode: resp.StatusCode, Method: method, URL: target})
+			}
+
+			return calDAVResponse{url: target, body: data}, nil
+		}
+
+		return calDAVResponse{}, backoff.Permanent(fmt.Errorf("too many redirects from %s", target))
+	}
+
+	return backoff.Retry(
+		context.Background(),
+		operation,
+		backoff.WithBackOff(backoff.NewExponentialBackOff()),
+		backoff.WithMaxTries(3),
+	)
+}
+
+// parseServerURL accepts HTTPS URLs, and plain HTTP ones only where local servers are allowed.
+func (c *CalDAVCalendarService) parseServerURL(serverURL string) (*url.URL, error) {
+	parsed, err := url.Parse(strings.TrimSpace(serverURL))
+	if err != nil || parsed.Host == "" || parsed.User != nil {
+		return nil, ErrCalDAVForbiddenURL
+	}
+
+	switch parsed.Scheme {
+	case "https":
+		return parsed, nil
+	case "http":
+		if c.config.AllowLocalServers {
+			return parsed, nil
+		}
+	}
+	return nil, ErrCalDAVForbiddenURL
+}
+
+func toCalDAVScheduledEvent(eventID string, calendarURL string, event *model.Event) model.ScheduledEvent {
+	return model.ScheduledEvent{
+		ID:         eventID,
+		Provider:   model.ProviderCalDAV,
+		CalendarID: calendarURL,
+		Event:      *event,
+	}
+}
+
+// sameServer tells whether both URLs point to the same scheme, host and port.
+func sameServer(a string, b string) bool {
+	urlA, errA := url.Parse(a)
+	urlB, errB := url.Parse(b)
+	return errA == nil && errB == nil && urlA.Scheme == urlB.Scheme && urlA.Host == urlB.Host
+}
+
+// objectURL returns the URL of the event's object in the calendar collection.
+func objectURL(calendarURL string, eventID string) string {
+	if !strings.HasSuffix(calendarURL, "/") {
+		calendarURL += "/"
+	}
+	return calendarURL + url.PathEscape(eventID) + ".ics"
+}
+
+func resolveHref(base string, href string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", err
+	}
+	return baseURL.ResolveReference(ref).String(), nil
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsMulticast()
+}
+
+const principalPropfind = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop>
+    <d:current-user-principal/>
+    <c:calendar-home-set/>
+  </d:prop>
+</d:propfind>`
+
+const calendarsPropfind = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop>
+    <d:resourcetype/>
+    <d:displayname/>
+    <c:supported-calendar-component-set/>
+  </d:prop>
+</d:propfind>`
+
+// calDAVTimeFormat is the UTC date-time format of CalDAV time ranges.
+const calDAVTimeFormat = "20060102T150405Z"
+
+// eventsQuery is a calendar-query (RFC 4791) for the events in a time range,
+// with recurring ones expanded within the same range.
+const eventsQuery = `<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop>
+    <c:calendar-data>
+      <c:expand start="%s" end="%s"/>
+    </c:calendar-data>
+  </d:prop>
+  <c:filter>
+    <c:comp-filter name="VCALENDAR">
+      <c:comp-filter name="VEVENT">
+        <c:time-range start="%s" end="%s"/>
+      </c:comp-filter>
+    </c:comp-filter>
+  </c:filter>
+</c:calendar-query>`
+
+type davMultistatus struct {
+	Responses []davResponse `xml:"DAV: response"`
+}
+
+type davResponse struct {
+	Href      string        `xml:"DAV: href"`
+	Propstats []davPropstat `xml:"DAV: propstat"`
+}
+
+// prop returns the properties the server found for the resource.
+func (r davResponse) prop() (davProp, bool) {
+	for _, propstat := range r.Propstats {
+		if strings.Contains(propstat.Status, " 200 ") {
+			return propstat.Prop, true
+		}
+	}
+	return davProp{}, false
+}
+
+func firstProp(responses []davResponse) davProp {
+	for _, response := range responses {
+		if prop, ok := response.prop(); ok {
+			return prop
+		}
+	}
+	return davProp{}
+}
+
+type davPropstat struct {
+	Prop   davProp `xml:"DAV: prop"`
+	Status string  `xml:"DAV: status"`
+}
+
+type davProp struct {
+	CurrentUserPrincipal davHref         `xml:"DAV: current-user-principal"`
+	CalendarHomeSet      davHref         `xml:"urn:ietf:params:xml:ns:caldav calendar-home-set"`
+	ResourceType         davResourceType `xml:"DAV: resourcetype"`
+	DisplayName          string          `xml:"DAV: displayname"`
+	SupportedComponents  davComponentSet `xml:"urn:ietf:params:xml:ns:caldav supported-calendar-component-set"`
+	CalendarData         string          `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+}
+
+type davHref struct {
+	Href string `xml:"DAV: href"`
+}
+
+type davResourceType struct {
+	Calendar *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+}
+
+type davComponentSet struct {
+	Components []struct {
+		Name string `xml:"name,attr"`
+	} `xml:"urn:ietf:params:xml:ns:caldav comp"`
+}
+
+// supports tells whether the calendar accepts the component. Servers that
+// do not report the set accept every component.
+func (s davComponentSet) supports(name string) bool {
+	if len(s.Components) == 0 {
+		return true
+	}
+	for _, component := range s.Components {
+		if strings.EqualFold(component.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+type CalDAVConfig struct {
+	// AllowLocalServers lets users link servers on private networks and over plain HTTP,
+	// e.g. a Radicale for development. Keep it off on public instances, as the bot
+	// connects to any address users send.
+	AllowLocalServers bool          `mapstructure:"allow_local_servers"`
+	Timeout           time.Duration `mapstructure:"timeout"`
+}
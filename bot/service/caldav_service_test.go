@@ -0,0 +1,380 @@
+package service_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/service"
+	"github.com/ivgag/schedulr/storage"
+)
+
+const (
+	calDAVUsername = "alice"
+	calDAVPassword = "app-password"
+)
+
+type fakeCalDAV struct {
+	mu      sync.Mutex
+	server  *httptest.Server
+	objects map[string]string
+	headers map[string]http.Header
+}
+
+func newFakeCalDAV(t *testing.T) *fakeCalDAV {
+	dav := &fakeCalDAV{objects: make(map[string]string), headers: make(map[string]http.Header)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/caldav", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/dav/", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("PROPFIND /dav/{$}", func(w http.ResponseWriter, r *http.Request) {
+		writeMultistatus(w, `<d:response><d:href>/dav/</d:href><d:propstat><d:prop>
+			<d:current-user-principal><d:href>/dav/principals/alice/</d:href></d:current-user-principal>
+			</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`)
+	})
+	mux.HandleFunc("PROPFIND /dav/principals/alice/", func(w http.ResponseWriter, r *http.Request) {
+		writeMultistatus(w, `<d:response><d:href>/dav/principals/alice/</d:href><d:propstat><d:prop>
+			<c:calendar-home-set><d:href>/dav/calendars/alice/</d:href></c:calendar-home-set>
+			</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`)
+	})
+	mux.HandleFunc("PROPFIND /dav/calendars/alice/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Depth") != "1" {
+			t.Errorf("calendars PROPFIND Depth = %q, want 1", r.Header.Get("Depth"))
+		}
+		writeMultistatus(w, `
+			<d:response><d:href>/dav/calendars/alice/</d:href><d:propstat><d:prop>
+				<d:resourcetype><d:collection/></d:resourcetype>
+			</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+			<d:response><d:href>/dav/calendars/alice/tasks/</d:href><d:propstat><d:prop>
+				<d:resourcetype><d:collection/><c:calendar/></d:resourcetype>
+				<c:supported-calendar-component-set><c:comp name="VTODO"/></c:supported-calendar-component-set>
+			</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+			<d:response><d:href>/dav/calendars/alice/personal/</d:href><d:propstat><d:prop>
+				<d:resourcetype><d:collection/><c:calendar/></d:resourcetype>
+				<d:displayname>Personal</d:displayname>
+				<c:supported-calendar-component-set><c:comp name="VEVENT"/></c:supported-calendar-component-set>
+			</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+			<d:response><d:href>/dav/calendars/alice/family/</d:href><d:propstat><d:prop>
+				<d:resourcetype><d:collection/><c:calendar/></d:resourcetype>
+			</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`)
+	})
+	mux.HandleFunc("PUT /dav/calendars/alice/{calendar}/{object}", func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read body: %v", err)
+		}
+		name := r.PathValue("calendar") + "/" + r.PathValue("object")
+		dav.mu.Lock()
+		defer dav.mu.Unlock()
+		dav.objects[name] = string(data)
+		dav.headers[name] = r.Header.Clone()
+		w.WriteHeader(http.StatusCreated)
+	})
+	// REPORT answers every calendar-query with all objects of the calendar.
+	mux.HandleFunc("REPORT /dav/calendars/alice/{calendar}/", func(w http.ResponseWriter, r *http.Request) {
+		prefix := r.PathValue("calendar") + "/"
+		var responses strings.Builder
+		dav.mu.Lock()
+		for name, object := range dav.objects {
+			if strings.HasPrefix(name, prefix) {
+				fmt.Fprintf(&responses, `<d:response><d:href>/dav/calendars/alice/%s</d:href><d:propstat><d:prop>
+					<c:calendar-data><![CDATA[%s]]></c:calendar-data>
+					</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, name, object)
+			}
+		}
+		dav.mu.Unlock()
+		writeMultistatus(w, responses.String())
+	})
+	mux.HandleFunc("DELETE /dav/calendars/alice/{calendar}/{object}", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("calendar") + "/" + r.PathValue("object")
+		dav.mu.Lock()
+		defer dav.mu.Unlock()
+		if _, ok := dav.objects[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(dav.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	dav.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != calDAVUsername || password != calDAVPassword {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(dav.server.Close)
+	return dav
+}
+
+func (d *fakeCalDAV) object(name string) (string, http.Header, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	object, ok := d.objects[name]
+	return object, d.headers[name], ok
+}
+
+func writeMultistatus(w http.ResponseWriter, responses string) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">%s</d:multistatus>`, responses)
+}
+
+func newCalDAVService(config service.CalDAVConfig) (*service.CalDAVCalendarService, *fakeLinkedAccounts) {
+	accounts := &fakeLinkedAccounts{accounts: make(map[model.Provider]storage.LinkedAccount)}
+	return service.NewCalDAVCalendarService(config, accounts), accounts
+}
+
+func TestCalDAVLinkAccount(t *testing.T) {
+	dav := newFakeCalDAV(t)
+
+	tests := []struct {
+		name     string
+		config   service.CalDAVConfig
+		url      string
+		password string
+		wantErr  error
+		wantHome string
+	}{
+		{
+			name:     "discovers the calendar home through the well-known URL",
+			config:   service.CalDAVConfig{AllowLocalServers: true},
+			url:      dav.server.URL,
+			password: calDAVPassword,
+			wantHome: dav.server.URL + "/dav/calendars/alice/",
+		},
+		{
+			name:     "rejects wrong credentials",
+			config:   service.CalDAVConfig{AllowLocalServers: true},
+			url:      dav.server.URL,
+			password: "wrong",
+			wantErr:  service.ErrCalDAVUnauthorized,
+		},
+		{
+			name:     "rejects local servers by default",
+			url:      dav.server.URL,
+			password: calDAVPassword,
+			wantErr:  service.ErrCalDAVForbiddenURL,
+		},
+		{
+			name:     "rejects local HTTPS servers by default",
+			url:      strings.Replace(dav.server.URL, "http://", "https://", 1),
+			password: calDAVPassword,
+			wantErr:  service.ErrCalDAVForbiddenURL,
+		},
+		{
+			name:     "rejects other schemes",
+			config:   service.CalDAVConfig{AllowLocalServers: true},
+			url:      "ftp://caldav.example.com",
+			password: calDAVPassword,
+			wantErr:  service.ErrCalDAVForbiddenURL,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calDAVService, accounts := newCalDAVService(tt.config)
+
+			err := calDAVService.LinkAccount(1, tt.url, calDAVUsername, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("LinkAccount() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			account, err := accounts.GetByUserIDAndProvider(1, model.ProviderCalDAV)
+			if err != nil {
+				t.Fatalf("account not saved: %v", err)
+			}
+			if account.ServerURL != tt.wantHome {
+				t.Errorf("ServerURL = %q, want %q", account.ServerURL, tt.wantHome)
+			}
+			if account.Username != calDAVUsername || account.AccessToken != calDAVPassword {
+				t.Errorf("credentials = %q/%q, want %q/%q", account.Username, account.AccessToken, calDAVUsername, calDAVPassword)
+			}
+		})
+	}
+}
+
+func TestCalDAVEventLifecycle(t *testing.T) {
+	dav := newFakeCalDAV(t)
+	calDAVService, _ := newCalDAVService(service.CalDAVConfig{AllowLocalServers: true})
+	if err := calDAVService.LinkAccount(1, dav.server.URL, calDAVUsername, calDAVPassword); err != nil {
+		t.Fatalf("LinkAccount() error = %v", err)
+	}
+
+	event := &model.Event{
+		Title:    "Dentist",
+		Start:    time.Date(2026, 10, 20, 9, 30, 0, 0, time.UTC),
+		End:      time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC),
+		TimeZone: "Europe/Berlin",
+	}
+
+	scheduled, err := calDAVService.CreateEvent(1, "", event)
+	if err != nil {
+		t.Fatalf("CreateEvent() error = %v", err)
+	}
+	if want := dav.server.URL + "/dav/calendars/alice/personal/"; scheduled.CalendarID != want {
+		t.Errorf("CalendarID = %q, want %q", scheduled.CalendarID, want)
+	}
+	if scheduled.Provider != model.ProviderCalDAV {
+		t.Errorf("Provider = %q, want %q", scheduled.Provider, model.ProviderCalDAV)
+	}
+
+	name := "personal/" + scheduled.ID + ".ics"
+	object, headers, ok := dav.object(name)
+	if !ok {
+		t.Fatalf("object %q not stored", name)
+	}
+	for _, want := range []string{"UID:" + scheduled.ID, "SUMMARY:Dentist", "DTSTART;TZID=Europe/Berlin:20261020T093000"} {
+		if !strings.Contains(object, want) {
+			t.Errorf("object does not contain %q:\n%s", want, object)
+		}
+	}
+	if headers.Get("If-None-Match") != "*" {
+		t.Errorf("If-None-Match = %q, want *", headers.Get("If-None-Match"))
+	}
+
+	listed, err := calDAVService.ListEvents(1, "", event.Start.AddDate(0, 0, -1), event.End.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("ListEvents() error = %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != scheduled.ID || !reflect.DeepEqual(listed[0].Event, *event) {
+		t.Errorf("ListEvents() = %+v, want the created event", listed)
+	}
+
+	event.Title = "Dentist (moved)"
+	if _, err := calDAVService.UpdateEvent(1, scheduled.CalendarID, scheduled.ID, event); err != nil {
+		t.Fatalf("UpdateEvent() error = %v", err)
+	}
+	if object, _, _ := dav.object(name); !strings.Contains(object, "SUMMARY:Dentist (moved)") {
+		t.Errorf("object not updated:\n%s", object)
+	}
+
+	if err := calDAVService.DeleteEvent(1, scheduled.CalendarID, scheduled.ID); err != nil {
+		t.Fatalf("DeleteEvent() error = %v", err)
+	}
+	if _, _, ok := dav.object(name); ok {
+		t.Errorf("object %q not deleted", name)
+	}
+	if err := calDAVService.DeleteEvent(1, scheduled.CalendarID, scheduled.ID); err != nil {
+		t.Errorf("DeleteEvent() of a missing event error = %v, want nil", err)
+	}
+}
+
+func TestCalDAVCalendars(t *testing.T) {
+	dav := newFakeCalDAV(t)
+	calDAVService, _ := newCalDAVService(service.CalDAVConfig{AllowLocalServers: true})
+	if err := calDAVService.LinkAccount(1, dav.server.URL, calDAVUsername, calDAVPassword); err != nil {
+		t.Fatalf("LinkAccount() error = %v", err)
+	}
+
+	calendars, err := calDAVService.ListCalendars(1)
+	if err != nil {
+		t.Fatalf("ListCalendars() error = %v", err)
+	}
+	want := []model.Calendar{
+		{ID: dav.server.URL + "/dav/calendars/alice/personal/", Name: "Personal", Primary: true},
+		{ID: dav.server.URL + "/dav/calendars/alice/family/", Name: "family"},
+	}
+	if !reflect.DeepEqual(calendars, want) {
+		t.Fatalf("ListCalendars() = %+v, want %+v", calendars, want)
+	}
+
+	event := &model.Event{
+		Title:  "Grandma's birthday",
+		Start:  time.Date(2026, 5, 12, 0, 0, 0, 0, time.UTC),
+		End:    time.Date(2026, 5, 12, 0, 0, 0, 0, time.UTC),
+		AllDay: true,
+	}
+	scheduled, err := calDAVService.CreateEvent(1, calendars[1].ID, event)
+	if err != nil {
+		t.Fatalf("CreateEvent() error = %v", err)
+	}
+	if _, _, ok := dav.object("family/" + scheduled.ID + ".ics"); !ok {
+		t.Errorf("event not stored in the family calendar")
+	}
+
+	// The password must not be sent to another server.
+	_, err = calDAVService.CreateEvent(1, "https://attacker.example/calendar/", event)
+	if !errors.Is(err, service.ErrCalDAVForbiddenURL) {
+		t.Errorf("CreateEvent() on another server error = %v, want %v", err, service.ErrCalDAVForbiddenURL)
+	}
+}
+
+func TestCalDAVRevokedPassword(t *testing.T) {
+	dav := newFakeCalDAV(t)
+	calDAVService, accounts := newCalDAVService(service.CalDAVConfig{AllowLocalServers: true})
+	if err := calDAVService.LinkAccount(1, dav.server.URL, calDAVUsername, calDAVPassword); err != nil {
+		t.Fatalf("LinkAccount() error = %v", err)
+	}
+
+	account, _ := accounts.GetByUserIDAndProvider(1, model.ProviderCalDAV)
+	account.AccessToken = "revoked"
+	accounts.accounts[model.ProviderCalDAV] = account
+
+	event := &model.Event{
+		Title: "Standup",
+		Start: time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC),
+		End:   time.Date(2026, 10, 20, 9, 15, 0, 0, time.UTC),
+	}
+	_, err := calDAVService.CreateEvent(1, "", event)
+
+	var relinkErr model.RelinkRequiredError
+	if !errors.As(err, &relinkErr) || relinkErr.Provider != model.ProviderCalDAV {
+		t.Fatalf("CreateEvent() error = %v, want RelinkRequiredError", err)
+	}
+	if account, _ := accounts.GetByUserIDAndProvider(1, model.ProviderCalDAV); account.Status != storage.LinkedAccountBroken {
+		t.Errorf("Status = %q, want %q", account.Status, storage.LinkedAccountBroken)
+	}
+}
+
+// TestCalDAVServer runs against a real server, such as Radicale:
+//
+//	CALDAV_TEST_URL=http://localhost:5232 CALDAV_TEST_USERNAME=alice CALDAV_TEST_PASSWORD=secret go test ./...
+func TestCalDAVServer(t *testing.T) {
+	serverURL := os.Getenv("CALDAV_TEST_URL")
+	if serverURL == "" {
+		t.Skip("CALDAV_TEST_URL is not set")
+	}
+
+	calDAVService, _ := newCalDAVService(service.CalDAVConfig{AllowLocalServers: true})
+	err := calDAVService.LinkAccount(1, serverURL, os.Getenv("CALDAV_TEST_USERNAME"), os.Getenv("CALDAV_TEST_PASSWORD"))
+	if err != nil {
+		t.Fatalf("LinkAccount() error = %v", err)
+	}
+
+	event := &model.Event{
+		Title:    "schedulr integration test",
+		Start:    time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC),
+		End:      time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC),
+		TimeZone: "Europe/Berlin",
+	}
+	scheduled, err := calDAVService.CreateEvent(1, "", event)
+	if err != nil {
+		t.Fatalf("CreateEvent() error = %v", err)
+	}
+	t.Cleanup(func() {
+		if err := calDAVService.DeleteEvent(1, scheduled.CalendarID, scheduled.ID); err != nil {
+			t.Errorf("DeleteEvent() error = %v", err)
+		}
+	})
+
+	event.Title = "schedulr integration test (updated)"
+	if _, err := calDAVService.UpdateEvent(1, scheduled.CalendarID, scheduled.ID, event); err != nil {
+		t.Fatalf("UpdateEvent() error = %v", err)
+	}
+}
@@ -19,8 +19,22 @@
 
 package service
 
-import "github.com/ivgag/schedulr/model"
+import (
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+)
 
 type CalendarService interface {
-	CreateEvent(userID int, event *model.Event) (model.ScheduledEvent, error)
+	// ListCalendars returns the calendars of the user that events can be created in.
+	ListCalendars(userID int) ([]model.Calendar, error)
+	// CreateEvent creates the event in the given calendar, or in the user's
+	// default calendar if calendarID is empty.
+	CreateEvent(userID int, calendarID string, event *model.Event) (model.ScheduledEvent, error)
+	UpdateEvent(userID int, calendarID string, eventID string, event *model.Event) (model.ScheduledEvent, error)
+	DeleteEvent(userID int, calendarID string, eventID string) error
+	// ListEvents returns the events of the calendar, or of the default one if calendarID
+	// is empty, that overlap the time range. Recurring events are expanded into their
+	// occurrences, and the times of timed events are given in UTC.
+	ListEvents(userID int, calendarID string, from time.Time, to time.Time) ([]model.ScheduledEvent, error)
 }
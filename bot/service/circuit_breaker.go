@@ -0,0 +1,116 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ivgag/schedulr/ai"
+)
+
+const (
+	defaultFailureThreshold = 3
+	defaultCooldown         = time.Minute
+)
+
+// circuitBreaker stops calling a provider after several consecutive failures
+// and lets a single trial call through once the cooldown has passed.
+type circuitBreaker struct {
+	failureThreshold int
+	cooldown         time.Duration
+
+	mutex       sync.Mutex
+	failures    int
+	openedUntil time.Time
+}
+
+func newCircuitBreaker(config CircuitBreakerConfig) *circuitBreaker {
+	breaker := &circuitBreaker{
+		failureThreshold: config.FailureThreshold,
+		cooldown:         config.Cooldown,
+	}
+	if breaker.failureThreshold <= 0 {
+		breaker.failureThreshold = defaultFailureThreshold
+	}
+	if breaker.cooldown <= 0 {
+		breaker.cooldown = defaultCooldown
+	}
+	return breaker
+}
+
+// Allow reports whether the provider may be called now.
+func (b *circuitBreaker) Allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.failures < b.failureThreshold {
+		return true
+	}
+	if time.Now().Before(b.openedUntil) {
+		return false
+	}
+
+	// Half-open: let one call through and keep the others out until it reports back.
+	b.openedUntil = time.Now().Add(b.cooldown)
+	return true
+}
+
+func (b *circuitBreaker) RecordSuccess() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures = 0
+	b.openedUntil = time.Time{}
+}
+
+func (b *circuitBreaker) RecordFailure() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures++
+	if b.failures >= b.failureThreshold {
+		b.openedUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// tripsBreaker reports whether the error says the provider itself is unhealthy:
+// it could not be reached, timed out, rate-limited the call or failed on its side.
+// A provider that rejects a request, e.g. with 400, or whose reply cannot be read
+// is still up and keeps the breaker closed.
+func tripsBreaker(err error) bool {
+	var apiError ai.ApiError
+	if errors.As(err, &apiError) {
+		return apiError.ResponseCode == http.StatusTooManyRequests ||
+			apiError.ResponseCode >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+type CircuitBreakerConfig struct {
+	FailureThreshold int           `mapstructure:"failure_threshold"`
+	Cooldown         time.Duration `mapstructure:"cooldown"`
+}
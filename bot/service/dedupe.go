@@ -0,0 +1,261 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/storage"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// dedupeWindow widens the range existing events are looked up in, as stored
+	// events keep wall-clock times that may be given in any time zone.
+	dedupeWindow = 24 * time.Hour
+	// duplicateTitleSimilarity is the title similarity that alone makes overlapping events duplicates.
+	duplicateTitleSimilarity = 0.8
+	// duplicatePlaceSimilarity is the title similarity that makes overlapping events
+	// at the same location duplicates.
+	duplicatePlaceSimilarity = 0.5
+)
+
+// DuplicateEventError is returned when the user's calendars seem to have the event already.
+type DuplicateEventError struct {
+	Existing model.ScheduledEvent
+}
+
+func (e DuplicateEventError) Error() string {
+	return "the event looks like a duplicate of " + e.Existing.Event.Title
+}
+
+// findDuplicate looks for the draft's event among the events the bot created for the user
+// and the events of the calendars the draft goes to, where the bot's tag matches before
+// the title does. Calendars that cannot be read are skipped, as the check must not keep
+// the user from creating the event.
+func (s *EventService) findDuplicate(draft storage.EventDraft) (model.ScheduledEvent, bool) {
+	user, err := s.userService.GetUserByID(draft.UserID)
+	if err != nil {
+		log.Error().
+			Int("draftID", draft.ID).
+			Err(err).
+			Msg("Failed to get the draft's user")
+		return model.ScheduledEvent{}, false
+	}
+
+	loc := UserLocation(user)
+	start, end := eventSpan(draft.Event, loc)
+
+	stored, err := s.scheduledEventRepository.ListByUserIDBetween(
+		user.ID, draft.Event.Start.Add(-dedupeWindow), draft.Event.End.Add(dedupeWindow),
+	)
+	if err != nil {
+		log.Error().
+			Int("draftID", draft.ID).
+			Err(err).
+			Msg("Failed to list stored events")
+	}
+	for _, event := range stored {
+		if isDuplicate(draft.Event, start, end, event.Event, loc) {
+			return model.ScheduledEvent{
+				ID:         event.ProviderEventID,
+				Provider:   event.Provider,
+				CalendarID: event.CalendarID,
+				Event:      event.Event,
+				Link:       event.Link,
+			}, true
+		}
+	}
+
+	key := EventKey(&draft.Event)
+	targets, err := s.draftTargets(draft)
+	if err != nil {
+		log.Error().
+			Int("draftID", draft.ID).
+			Err(err).
+			Msg("Failed to list the draft's calendars")
+		return model.ScheduledEvent{}, false
+	}
+
+	for _, target := range targets {
+		calendarService, err := s.calendarService(target.provider)
+		if err != nil {
+			continue
+		}
+
+		events, err := calendarService.ListEvents(user.ID, target.calendarID, start.Add(-dedupeWindow), end.Add(dedupeWindow))
+		if err != nil {
+			log.Warn().
+				Int("draftID", draft.ID).
+				Str("provider", string(target.provider)).
+				Err(err).
+				Msg("Failed to list calendar events")
+			continue
+		}
+
+		// The key finds the bot's events even after the user renamed them.
+		for _, event := range events {
+			if event.Key == key {
+				event.Event = inLocation(event.Event, loc)
+				return event, true
+			}
+		}
+		for _, event := range events {
+			if isDuplicate(draft.Event, start, end, event.Event, loc) {
+				event.Event = inLocation(event.Event, loc)
+				return event, true
+			}
+		}
+	}
+
+	return model.ScheduledEvent{}, false
+}
+
+// isDuplicate tells whether the existing event overlaps the new one spanning [start, end)
+// and has a similar title, or a somewhat similar one at the same location.
+func isDuplicate(event model.Event, start time.Time, end time.Time, existing model.Event, loc *time.Location) bool {
+	existingStart, existingEnd := eventSpan(existing, loc)
+	if !existingStart.Before(end) || !start.Before(existingEnd) {
+		return false
+	}
+
+	similarity := titleSimilarity(event.Title, existing.Title)
+	if similarity >= duplicateTitleSimilarity {
+		return true
+	}
+	return similarity >= duplicatePlaceSimilarity && sameLocation(event.Location, existing.Location)
+}
+
+// eventSpan returns the instants the event starts and ends at. Times without a zone
+// are read in the fallback one, and all-day events span their whole days.
+func eventSpan(event model.Event, fallback *time.Location) (time.Time, time.Time) {
+	loc := fallback
+	if event.TimeZone != "" {
+		if eventLoc, err := time.LoadLocation(event.TimeZone); err == nil {
+			loc = eventLoc
+		}
+	}
+
+	if event.AllDay {
+		start := toLocalTime(toDate(event.Start), loc)
+		end := toLocalTime(toDate(event.End), loc).AddDate(0, 0, 1)
+		if end.Before(start) {
+			end = start.AddDate(0, 0, 1)
+		}
+		return start, end
+	}
+
+	start := toLocalTime(event.Start, loc)
+	end := toLocalTime(event.End, loc)
+	// Reminders have no duration, but still occupy their moment.
+	if !end.After(start) {
+		end = start.Add(time.Minute)
+	}
+	return start, end
+}
+
+// inLocation converts the times of a timed event to the wall clock of the location.
+func inLocation(event model.Event, loc *time.Location) model.Event {
+	if event.AllDay {
+		return event
+	}
+
+	start, end := eventSpan(event, loc)
+	event.Start = toLocalTime(start.In(loc), time.UTC)
+	event.End = toLocalTime(end.In(loc), time.UTC)
+	event.TimeZone = loc.String()
+	return event
+}
+
+// normalizeTitle lowercases the text and reduces it to words of letters and digits.
+func normalizeTitle(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// titleSimilarity returns the Sørensen–Dice coefficient of the letter pairs of the
+// normalized titles, from 0 for unrelated titles to 1 for equal ones.
+func titleSimilarity(a string, b string) float64 {
+	a, b = normalizeTitle(a), normalizeTitle(b)
+	if a == b {
+		return 1
+	}
+
+	aPairs, bPairs := letterPairs(a), letterPairs(b)
+	total := len(aPairs) + len(bPairs)
+	if total == 0 {
+		return 0
+	}
+
+	counts := make(map[string]int, len(aPairs))
+	for _, pair := range aPairs {
+		counts[pair]++
+	}
+	shared := 0
+	for _, pair := range bPairs {
+		if counts[pair] > 0 {
+			counts[pair]--
+			shared++
+		}
+	}
+	return float64(2*shared) / float64(total)
+}
+
+// letterPairs returns the adjacent letter pairs of every word of the text.
+func letterPairs(text string) []string {
+	var pairs []string
+	for _, word := range strings.Fields(text) {
+		runes := []rune(word)
+		for i := 0; i+1 < len(runes); i++ {
+			pairs = append(pairs, string(runes[i:i+2]))
+		}
+	}
+	return pairs
+}
+
+// sameLocation tells whether both locations are given and one contains the other,
+// so that "City Park" matches "City Park, main entrance".
+func sameLocation(a string, b string) bool {
+	a, b = normalizeTitle(a), normalizeTitle(b)
+	if a == "" || b == "" {
+		return false
+	}
+	return strings.Contains(a, b) || strings.Contains(b, a)
+}
+
+// EventKey derives a key from the normalized title and the start of the event,
+// so that the same event extracted twice gets the same key. Calendars tag the
+// events created by the bot with it.
+func EventKey(event *model.Event) string {
+	start := event.Start.Format(time.DateTime)
+	if event.AllDay {
+		start = event.Start.Format(time.DateOnly)
+	}
+
+	sum := sha256.Sum256([]byte(normalizeTitle(event.Title) + "\n" + start + "\n" + event.TimeZone))
+	return hex.EncodeToString(sum[:16])
+}
@@ -20,46 +20,756 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/ivgag/schedulr/ical"
 	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/storage"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrDraftAlreadyProcessed is returned when a draft was already created or discarded.
+	ErrDraftAlreadyProcessed = model.ErrorForMessage("draft was already processed")
+	ErrUnknownEventType      = model.ErrorForMessage("unknown event type")
+	// ErrInvalidCalendarFile is returned for iCalendar files that cannot be imported.
+	ErrInvalidCalendarFile = model.ErrorForMessage("invalid calendar file")
 )
 
 func NewEventService(
 	aiService AIService,
 	userService UserService,
 	clanedarServices map[model.Provider]CalendarService,
+	draftRepository storage.EventDraftRepository,
+	scheduledEventRepository storage.ScheduledEventRepository,
+	calendarPreferenceRepository storage.CalendarPreferenceRepository,
 ) *EventService {
 	return &EventService{
-		aiService:        aiService,
-		userService:      userService,
-		calendarServices: clanedarServices,
+		aiService:                    aiService,
+		userService:                  userService,
+		calendarServices:             clanedarServices,
+		draftRepository:              draftRepository,
+		scheduledEventRepository:     scheduledEventRepository,
+		calendarPreferenceRepository: calendarPreferenceRepository,
 	}
 }
 
 type EventService struct {
-	aiService        AIService
-	userService      UserService
-	calendarServices map[model.Provider]CalendarService
+	aiService                    AIService
+	userService                  UserService
+	calendarServices             map[model.Provider]CalendarService
+	draftRepository              storage.EventDraftRepository
+	scheduledEventRepository     storage.ScheduledEventRepository
+	calendarPreferenceRepository storage.CalendarPreferenceRepository
 }
 
-func (s *EventService) CreateEventsFromUserMessage(telegramID int64, messages []model.TextMessage) ([]model.ScheduledEvent, error) {
+// CreateDraftsFromUserMessage extracts events from the user's messages and stores
+// them as pending drafts. Nothing is written to the calendar until a draft is confirmed.
+func (s *EventService) CreateDraftsFromUserMessage(telegramID int64, messages []model.TextMessage) ([]storage.EventDraft, error) {
 	user, err := s.userService.GetUserByTelegramID(telegramID)
 	if err != nil {
 		return nil, err
 	}
 
-	events, err := s.aiService.ExtractCalendarEvents(&messages)
+	events, err := s.aiService.ExtractCalendarEvents(&messages, UserLocation(user))
+	if err != nil {
+		return nil, err
+	}
+
+	sourceMessageIDs := make([]int, len(messages))
+	for i, message := range messages {
+		sourceMessageIDs[i] = message.MessageID
+	}
+
+	return s.saveDrafts(user, telegramID, sourceMessageIDs, *events)
+}
+
+// CreateDraftsFromICS reads the events of an iCalendar file the user has sent
+// and stores them as pending drafts, like the ones extracted from messages.
+func (s *EventService) CreateDraftsFromICS(telegramID int64, messageID int, data []byte) ([]storage.EventDraft, error) {
+	user, err := s.userService.GetUserByTelegramID(telegramID)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := ical.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCalendarFile, err)
+	}
+
+	events := make([]model.Event, len(decoded))
+	for i, event := range decoded {
+		if _, _, err := event.RRule(); err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidCalendarFile, event.Title, err)
+		}
+		if !slices.Contains(model.EventTypes, event.EventType) {
+			event.EventType = "event"
+		}
+		events[i] = inUserTimeZone(event.Event, user)
+	}
+
+	return s.saveDrafts(user, telegramID, []int{messageID}, events)
+}
+
+// inUserTimeZone shows the UTC times of imported events in the user's zone.
+func inUserTimeZone(event model.Event, user storage.User) model.Event {
+	if event.TimeZone != "UTC" || user.TimeZone == "" {
+		return event
+	}
+
+	location := UserLocation(user)
+	wallClock := func(t time.Time) time.Time {
+		local := t.In(location)
+		return time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), 0, time.UTC)
+	}
+
+	event.Start, event.End = wallClock(event.Start), wallClock(event.End)
+	exceptionDates := make([]time.Time, len(event.ExceptionDates))
+	for i, date := range event.ExceptionDates {
+		exceptionDates[i] = wallClock(date)
+	}
+	if len(exceptionDates) > 0 {
+		event.ExceptionDates = exceptionDates
+	}
+	event.TimeZone = location.String()
+	return event
+}
+
+// saveDrafts stores the events as one batch of pending drafts for the user's default provider.
+func (s *EventService) saveDrafts(
+	user storage.User,
+	telegramID int64,
+	sourceMessageIDs []int,
+	events []model.Event,
+) ([]storage.EventDraft, error) {
+	provider, err := s.userService.CalendarProvider(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	preferences, err := s.calendarPreferenceRepository.ListByUserID(user.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	var scheduledEvents []model.ScheduledEvent = make([]model.ScheduledEvent, len(*events))
-	for i, event := range *events {
-		scheduledEvent, err := s.calendarServices[model.ProviderGoogle].CreateEvent(user.ID, &event)
+	batchID, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+
+	var drafts []storage.EventDraft = make([]storage.EventDraft, len(events))
+	for i, event := range events {
+		applyUserTimeZone(&event, user)
+		preference := preferredCalendar(preferences, provider, event.EventType)
+		drafts[i] = storage.EventDraft{
+			UserID:           user.ID,
+			BatchID:          batchID.String(),
+			ChatID:           telegramID,
+			SourceMessageIDs: sourceMessageIDs,
+			Provider:         provider,
+			CalendarID:       preference.CalendarID,
+			CalendarName:     preference.CalendarName,
+			Event:            event,
+			Status:           storage.DraftStatusPending,
+		}
+		if err := s.draftRepository.Save(&drafts[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return drafts, nil
+}
+
+// AttachDraftMessage remembers the Telegram message that shows the draft,
+// so that replies to it can be matched back to the draft.
+func (s *EventService) AttachDraftMessage(draftID int, messageID int) error {
+	draft, err := s.draftRepository.GetByID(draftID)
+	if err != nil {
+		return err
+	}
+
+	draft.MessageID = messageID
+	return s.draftRepository.Save(&draft)
+}
+
+// GetDraftByMessage returns the user's draft shown in the given message.
+func (s *EventService) GetDraftByMessage(telegramID int64, messageID int) (storage.EventDraft, error) {
+	user, err := s.userService.GetUserByTelegramID(telegramID)
+	if err != nil {
+		return storage.EventDraft{}, err
+	}
+
+	draft, err := s.draftRepository.GetByMessageID(telegramID, messageID)
+	if err != nil {
+		return storage.EventDraft{}, err
+	} else if draft.UserID != user.ID {
+		return storage.EventDraft{}, model.NotFoundError{Message: "draft not found"}
+	}
+
+	return draft, nil
+}
+
+// ProviderResult is the outcome of writing an event to the calendar of one provider.
+type ProviderResult struct {
+	Provider model.Provider
+	Event    model.ScheduledEvent
+	Err      error
+}
+
+// ConfirmDraft writes the draft to the calendars of the user's providers concurrently.
+// It fails only if no calendar accepted the event; the failures of the other
+// providers are reported in the results. Unless force is set, it returns
+// a DuplicateEventError if the user's calendars seem to have the event already.
+func (s *EventService) ConfirmDraft(telegramID int64, draftID int, force bool) ([]ProviderResult, error) {
+	draft, err := s.getUserDraft(telegramID, draftID)
+	if err != nil {
+		return nil, err
+	} else if draft.Status != storage.DraftStatusPending {
+		return nil, ErrDraftAlreadyProcessed
+	}
+
+	if !force {
+		if existing, ok := s.findDuplicate(draft); ok {
+			return nil, DuplicateEventError{Existing: existing}
+		}
+	}
+
+	// Claim the draft first so that a double tap cannot create the event twice.
+	if err := s.updateDraftStatus(draft, storage.DraftStatusCreated); err != nil {
+		return nil, err
+	}
+
+	results, err := s.createEvents(draft)
+	if err != nil {
+		if _, revertErr := s.draftRepository.UpdateStatus(
+			draft.ID, storage.DraftStatusCreated, storage.DraftStatusPending,
+		); revertErr != nil {
+			return nil, revertErr
+		}
+		return nil, err
+	}
+
+	// The draft card turns into the event card, so replies to it edit the created events.
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+
+		err := s.scheduledEventRepository.Save(&storage.ScheduledEvent{
+			UserID:           draft.UserID,
+			BatchID:          draft.BatchID,
+			Provider:         result.Provider,
+			ProviderEventID:  result.Event.ID,
+			CalendarID:       result.Event.CalendarID,
+			SourceChatID:     draft.ChatID,
+			SourceMessageIDs: draft.SourceMessageIDs,
+			CardMessageID:    draft.MessageID,
+			Event:            result.Event.Event,
+			Link:             result.Event.Link,
+		})
 		if err != nil {
+			log.Error().
+				Int("draftID", draft.ID).
+				Str("eventID", result.Event.ID).
+				Err(err).
+				Msg("Failed to save scheduled event")
+		}
+	}
+
+	return results, nil
+}
+
+// calendarTarget is a calendar of one of the user's providers.
+type calendarTarget struct {
+	provider   model.Provider
+	calendarID string
+}
+
+// createEvents creates the draft in every target calendar at once. It returns
+// an error joining the failures if none of the calendars accepted the event.
+func (s *EventService) createEvents(draft storage.EventDraft) ([]ProviderResult, error) {
+	targets, err := s.draftTargets(draft)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ProviderResult, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			event := draft.Event
+			results[i] = ProviderResult{Provider: target.provider}
+			calendarService, err := s.calendarService(target.provider)
+			if err != nil {
+				results[i].Err = err
+				return
+			}
+			results[i].Event, results[i].Err = calendarService.CreateEvent(draft.UserID, target.calendarID, &event)
+		}()
+	}
+	wg.Wait()
+
+	return results, failedEverywhere(results)
+}
+
+// draftTargets lists the calendars the draft is created in. The draft's provider keeps
+// the calendar picked on its card, the other providers use the user's preferences.
+func (s *EventService) draftTargets(draft storage.EventDraft) ([]calendarTarget, error) {
+	targets := []calendarTarget{{provider: draft.Provider, calendarID: draft.CalendarID}}
+
+	providers, err := s.userService.CalendarProviders(draft.UserID)
+	if err != nil {
+		return nil, err
+	} else if len(providers) < 2 {
+		return targets, nil
+	}
+
+	preferences, err := s.calendarPreferenceRepository.ListByUserID(draft.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, provider := range providers {
+		if provider != draft.Provider {
+			preference := preferredCalendar(preferences, provider, draft.Event.EventType)
+			targets = append(targets, calendarTarget{provider: provider, calendarID: preference.CalendarID})
+		}
+	}
+	return targets, nil
+}
+
+// failedEverywhere joins the errors of the results if none of them succeeded.
+func failedEverywhere(results []ProviderResult) error {
+	errs := make([]error, 0, len(results))
+	for _, result := range results {
+		if result.Err == nil {
+			return nil
+		}
+		errs = append(errs, result.Err)
+	}
+	return errors.Join(errs...)
+}
+
+func (s *EventService) calendarService(provider model.Provider) (CalendarService, error) {
+	calendarService, ok := s.calendarServices[provider]
+	if !ok {
+		return nil, ErrProviderUnavailable
+	}
+	return calendarService, nil
+}
+
+// EditEventByMessage applies the user's instruction to the created events
+// shown in the given message and patches them in their calendars.
+func (s *EventService) EditEventByMessage(
+	telegramID int64,
+	messageID int,
+	instruction string,
+) ([]ProviderResult, error) {
+	user, err := s.userService.GetUserByTelegramID(telegramID)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.getCardEvents(user, telegramID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	edited, aiErr := s.aiService.EditCalendarEvent(&stored[0].Event, instruction, UserLocation(user))
+	if aiErr != nil {
+		return nil, aiErr
+	}
+	applyUserTimeZone(edited, user)
+
+	results := make([]ProviderResult, len(stored))
+	for i, event := range stored {
+		results[i] = ProviderResult{Provider: event.Provider}
+
+		calendarService, err := s.calendarService(event.Provider)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		results[i].Event, results[i].Err = calendarService.UpdateEvent(
+			user.ID,
+			event.CalendarID,
+			event.ProviderEventID,
+			edited,
+		)
+		if results[i].Err != nil {
+			continue
+		}
+
+		event.Event = results[i].Event.Event
+		event.Link = results[i].Event.Link
+		if err := s.scheduledEventRepository.Save(&event); err != nil {
 			return nil, err
 		}
-		scheduledEvents[i] = scheduledEvent
 	}
 
-	return scheduledEvents, nil
+	if err := failedEverywhere(results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// DeleteEventByMessage removes the created events shown in the given message from their calendars.
+func (s *EventService) DeleteEventByMessage(telegramID int64, messageID int) (storage.ScheduledEvent, error) {
+	user, err := s.userService.GetUserByTelegramID(telegramID)
+	if err != nil {
+		return storage.ScheduledEvent{}, err
+	}
+
+	stored, err := s.getCardEvents(user, telegramID, messageID)
+	if err != nil {
+		return storage.ScheduledEvent{}, err
+	}
+
+	// Every event is tried, so that retrying only has the failed ones left.
+	var errs []error
+	for _, event := range stored {
+		if err := s.deleteScheduledEvent(event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return storage.ScheduledEvent{}, err
+	}
+
+	// Mark the draft behind the card as discarded so that /undo skips it.
+	draft, err := s.draftRepository.GetByMessageID(telegramID, messageID)
+	if err == nil {
+		_, err = s.draftRepository.UpdateStatus(draft.ID, storage.DraftStatusCreated, storage.DraftStatusDiscarded)
+	}
+	var notFound model.NotFoundError
+	if err != nil && !errors.As(err, &notFound) {
+		return storage.ScheduledEvent{}, err
+	}
+
+	return stored[0], nil
+}
+
+// getCardEvents returns the user's events shown in the given message.
+func (s *EventService) getCardEvents(user storage.User, telegramID int64, messageID int) ([]storage.ScheduledEvent, error) {
+	stored, err := s.scheduledEventRepository.ListByCardMessageID(telegramID, messageID)
+	if err != nil {
+		return nil, err
+	} else if len(stored) == 0 || stored[0].UserID != user.ID {
+		return nil, model.NotFoundError{Message: "event not found"}
+	}
+	return stored, nil
+}
+
+// UndoResult lists what UndoLastBatch has removed.
+type UndoResult struct {
+	DeletedEvents   []storage.ScheduledEvent
+	DiscardedDrafts []storage.EventDraft
+}
+
+// UndoLastBatch removes every event created from the user's most recent batch
+// of messages and discards the drafts of that batch that are still pending.
+func (s *EventService) UndoLastBatch(telegramID int64) (UndoResult, error) {
+	user, err := s.userService.GetUserByTelegramID(telegramID)
+	if err != nil {
+		return UndoResult{}, err
+	}
+
+	batchID, err := s.draftRepository.GetLatestActiveBatchID(user.ID)
+	if err != nil {
+		return UndoResult{}, err
+	}
+
+	var result UndoResult
+
+	events, err := s.scheduledEventRepository.ListByBatchID(batchID)
+	if err != nil {
+		return UndoResult{}, err
+	}
+	for _, event := range events {
+		if err := s.deleteScheduledEvent(event); err != nil {
+			return result, err
+		}
+		result.DeletedEvents = append(result.DeletedEvents, event)
+	}
+
+	drafts, err := s.draftRepository.ListByBatchID(batchID)
+	if err != nil {
+		return result, err
+	}
+	for _, draft := range drafts {
+		if draft.Status == storage.DraftStatusDiscarded {
+			continue
+		}
+
+		if _, err := s.draftRepository.UpdateStatus(draft.ID, draft.Status, storage.DraftStatusDiscarded); err != nil {
+			return result, err
+		}
+		if draft.Status == storage.DraftStatusPending {
+			result.DiscardedDrafts = append(result.DiscardedDrafts, draft)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *EventService) deleteScheduledEvent(event storage.ScheduledEvent) error {
+	calendarService, err := s.calendarService(event.Provider)
+	if err != nil {
+		return err
+	}
+
+	err = calendarService.DeleteEvent(event.UserID, event.CalendarID, event.ProviderEventID)
+	if err != nil {
+		return err
+	}
+
+	return s.scheduledEventRepository.Delete(event.ID)
+}
+
+// UnlinkResult describes what was cleaned up after unlinking an account.
+type UnlinkResult struct {
+	// Revoked is false if the provider could not revoke the grant.
+	Revoked         bool
+	DiscardedDrafts []storage.EventDraft
+	// ForgottenEvents stay in the calendar, but can no longer be edited or deleted from the chat.
+	ForgottenEvents []storage.ScheduledEvent
+	// AppPassword is set for accounts that signed in with an app password. Nothing
+	// is revoked then, the user deletes the password in the provider's settings.
+	AppPassword bool
+}
+
+// UnlinkAccount disconnects the user's account of the provider and cleans up
+// the pending drafts and the events created in its calendars.
+func (s *EventService) UnlinkAccount(telegramID int64, provider model.Provider) (UnlinkResult, error) {
+	user, err := s.userService.GetUserByTelegramID(telegramID)
+	if err != nil {
+		return UnlinkResult{}, err
+	}
+
+	accounts, err := s.userService.LinkedAccounts(telegramID)
+	if err != nil {
+		return UnlinkResult{}, err
+	} else if !slices.ContainsFunc(accounts, func(account storage.LinkedAccount) bool { return account.Provider == provider }) {
+		return UnlinkResult{}, model.NotFoundError{Message: "account not found"}
+	}
+
+	// The account is deleted last, so that if the cleanup fails it stays linked
+	// and unlinking it again finishes the job.
+	var result UnlinkResult
+
+	result.DiscardedDrafts, err = s.draftRepository.DiscardPendingByProvider(user.ID, provider)
+	if err != nil {
+		return result, err
+	}
+
+	result.ForgottenEvents, err = s.scheduledEventRepository.DeleteByProvider(user.ID, provider)
+	if err != nil {
+		return result, err
+	}
+
+	// The calendars may not exist in the account linked next.
+	if err := s.calendarPreferenceRepository.DeleteByProvider(user.ID, provider); err != nil {
+		return result, err
+	}
+
+	result.Revoked, err = s.userService.UnlinkAccount(user.ID, provider)
+	if err != nil {
+		return result, err
+	}
+	result.AppPassword = provider == model.ProviderCalDAV
+
+	return result, nil
+}
+
+// ListCalendars returns the calendars of the user's account of the provider.
+func (s *EventService) ListCalendars(telegramID int64, provider model.Provider) ([]model.Calendar, error) {
+	user, err := s.userService.GetUserByTelegramID(telegramID)
+	if err != nil {
+		return nil, err
+	}
+
+	calendarService, err := s.calendarService(provider)
+	if err != nil {
+		return nil, err
+	}
+	return calendarService.ListCalendars(user.ID)
+}
+
+// CalendarPreferences returns the calendars the user picked for every provider and event type.
+func (s *EventService) CalendarPreferences(telegramID int64) ([]storage.CalendarPreference, error) {
+	user, err := s.userService.GetUserByTelegramID(telegramID)
+	if err != nil {
+		return nil, err
+	}
+	return s.calendarPreferenceRepository.ListByUserID(user.ID)
+}
+
+// SetCalendarPreference makes new events of the type go to the calendar. An empty event type
+// sets the default calendar of the provider, and an empty calendar ID removes the preference.
+func (s *EventService) SetCalendarPreference(
+	telegramID int64,
+	provider model.Provider,
+	eventType string,
+	calendar model.Calendar,
+) error {
+	if eventType != "" && !slices.Contains(model.EventTypes, eventType) {
+		return ErrUnknownEventType
+	}
+
+	user, err := s.userService.GetUserByTelegramID(telegramID)
+	if err != nil {
+		return err
+	}
+
+	if calendar.ID == "" {
+		return s.calendarPreferenceRepository.Delete(user.ID, provider, eventType)
+	}
+	return s.calendarPreferenceRepository.Save(storage.CalendarPreference{
+		UserID:       user.ID,
+		Provider:     provider,
+		EventType:    eventType,
+		CalendarID:   calendar.ID,
+		CalendarName: calendar.Name,
+	})
+}
+
+// DraftCalendars returns the calendars the pending draft can be created in.
+func (s *EventService) DraftCalendars(telegramID int64, draftID int) ([]model.Calendar, error) {
+	draft, err := s.getUserDraft(telegramID, draftID)
+	if err != nil {
+		return nil, err
+	} else if draft.Status != storage.DraftStatusPending {
+		return nil, ErrDraftAlreadyProcessed
+	}
+
+	calendarService, err := s.calendarService(draft.Provider)
+	if err != nil {
+		return nil, err
+	}
+	return calendarService.ListCalendars(draft.UserID)
+}
+
+// SetDraftCalendar changes the calendar the pending draft is created in.
+func (s *EventService) SetDraftCalendar(telegramID int64, draftID int, calendar model.Calendar) (storage.EventDraft, error) {
+	draft, err := s.getUserDraft(telegramID, draftID)
+	if err != nil {
+		return storage.EventDraft{}, err
+	} else if draft.Status != storage.DraftStatusPending {
+		return storage.EventDraft{}, ErrDraftAlreadyProcessed
+	}
+
+	draft.CalendarID = calendar.ID
+	draft.CalendarName = calendar.Name
+	if err := s.draftRepository.Save(&draft); err != nil {
+		return storage.EventDraft{}, err
+	}
+
+	return draft, nil
+}
+
+// preferredCalendar picks the user's calendar for events of the type, falling back
+// to the provider's default one. The zero preference stands for the account's default calendar.
+func preferredCalendar(
+	preferences []storage.CalendarPreference,
+	provider model.Provider,
+	eventType string,
+) storage.CalendarPreference {
+	var fallback storage.CalendarPreference
+	for _, preference := range preferences {
+		if preference.Provider != provider {
+			continue
+		}
+		if eventType != "" && preference.EventType == eventType {
+			return preference
+		} else if preference.EventType == "" {
+			fallback = preference
+		}
+	}
+	return fallback
+}
+
+// DiscardDraft drops the draft without creating an event.
+func (s *EventService) DiscardDraft(telegramID int64, draftID int) (storage.EventDraft, error) {
+	draft, err := s.getUserDraft(telegramID, draftID)
+	if err != nil {
+		return storage.EventDraft{}, err
+	}
+
+	if err := s.updateDraftStatus(draft, storage.DraftStatusDiscarded); err != nil {
+		return storage.EventDraft{}, err
+	}
+
+	draft.Status = storage.DraftStatusDiscarded
+	return draft, nil
+}
+
+// ReviseDraft applies the user's instruction to a pending draft.
+func (s *EventService) ReviseDraft(telegramID int64, draftID int, instruction string) (storage.EventDraft, error) {
+	draft, err := s.getUserDraft(telegramID, draftID)
+	if err != nil {
+		return storage.EventDraft{}, err
+	} else if draft.Status != storage.DraftStatusPending {
+		return storage.EventDraft{}, ErrDraftAlreadyProcessed
+	}
+
+	user, err := s.userService.GetUserByID(draft.UserID)
+	if err != nil {
+		return storage.EventDraft{}, err
+	}
+
+	edited, aiErr := s.aiService.EditCalendarEvent(&draft.Event, instruction, UserLocation(user))
+	if aiErr != nil {
+		return storage.EventDraft{}, aiErr
+	}
+	applyUserTimeZone(edited, user)
+
+	draft.Event = *edited
+	if err := s.draftRepository.Save(&draft); err != nil {
+		return storage.EventDraft{}, err
+	}
+
+	return draft, nil
+}
+
+// applyUserTimeZone pins events without an explicit time zone to the user's one,
+// so that the calendar does not reinterpret them in its own zone.
+func applyUserTimeZone(event *model.Event, user storage.User) {
+	if event.TimeZone == "" && !event.AllDay {
+		event.TimeZone = user.TimeZone
+	}
+}
+
+func (s *EventService) getUserDraft(telegramID int64, draftID int) (storage.EventDraft, error) {
+	user, err := s.userService.GetUserByTelegramID(telegramID)
+	if err != nil {
+		return storage.EventDraft{}, err
+	}
+
+	draft, err := s.draftRepository.GetByID(draftID)
+	if err != nil {
+		return storage.EventDraft{}, err
+	} else if draft.UserID != user.ID {
+		return storage.EventDraft{}, model.NotFoundError{Message: "draft not found"}
+	}
+
+	return draft, nil
+}
+
+func (s *EventService) updateDraftStatus(draft storage.EventDraft, status storage.EventDraftStatus) error {
+	updated, err := s.draftRepository.UpdateStatus(draft.ID, storage.DraftStatusPending, status)
+	if err != nil {
+		return err
+	} else if !updated {
+		return ErrDraftAlreadyProcessed
+	}
+
+	return nil
 }
@@ -0,0 +1,965 @@
+package service_test
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ivgag/schedulr/ai"
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/service"
+	"github.com/ivgag/schedulr/storage"
+)
+
+const (
+	eventUserID     = 1
+	eventTelegramID = 100
+)
+
+func TestEventService_ConfirmDraft(t *testing.T) {
+	errUnavailable := errors.New("calendar unavailable")
+
+	tests := []struct {
+		name            string
+		defaultProvider model.Provider
+		allProviders    bool
+		googleErr       error
+		microsoftErr    error
+		wantCreated     map[model.Provider]string
+		wantFailed      []model.Provider
+		wantErr         bool
+	}{
+		{
+			name:        "default provider only",
+			wantCreated: map[model.Provider]string{model.ProviderGoogle: "work"},
+		},
+		{
+			name:            "chosen default provider",
+			defaultProvider: model.ProviderMicrosoft,
+			wantCreated:     map[model.Provider]string{model.ProviderMicrosoft: "family"},
+		},
+		{
+			name:         "all providers",
+			allProviders: true,
+			wantCreated:  map[model.Provider]string{model.ProviderGoogle: "work", model.ProviderMicrosoft: "family"},
+		},
+		{
+			name:         "partial failure",
+			allProviders: true,
+			microsoftErr: model.RelinkRequiredError{Provider: model.ProviderMicrosoft},
+			wantCreated:  map[model.Provider]string{model.ProviderGoogle: "work"},
+			wantFailed:   []model.Provider{model.ProviderMicrosoft},
+		},
+		{
+			name:         "every provider fails",
+			allProviders: true,
+			googleErr:    errUnavailable,
+			microsoftErr: errUnavailable,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &fakeUsers{user: storage.User{
+				ID:              eventUserID,
+				TelegramID:      eventTelegramID,
+				DefaultProvider: tt.defaultProvider,
+				AllProviders:    tt.allProviders,
+			}}
+			accounts := &fakeLinkedAccounts{accounts: map[model.Provider]storage.LinkedAccount{
+				model.ProviderGoogle:    {UserID: eventUserID, Provider: model.ProviderGoogle, Status: storage.LinkedAccountActive},
+				model.ProviderMicrosoft: {UserID: eventUserID, Provider: model.ProviderMicrosoft, Status: storage.LinkedAccountActive},
+			}}
+			tokenServices := map[model.Provider]service.TokenService{model.ProviderGoogle: nil, model.ProviderMicrosoft: nil}
+			userService := service.NewUserService(users, accounts, tokenServices, nil)
+
+			provider, err := userService.CalendarProvider(eventUserID)
+			if err != nil {
+				t.Fatalf("CalendarProvider() error = %v", err)
+			}
+
+			// The draft keeps the calendar picked on its card; other providers use the preferences.
+			preferences := &fakePreferences{preferences: []storage.CalendarPreference{
+				{UserID: eventUserID, Provider: model.ProviderMicrosoft, EventType: "birthday", CalendarID: "family"},
+			}}
+			drafts := &fakeDrafts{draft: storage.EventDraft{
+				ID:         1,
+				UserID:     eventUserID,
+				ChatID:     eventTelegramID,
+				Provider:   provider,
+				CalendarID: map[model.Provider]string{model.ProviderGoogle: "work", model.ProviderMicrosoft: "family"}[provider],
+				Event:      model.Event{Title: "Grandma's birthday", EventType: "birthday"},
+				Status:     storage.DraftStatusPending,
+			}}
+			events := &fakeScheduledEvents{}
+			calendars := map[model.Provider]service.CalendarService{
+				model.ProviderGoogle:    &fakeCalendar{provider: model.ProviderGoogle, err: tt.googleErr},
+				model.ProviderMicrosoft: &fakeCalendar{provider: model.ProviderMicrosoft, err: tt.microsoftErr},
+			}
+			eventService := service.NewEventService(service.AIService{}, *userService, calendars, drafts, events, preferences)
+
+			results, err := eventService.ConfirmDraft(eventTelegramID, 1, false)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ConfirmDraft() error = nil, want an error")
+				} else if drafts.draft.Status != storage.DraftStatusPending {
+					t.Errorf("draft status = %s, want it pending again", drafts.draft.Status)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("ConfirmDraft() error = %v", err)
+			}
+
+			created := make(map[model.Provider]string)
+			var failed []model.Provider
+			for _, result := range results {
+				if result.Err != nil {
+					failed = append(failed, result.Provider)
+				} else {
+					created[result.Provider] = result.Event.CalendarID
+				}
+			}
+			if len(created) != len(tt.wantCreated) {
+				t.Errorf("created in %v, want %v", created, tt.wantCreated)
+			}
+			for provider, calendarID := range tt.wantCreated {
+				if created[provider] != calendarID {
+					t.Errorf("created in %v, want %v", created, tt.wantCreated)
+				}
+			}
+			if len(failed) != len(tt.wantFailed) {
+				t.Errorf("failed in %v, want %v", failed, tt.wantFailed)
+			}
+
+			if len(events.events) != len(tt.wantCreated) {
+				t.Errorf("saved %d scheduled events, want %d", len(events.events), len(tt.wantCreated))
+			}
+			if drafts.draft.Status != storage.DraftStatusCreated {
+				t.Errorf("draft status = %s, want %s", drafts.draft.Status, storage.DraftStatusCreated)
+			}
+		})
+	}
+}
+
+func TestEventService_ConfirmDraftDuplicates(t *testing.T) {
+	// The draft is given in Berlin time, while calendars list events in UTC.
+	draftEvent := model.Event{
+		Title:    "Jazz Concert!",
+		Start:    time.Date(2025, 3, 4, 19, 0, 0, 0, time.UTC),
+		End:      time.Date(2025, 3, 4, 21, 0, 0, 0, time.UTC),
+		Location: "Blue Note Club",
+		TimeZone: "Europe/Berlin",
+	}
+	at := func(title string, location string, hour int) model.Event {
+		return model.Event{
+			Title:    title,
+			Start:    time.Date(2025, 3, 4, hour, 0, 0, 0, time.UTC),
+			End:      time.Date(2025, 3, 4, hour+1, 0, 0, 0, time.UTC),
+			Location: location,
+			TimeZone: "UTC",
+		}
+	}
+
+	tests := []struct {
+		name           string
+		stored         []model.Event
+		calendarEvents []model.Event
+		calendarKey    string
+		force          bool
+		wantDuplicate  string
+	}{
+		{
+			name:   "nothing similar",
+			stored: []model.Event{at("Dentist", "", 18)},
+		},
+		{
+			name:          "stored by the bot",
+			stored:        []model.Event{draftEvent},
+			wantDuplicate: "Jazz Concert!",
+		},
+		{
+			name:           "same title in the calendar",
+			calendarEvents: []model.Event{at("jazz concert", "", 18)},
+			wantDuplicate:  "jazz concert",
+		},
+		{
+			name:           "same title at another time",
+			calendarEvents: []model.Event{at("Jazz concert", "", 12)},
+		},
+		{
+			name:           "renamed but tagged by the bot",
+			calendarEvents: []model.Event{at("Friday night out", "", 18)},
+			calendarKey:    service.EventKey(&draftEvent),
+			wantDuplicate:  "Friday night out",
+		},
+		{
+			name:           "similar title at the same place",
+			calendarEvents: []model.Event{at("Concert of the jazz band", "Blue Note Club, Berlin", 19)},
+			wantDuplicate:  "Concert of the jazz band",
+		},
+		{
+			name:           "similar title elsewhere",
+			calendarEvents: []model.Event{at("Concert of the jazz band", "City Hall", 19)},
+		},
+		{
+			name:           "forced",
+			calendarEvents: []model.Event{at("Jazz concert", "", 18)},
+			force:          true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &fakeUsers{user: storage.User{ID: eventUserID, TelegramID: eventTelegramID, TimeZone: "Europe/Berlin"}}
+			accounts := &fakeLinkedAccounts{accounts: map[model.Provider]storage.LinkedAccount{
+				model.ProviderGoogle: {UserID: eventUserID, Provider: model.ProviderGoogle, Status: storage.LinkedAccountActive},
+			}}
+			tokenServices := map[model.Provider]service.TokenService{model.ProviderGoogle: nil}
+			userService := service.NewUserService(users, accounts, tokenServices, nil)
+
+			drafts := &fakeDrafts{draft: storage.EventDraft{
+				ID:       1,
+				UserID:   eventUserID,
+				ChatID:   eventTelegramID,
+				Provider: model.ProviderGoogle,
+				Event:    draftEvent,
+				Status:   storage.DraftStatusPending,
+			}}
+			events := &fakeScheduledEvents{}
+			for _, event := range tt.stored {
+				events.events = append(events.events, storage.ScheduledEvent{UserID: eventUserID, Event: event})
+			}
+			calendar := &fakeCalendar{provider: model.ProviderGoogle}
+			for _, event := range tt.calendarEvents {
+				calendar.events = append(calendar.events, model.ScheduledEvent{
+					Provider: model.ProviderGoogle,
+					Event:    event,
+					Key:      tt.calendarKey,
+				})
+			}
+			calendars := map[model.Provider]service.CalendarService{model.ProviderGoogle: calendar}
+			eventService := service.NewEventService(
+				service.AIService{}, *userService, calendars, drafts, events, &fakePreferences{},
+			)
+
+			_, err := eventService.ConfirmDraft(eventTelegramID, 1, tt.force)
+
+			var duplicate service.DuplicateEventError
+			if tt.wantDuplicate == "" {
+				if err != nil {
+					t.Fatalf("ConfirmDraft() error = %v", err)
+				} else if drafts.draft.Status != storage.DraftStatusCreated {
+					t.Errorf("draft status = %s, want %s", drafts.draft.Status, storage.DraftStatusCreated)
+				}
+				return
+			} else if !errors.As(err, &duplicate) {
+				t.Fatalf("ConfirmDraft() error = %v, want a duplicate", err)
+			}
+
+			if duplicate.Existing.Event.Title != tt.wantDuplicate {
+				t.Errorf("duplicate = %q, want %q", duplicate.Existing.Event.Title, tt.wantDuplicate)
+			}
+			if drafts.draft.Status != storage.DraftStatusPending {
+				t.Errorf("draft status = %s, want it still pending", drafts.draft.Status)
+			}
+		})
+	}
+}
+
+func TestEventService_ConfirmDraftClaim(t *testing.T) {
+	tests := []struct {
+		name string
+		// stored is the status of the draft in the repository, read is the one
+		// ConfirmDraft reads, which differ when another tap claimed it in between.
+		stored      storage.EventDraftStatus
+		read        storage.EventDraftStatus
+		calendarErr error
+		wantErr     error
+		wantCreated int
+		wantStatus  storage.EventDraftStatus
+	}{
+		{
+			name:        "pending",
+			stored:      storage.DraftStatusPending,
+			read:        storage.DraftStatusPending,
+			wantCreated: 1,
+			wantStatus:  storage.DraftStatusCreated,
+		},
+		{
+			name:       "already created",
+			stored:     storage.DraftStatusCreated,
+			read:       storage.DraftStatusCreated,
+			wantErr:    service.ErrDraftAlreadyProcessed,
+			wantStatus: storage.DraftStatusCreated,
+		},
+		{
+			name:       "claimed by another tap",
+			stored:     storage.DraftStatusCreated,
+			read:       storage.DraftStatusPending,
+			wantErr:    service.ErrDraftAlreadyProcessed,
+			wantStatus: storage.DraftStatusCreated,
+		},
+		{
+			name:        "calendar fails",
+			stored:      storage.DraftStatusPending,
+			read:        storage.DraftStatusPending,
+			calendarErr: errors.New("calendar unavailable"),
+			wantStatus:  storage.DraftStatusPending,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			draft := newTestDraft(tt.stored)
+			read := draft
+			read.Status = tt.read
+			drafts := &staleDrafts{fakeDrafts: fakeDrafts{draft: draft}, read: read}
+			calendar := &fakeCalendar{provider: model.ProviderGoogle, err: tt.calendarErr}
+			eventService := newDraftEventService(drafts, calendar, nil, "Europe/Berlin")
+
+			_, err := eventService.ConfirmDraft(eventTelegramID, draft.ID, true)
+			if tt.calendarErr != nil {
+				if err == nil {
+					t.Errorf("ConfirmDraft() error = nil, want an error")
+				}
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ConfirmDraft() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if calendar.created != tt.wantCreated {
+				t.Errorf("created %d events, want %d", calendar.created, tt.wantCreated)
+			}
+			if drafts.draft.Status != tt.wantStatus {
+				t.Errorf("draft status = %s, want %s", drafts.draft.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestEventService_DiscardDraft(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     storage.EventDraftStatus
+		userID     int
+		wantErr    error
+		wantStatus storage.EventDraftStatus
+	}{
+		{
+			name:       "pending",
+			status:     storage.DraftStatusPending,
+			wantStatus: storage.DraftStatusDiscarded,
+		},
+		{
+			name:       "already created",
+			status:     storage.DraftStatusCreated,
+			wantErr:    service.ErrDraftAlreadyProcessed,
+			wantStatus: storage.DraftStatusCreated,
+		},
+		{
+			name:       "already discarded",
+			status:     storage.DraftStatusDiscarded,
+			wantErr:    service.ErrDraftAlreadyProcessed,
+			wantStatus: storage.DraftStatusDiscarded,
+		},
+		{
+			name:       "draft of another user",
+			status:     storage.DraftStatusPending,
+			userID:     eventUserID + 1,
+			wantErr:    model.NotFoundError{Message: "draft not found"},
+			wantStatus: storage.DraftStatusPending,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			draft := newTestDraft(tt.status)
+			if tt.userID != 0 {
+				draft.UserID = tt.userID
+			}
+			drafts := &fakeDrafts{draft: draft}
+			eventService := newDraftEventService(drafts, &fakeCalendar{provider: model.ProviderGoogle}, nil, "Europe/Berlin")
+
+			discarded, err := eventService.DiscardDraft(eventTelegramID, draft.ID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DiscardDraft() error = %v, want %v", err, tt.wantErr)
+			} else if err == nil && discarded.Status != storage.DraftStatusDiscarded {
+				t.Errorf("DiscardDraft() status = %s, want %s", discarded.Status, storage.DraftStatusDiscarded)
+			}
+			if drafts.draft.Status != tt.wantStatus {
+				t.Errorf("draft status = %s, want %s", drafts.draft.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestEventService_ReviseDraft(t *testing.T) {
+	edited := model.Event{
+		Title: "Dentist",
+		Start: time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC),
+		End:   time.Date(2026, 10, 20, 11, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name      string
+		status    storage.EventDraftStatus
+		aiErr     model.Error
+		wantErr   bool
+		wantCalls int
+		wantTitle string
+	}{
+		{
+			name:      "pending",
+			status:    storage.DraftStatusPending,
+			wantCalls: 1,
+			wantTitle: "Dentist",
+		},
+		{
+			name:      "already created",
+			status:    storage.DraftStatusCreated,
+			wantErr:   true,
+			wantTitle: "Grandma's birthday",
+		},
+		{
+			name:      "AI fails",
+			status:    storage.DraftStatusPending,
+			aiErr:     badRequest,
+			wantErr:   true,
+			wantCalls: 1,
+			wantTitle: "Grandma's birthday",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drafts := &fakeDrafts{draft: newTestDraft(tt.status)}
+			agent := &fakeAI{provider: ai.ProviderOpenAI, err: tt.aiErr, events: []model.Event{edited}}
+			eventService := newDraftEventService(drafts, &fakeCalendar{provider: model.ProviderGoogle}, agent, "Europe/Berlin")
+
+			revised, err := eventService.ReviseDraft(eventTelegramID, drafts.draft.ID, "move it to 10:00, it is the dentist")
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("ReviseDraft() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.status != storage.DraftStatusPending && !errors.Is(err, service.ErrDraftAlreadyProcessed) {
+				t.Errorf("ReviseDraft() error = %v, want %v", err, service.ErrDraftAlreadyProcessed)
+			}
+			if agent.calls != tt.wantCalls {
+				t.Errorf("AI called %d times, want %d", agent.calls, tt.wantCalls)
+			}
+			if drafts.draft.Event.Title != tt.wantTitle {
+				t.Errorf("stored title = %q, want %q", drafts.draft.Event.Title, tt.wantTitle)
+			}
+
+			// An edited event without a time zone is pinned to the user's one.
+			if err == nil && revised.Event.TimeZone != "Europe/Berlin" {
+				t.Errorf("TimeZone = %q, want the user's Europe/Berlin", revised.Event.TimeZone)
+			}
+		})
+	}
+}
+
+func TestEventService_ReviseDraftTimeZone(t *testing.T) {
+	at := func(timeZone string, allDay bool) model.Event {
+		return model.Event{
+			Title:    "Dentist",
+			Start:    time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC),
+			End:      time.Date(2026, 10, 20, 11, 0, 0, 0, time.UTC),
+			TimeZone: timeZone,
+			AllDay:   allDay,
+		}
+	}
+
+	tests := []struct {
+		name         string
+		userTimeZone string
+		edited       model.Event
+		want         string
+	}{
+		{name: "pinned to the user's zone", userTimeZone: "Europe/Berlin", edited: at("", false), want: "Europe/Berlin"},
+		{name: "explicit zone kept", userTimeZone: "Europe/Berlin", edited: at("Asia/Tokyo", false), want: "Asia/Tokyo"},
+		{name: "all-day event", userTimeZone: "Europe/Berlin", edited: at("", true), want: ""},
+		{name: "user without a time zone", edited: at("", false), want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drafts := &fakeDrafts{draft: newTestDraft(storage.DraftStatusPending)}
+			agent := &fakeAI{provider: ai.ProviderOpenAI, events: []model.Event{tt.edited}}
+			eventService := newDraftEventService(drafts, &fakeCalendar{provider: model.ProviderGoogle}, agent, tt.userTimeZone)
+
+			revised, err := eventService.ReviseDraft(eventTelegramID, drafts.draft.ID, "make it at 10")
+			if err != nil {
+				t.Fatalf("ReviseDraft() error = %v", err)
+			}
+			if revised.Event.TimeZone != tt.want {
+				t.Errorf("TimeZone = %q, want %q", revised.Event.TimeZone, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventService_UnlinkAccount(t *testing.T) {
+	errStorage := errors.New("storage unavailable")
+
+	tests := []struct {
+		name          string
+		provider      model.Provider
+		deleteErr     error
+		wantErr       error
+		wantForgotten int
+		wantLinked    bool
+		wantRevoked   int
+		// wantAppPassword is set for accounts that have no grant to revoke.
+		wantAppPassword bool
+	}{
+		{
+			name:          "linked account",
+			provider:      model.ProviderGoogle,
+			wantForgotten: 1,
+			wantRevoked:   1,
+		},
+		{
+			name:        "cleanup fails",
+			provider:    model.ProviderGoogle,
+			deleteErr:   errStorage,
+			wantErr:     errStorage,
+			wantLinked:  true,
+			wantRevoked: 0,
+		},
+		{
+			name:            "CalDAV account",
+			provider:        model.ProviderCalDAV,
+			wantLinked:      true,
+			wantAppPassword: true,
+		},
+		{
+			name:       "account not linked",
+			provider:   model.ProviderMicrosoft,
+			wantErr:    model.NotFoundError{Message: "account not found"},
+			wantLinked: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, tokenService, google, accounts := newGoogleCalendar(t, false)
+			accounts.accounts[model.ProviderCalDAV] = storage.LinkedAccount{
+				UserID:   googleUserID,
+				Provider: model.ProviderCalDAV,
+				Status:   storage.LinkedAccountActive,
+			}
+			users := &fakeUsers{user: storage.User{ID: googleUserID, TelegramID: eventTelegramID}}
+			userService := service.NewUserService(users, accounts, map[model.Provider]service.TokenService{
+				model.ProviderGoogle: tokenService,
+			}, nil)
+
+			events := &fakeScheduledEvents{deleteErr: tt.deleteErr, events: []storage.ScheduledEvent{
+				{ID: 1, UserID: googleUserID, Provider: model.ProviderGoogle},
+				{ID: 2, UserID: googleUserID, Provider: model.ProviderMicrosoft},
+			}}
+			eventService := service.NewEventService(
+				service.AIService{}, *userService, nil, &fakeDrafts{}, events, &fakePreferences{},
+			)
+
+			result, err := eventService.UnlinkAccount(eventTelegramID, tt.provider)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UnlinkAccount() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(result.ForgottenEvents) != tt.wantForgotten {
+				t.Errorf("ForgottenEvents = %+v, want %d", result.ForgottenEvents, tt.wantForgotten)
+			}
+			if err == nil && result.Revoked == tt.wantAppPassword {
+				t.Errorf("Revoked = %v, want %v", result.Revoked, !tt.wantAppPassword)
+			}
+			if result.AppPassword != tt.wantAppPassword {
+				t.Errorf("AppPassword = %v, want %v", result.AppPassword, tt.wantAppPassword)
+			}
+			if _, err := accounts.GetByUserIDAndProvider(googleUserID, model.ProviderCalDAV); (err != nil) != (tt.provider == model.ProviderCalDAV) {
+				t.Errorf("CalDAV account linked = %v, want it unlinked only when unlinking CalDAV", err == nil)
+			}
+
+			_, err = accounts.GetByUserIDAndProvider(googleUserID, model.ProviderGoogle)
+			if linked := err == nil; linked != tt.wantLinked {
+				t.Errorf("Google account linked = %v, want %v", linked, tt.wantLinked)
+			}
+			if len(google.revoked) != tt.wantRevoked {
+				t.Errorf("revoked %d grants, want %d", len(google.revoked), tt.wantRevoked)
+			}
+		})
+	}
+}
+
+func newTestDraft(status storage.EventDraftStatus) storage.EventDraft {
+	return storage.EventDraft{
+		ID:       1,
+		UserID:   eventUserID,
+		ChatID:   eventTelegramID,
+		Provider: model.ProviderGoogle,
+		Event:    model.Event{Title: "Grandma's birthday", EventType: "birthday"},
+		Status:   status,
+	}
+}
+
+// newDraftEventService returns an EventService for a user in the time zone with a Google account.
+func newDraftEventService(
+	drafts storage.EventDraftRepository,
+	calendar *fakeCalendar,
+	agent *fakeAI,
+	timeZone string,
+) *service.EventService {
+	users := &fakeUsers{user: storage.User{ID: eventUserID, TelegramID: eventTelegramID, TimeZone: timeZone}}
+	accounts := &fakeLinkedAccounts{accounts: map[model.Provider]storage.LinkedAccount{
+		model.ProviderGoogle: {UserID: eventUserID, Provider: model.ProviderGoogle, Status: storage.LinkedAccountActive},
+	}}
+	userService := service.NewUserService(users, accounts, map[model.Provider]service.TokenService{model.ProviderGoogle: nil}, nil)
+
+	aiService := service.AIService{}
+	if agent != nil {
+		aiService = *newTestAIService(service.CircuitBreakerConfig{}, agent)
+	}
+
+	return service.NewEventService(
+		aiService, *userService, map[model.Provider]service.CalendarService{model.ProviderGoogle: calendar},
+		drafts, &fakeScheduledEvents{}, &fakePreferences{},
+	)
+}
+
+func TestEventService_CreateDraftsFromICS(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []model.Event
+		wantErr error
+	}{
+		{
+			name: "invite and floating event",
+			data: "BEGIN:VCALENDAR\nMETHOD:REQUEST\n" +
+				"BEGIN:VEVENT\nUID:1\nDTSTART:20250304T170000Z\nDTEND:20250304T180000Z\n" +
+				"SUMMARY:Design review\nCATEGORIES:Work\nEND:VEVENT\n" +
+				"BEGIN:VEVENT\nUID:2\nDTSTART:20250305T090000\nSUMMARY:Birthday breakfast\n" +
+				"CATEGORIES:Birthday\nEND:VEVENT\n" +
+				"END:VCALENDAR\n",
+			want: []model.Event{
+				{
+					Title:     "Design review",
+					Start:     time.Date(2025, 3, 4, 18, 0, 0, 0, time.UTC),
+					End:       time.Date(2025, 3, 4, 19, 0, 0, 0, time.UTC),
+					EventType: "event",
+					TimeZone:  "Europe/Berlin",
+				},
+				{
+					Title:     "Birthday breakfast",
+					Start:     time.Date(2025, 3, 5, 9, 0, 0, 0, time.UTC),
+					End:       time.Date(2025, 3, 5, 9, 0, 0, 0, time.UTC),
+					EventType: "birthday",
+					TimeZone:  "Europe/Berlin",
+				},
+			},
+		},
+		{
+			name:    "malformed file",
+			data:    "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:tomorrow\nEND:VEVENT\nEND:VCALENDAR\n",
+			wantErr: service.ErrInvalidCalendarFile,
+		},
+		{
+			name: "unsupported recurrence",
+			data: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20250304T170000Z\nSUMMARY:Water the plants\n" +
+				"RRULE:FREQ=HOURLY\nEND:VEVENT\nEND:VCALENDAR\n",
+			wantErr: service.ErrInvalidCalendarFile,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &fakeUsers{user: storage.User{ID: eventUserID, TelegramID: eventTelegramID, TimeZone: "Europe/Berlin"}}
+			accounts := &fakeLinkedAccounts{accounts: map[model.Provider]storage.LinkedAccount{
+				model.ProviderGoogle: {UserID: eventUserID, Provider: model.ProviderGoogle, Status: storage.LinkedAccountActive},
+			}}
+			tokenServices := map[model.Provider]service.TokenService{model.ProviderGoogle: nil}
+			userService := service.NewUserService(users, accounts, tokenServices, nil)
+			eventService := service.NewEventService(
+				service.AIService{}, *userService, nil, &fakeDrafts{}, &fakeScheduledEvents{}, &fakePreferences{},
+			)
+
+			drafts, err := eventService.CreateDraftsFromICS(eventTelegramID, 42, []byte(tt.data))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CreateDraftsFromICS() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("CreateDraftsFromICS() error = %v", err)
+			}
+
+			if len(drafts) != len(tt.want) {
+				t.Fatalf("CreateDraftsFromICS() returned %d drafts, want %d", len(drafts), len(tt.want))
+			}
+			for i, draft := range drafts {
+				if !reflect.DeepEqual(draft.Event, tt.want[i]) {
+					t.Errorf("draft %d event = %+v, want %+v", i, draft.Event, tt.want[i])
+				}
+				if draft.Provider != model.ProviderGoogle || draft.Status != storage.DraftStatusPending {
+					t.Errorf("draft %d = %s %s, want a pending %s draft", i, draft.Provider, draft.Status, model.ProviderGoogle)
+				}
+				if !reflect.DeepEqual(draft.SourceMessageIDs, []int{42}) {
+					t.Errorf("draft %d source messages = %v, want [42]", i, draft.SourceMessageIDs)
+				}
+			}
+		})
+	}
+}
+
+type fakeCalendar struct {
+	provider model.Provider
+	err      error
+	events   []model.ScheduledEvent
+	created  int
+}
+
+func (c *fakeCalendar) ListCalendars(userID int) ([]model.Calendar, error) {
+	return nil, c.err
+}
+
+func (c *fakeCalendar) CreateEvent(userID int, calendarID string, event *model.Event) (model.ScheduledEvent, error) {
+	if c.err != nil {
+		return model.ScheduledEvent{}, c.err
+	}
+	c.created++
+	return model.ScheduledEvent{ID: "event", Provider: c.provider, CalendarID: calendarID, Event: *event}, nil
+}
+
+func (c *fakeCalendar) UpdateEvent(
+	userID int,
+	calendarID string,
+	eventID string,
+	event *model.Event,
+) (model.ScheduledEvent, error) {
+	if c.err != nil {
+		return model.ScheduledEvent{}, c.err
+	}
+	return model.ScheduledEvent{ID: eventID, Provider: c.provider, CalendarID: calendarID, Event: *event}, nil
+}
+
+func (c *fakeCalendar) DeleteEvent(userID int, calendarID string, eventID string) error {
+	return c.err
+}
+
+func (c *fakeCalendar) ListEvents(userID int, calendarID string, from time.Time, to time.Time) ([]model.ScheduledEvent, error) {
+	return c.events, c.err
+}
+
+type fakeUsers struct {
+	user storage.User
+}
+
+func (r *fakeUsers) GetByID(id int) (storage.User, error) {
+	if id != r.user.ID {
+		return storage.User{}, model.NotFoundError{Message: "user not found"}
+	}
+	return r.user, nil
+}
+
+func (r *fakeUsers) GetByTelegramID(telegramID int64) (storage.User, error) {
+	if telegramID != r.user.TelegramID {
+		return storage.User{}, model.NotFoundError{Message: "user not found"}
+	}
+	return r.user, nil
+}
+
+func (r *fakeUsers) GetByFeedToken(token string) (storage.User, error) {
+	if token != r.user.FeedToken {
+		return storage.User{}, model.NotFoundError{Message: "user not found"}
+	}
+	return r.user, nil
+}
+
+func (r *fakeUsers) Save(user *storage.User) error {
+	r.user = *user
+	return nil
+}
+
+func (r *fakeUsers) UpdateTimeZone(id int, timeZone string) error {
+	r.user.TimeZone = timeZone
+	return nil
+}
+
+func (r *fakeUsers) UpdateCalendarProviders(id int, defaultProvider model.Provider, allProviders bool) error {
+	r.user.DefaultProvider = defaultProvider
+	r.user.AllProviders = allProviders
+	return nil
+}
+
+func (r *fakeUsers) UpdateFeedToken(id int, token string) error {
+	r.user.FeedToken = token
+	return nil
+}
+
+// fakeDrafts holds a single draft.
+type fakeDrafts struct {
+	draft storage.EventDraft
+}
+
+func (r *fakeDrafts) Save(draft *storage.EventDraft) error {
+	r.draft = *draft
+	return nil
+}
+
+func (r *fakeDrafts) GetByID(id int) (storage.EventDraft, error) {
+	if id != r.draft.ID {
+		return storage.EventDraft{}, model.NotFoundError{Message: "draft not found"}
+	}
+	return r.draft, nil
+}
+
+func (r *fakeDrafts) GetByMessageID(chatID int64, messageID int) (storage.EventDraft, error) {
+	if chatID != r.draft.ChatID || messageID != r.draft.MessageID {
+		return storage.EventDraft{}, model.NotFoundError{Message: "draft not found"}
+	}
+	return r.draft, nil
+}
+
+func (r *fakeDrafts) ListByBatchID(batchID string) ([]storage.EventDraft, error) {
+	return []storage.EventDraft{r.draft}, nil
+}
+
+func (r *fakeDrafts) GetLatestActiveBatchID(userID int) (string, error) {
+	return r.draft.BatchID, nil
+}
+
+func (r *fakeDrafts) UpdateStatus(id int, from storage.EventDraftStatus, to storage.EventDraftStatus) (bool, error) {
+	if id != r.draft.ID || r.draft.Status != from {
+		return false, nil
+	}
+	r.draft.Status = to
+	return true, nil
+}
+
+func (r *fakeDrafts) DiscardPendingByProvider(userID int, provider model.Provider) ([]storage.EventDraft, error) {
+	return nil, nil
+}
+
+// staleDrafts reads the draft as it was before another tap claimed it.
+type staleDrafts struct {
+	fakeDrafts
+	read storage.EventDraft
+}
+
+func (r *staleDrafts) GetByID(id int) (storage.EventDraft, error) {
+	return r.read, nil
+}
+
+type fakeScheduledEvents struct {
+	mu        sync.Mutex
+	events    []storage.ScheduledEvent
+	deleteErr error
+}
+
+func (r *fakeScheduledEvents) Save(event *storage.ScheduledEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event.ID = len(r.events) + 1
+	r.events = append(r.events, *event)
+	return nil
+}
+
+func (r *fakeScheduledEvents) ListByCardMessageID(chatID int64, messageID int) ([]storage.ScheduledEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []storage.ScheduledEvent
+	for _, event := range r.events {
+		if event.SourceChatID == chatID && event.CardMessageID == messageID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (r *fakeScheduledEvents) ListByBatchID(batchID string) ([]storage.ScheduledEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []storage.ScheduledEvent
+	for _, event := range r.events {
+		if event.BatchID == batchID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (r *fakeScheduledEvents) ListByUserID(userID int) ([]storage.ScheduledEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []storage.ScheduledEvent
+	for _, event := range r.events {
+		if event.UserID == userID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (r *fakeScheduledEvents) ListByUserIDBetween(userID int, from time.Time, to time.Time) ([]storage.ScheduledEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []storage.ScheduledEvent
+	for _, event := range r.events {
+		if event.UserID == userID && !event.Event.End.Before(from) && !event.Event.Start.After(to) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (r *fakeScheduledEvents) Delete(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, event := range r.events {
+		if event.ID == id {
+			r.events = append(r.events[:i], r.events[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (r *fakeScheduledEvents) DeleteByProvider(userID int, provider model.Provider) ([]storage.ScheduledEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.deleteErr != nil {
+		return nil, r.deleteErr
+	}
+
+	var deleted, kept []storage.ScheduledEvent
+	for _, event := range r.events {
+		if event.UserID == userID && event.Provider == provider {
+			deleted = append(deleted, event)
+		} else {
+			kept = append(kept, event)
+		}
+	}
+	r.events = kept
+	return deleted, nil
+}
+
+type fakePreferences struct {
+	preferences []storage.CalendarPreference
+}
+
+func (r *fakePreferences) Save(preference storage.CalendarPreference) error {
+	r.preferences = append(r.preferences, preference)
+	return nil
+}
+
+func (r *fakePreferences) ListByUserID(userID int) ([]storage.CalendarPreference, error) {
+	return r.preferences, nil
+}
+
+func (r *fakePreferences) Delete(userID int, provider model.Provider, eventType string) error {
+	return nil
+}
+
+func (r *fakePreferences) DeleteByProvider(userID int, provider model.Provider) error {
+	return nil
+}
@@ -0,0 +1,161 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ivgag/schedulr/ical"
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/storage"
+)
+
+// FeedConfig configures the calendar feeds users subscribe to.
+type FeedConfig struct {
+	// BaseURL is the public address of the REST server, e.g. "https://schedulr.example".
+	BaseURL string `mapstructure:"base_url"`
+}
+
+func NewFeedService(
+	config FeedConfig,
+	userRepository storage.UserRepository,
+	scheduledEventRepository storage.ScheduledEventRepository,
+) *FeedService {
+	return &FeedService{
+		baseURL:                  strings.TrimRight(config.BaseURL, "/"),
+		userRepository:           userRepository,
+		scheduledEventRepository: scheduledEventRepository,
+	}
+}
+
+// FeedService serves the events of each user as an iCalendar feed at a secret URL,
+// which calendar apps subscribe to without linking an account.
+type FeedService struct {
+	baseURL                  string
+	userRepository           storage.UserRepository
+	scheduledEventRepository storage.ScheduledEventRepository
+}
+
+// Feed is the rendered calendar feed of a user.
+type Feed struct {
+	Data []byte
+	// ETag changes whenever the content of the feed does.
+	ETag string
+}
+
+// FeedURL returns the URL of the user's feed, turning the feed on if it is off.
+func (s *FeedService) FeedURL(telegramID int64) (string, error) {
+	user, err := s.userRepository.GetByTelegramID(telegramID)
+	if err != nil {
+		return "", err
+	} else if user.FeedToken != "" {
+		return s.feedURL(user.FeedToken), nil
+	}
+	return s.newFeedToken(user)
+}
+
+// RotateFeedURL gives the user's feed a new URL. Apps subscribed to the old one lose access.
+func (s *FeedService) RotateFeedURL(telegramID int64) (string, error) {
+	user, err := s.userRepository.GetByTelegramID(telegramID)
+	if err != nil {
+		return "", err
+	}
+	return s.newFeedToken(user)
+}
+
+// DisableFeed turns the user's feed off until FeedURL is asked for again.
+func (s *FeedService) DisableFeed(telegramID int64) error {
+	user, err := s.userRepository.GetByTelegramID(telegramID)
+	if err != nil {
+		return err
+	}
+	return s.userRepository.UpdateFeedToken(user.ID, "")
+}
+
+// Feed renders the feed with the given token from the events the user has created.
+// Events written to several providers appear once.
+func (s *FeedService) Feed(token string) (Feed, error) {
+	if token == "" {
+		return Feed{}, model.NotFoundError{Message: "feed not found"}
+	}
+
+	user, err := s.userRepository.GetByFeedToken(token)
+	if err != nil {
+		return Feed{}, err
+	}
+
+	scheduled, err := s.scheduledEventRepository.ListByUserID(user.ID)
+	if err != nil {
+		return Feed{}, err
+	}
+
+	type card struct {
+		chatID    int64
+		messageID int
+	}
+	seen := make(map[card]bool)
+	var events []ical.Event
+	for _, event := range scheduled {
+		if event.CardMessageID != 0 {
+			key := card{chatID: event.SourceChatID, messageID: event.CardMessageID}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+
+		events = append(events, ical.Event{
+			UID:    fmt.Sprintf("feed-%d@schedulr", event.ID),
+			Stamp:  event.CreatedAt,
+			Alarms: icsAlarms(event.Event),
+			Event:  event.Event,
+		})
+	}
+
+	data, err := ical.Encode(events...)
+	if err != nil {
+		return Feed{}, err
+	}
+
+	sum := sha256.Sum256(data)
+	return Feed{Data: data, ETag: `"` + hex.EncodeToString(sum[:16]) + `"`}, nil
+}
+
+func (s *FeedService) newFeedToken(user storage.User) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(secret)
+	if err := s.userRepository.UpdateFeedToken(user.ID, token); err != nil {
+		return "", err
+	}
+	return s.feedURL(token), nil
+}
+
+func (s *FeedService) feedURL(token string) string {
+	return s.baseURL + "/feeds/" + token + ".ics"
+}
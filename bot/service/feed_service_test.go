@@ -0,0 +1,101 @@
+package service_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivgag/schedulr/ical"
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/service"
+	"github.com/ivgag/schedulr/storage"
+)
+
+func TestFeedService(t *testing.T) {
+	users := &fakeUsers{user: storage.User{ID: eventUserID, TelegramID: eventTelegramID}}
+	events := &fakeScheduledEvents{}
+	feeds := service.NewFeedService(service.FeedConfig{BaseURL: "https://schedulr.example/"}, users, events)
+
+	concert := model.Event{
+		Title:    "Concert",
+		Start:    time.Date(2025, 3, 4, 19, 0, 0, 0, time.UTC),
+		End:      time.Date(2025, 3, 4, 21, 0, 0, 0, time.UTC),
+		TimeZone: "Europe/Berlin",
+	}
+	// The concert was written to two providers and is shown in a single card.
+	for _, event := range []storage.ScheduledEvent{
+		{UserID: eventUserID, Provider: model.ProviderGoogle, SourceChatID: eventTelegramID, CardMessageID: 7, Event: concert},
+		{UserID: eventUserID, Provider: model.ProviderICS, SourceChatID: eventTelegramID, CardMessageID: 7, Event: concert},
+		{UserID: eventUserID, Provider: model.ProviderICS, Event: model.Event{
+			Title:  "Festival",
+			Start:  time.Date(2025, 7, 11, 0, 0, 0, 0, time.UTC),
+			End:    time.Date(2025, 7, 13, 0, 0, 0, 0, time.UTC),
+			AllDay: true,
+		}},
+		{UserID: eventUserID + 1, Provider: model.ProviderGoogle, Event: model.Event{Title: "Someone else's"}},
+	} {
+		if err := events.Save(&event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	url, err := feeds.FeedURL(eventTelegramID)
+	if err != nil {
+		t.Fatalf("FeedURL() error = %v", err)
+	}
+	token, ok := strings.CutPrefix(url, "https://schedulr.example/feeds/")
+	if !ok || !strings.HasSuffix(token, ".ics") || len(token) < 40 {
+		t.Fatalf("FeedURL() = %q, want a secret URL of an .ics file", url)
+	}
+	token = strings.TrimSuffix(token, ".ics")
+
+	if again, err := feeds.FeedURL(eventTelegramID); err != nil || again != url {
+		t.Errorf("FeedURL() again = %q, %v, want %q", again, err, url)
+	}
+
+	feed, err := feeds.Feed(token)
+	if err != nil {
+		t.Fatalf("Feed() error = %v", err)
+	}
+	decoded, err := ical.Decode(feed.Data)
+	if err != nil {
+		t.Fatalf("feed is not a calendar: %v", err)
+	}
+	var titles []string
+	for _, event := range decoded {
+		titles = append(titles, event.Title)
+	}
+	if strings.Join(titles, ", ") != "Concert, Festival" {
+		t.Errorf("feed events = %v, want Concert and Festival", titles)
+	}
+
+	if again, err := feeds.Feed(token); err != nil || again.ETag != feed.ETag {
+		t.Errorf("ETag of an unchanged feed = %q, want %q", again.ETag, feed.ETag)
+	}
+	if err := events.Delete(3); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := feeds.Feed(token); err != nil || changed.ETag == feed.ETag {
+		t.Errorf("ETag of a changed feed = %q, want a new one", changed.ETag)
+	}
+
+	rotated, err := feeds.RotateFeedURL(eventTelegramID)
+	if err != nil {
+		t.Fatalf("RotateFeedURL() error = %v", err)
+	} else if rotated == url {
+		t.Errorf("RotateFeedURL() kept the URL %q", url)
+	}
+	var notFound model.NotFoundError
+	if _, err := feeds.Feed(token); !errors.As(err, &notFound) {
+		t.Errorf("Feed() with the old token error = %v, want not found", err)
+	}
+
+	if err := feeds.DisableFeed(eventTelegramID); err != nil {
+		t.Fatalf("DisableFeed() error = %v", err)
+	}
+	rotatedToken := strings.TrimSuffix(strings.TrimPrefix(rotated, "https://schedulr.example/feeds/"), ".ics")
+	if _, err := feeds.Feed(rotatedToken); !errors.As(err, &notFound) {
+		t.Errorf("Feed() of a disabled feed error = %v, want not found", err)
+	}
+}
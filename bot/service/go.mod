@@ -4,6 +4,7 @@ go 1.23.3
 
 replace (
 	github.com/ivgag/schedulr/ai v0.0.0 => ../ai
+	github.com/ivgag/schedulr/ical v0.0.0 => ../ical
 	github.com/ivgag/schedulr/model v0.0.0 => ../model
 	github.com/ivgag/schedulr/storage v0.0.0 => ../storage
 	github.com/ivgag/schedulr/utils v0.0.0 => ../utils
@@ -13,9 +14,11 @@ require (
 	github.com/cenkalti/backoff/v5 v5.0.2
 	github.com/gofrs/uuid v4.4.0+incompatible
 	github.com/ivgag/schedulr/ai v0.0.0
+	github.com/ivgag/schedulr/ical v0.0.0
 	github.com/ivgag/schedulr/model v0.0.0
 	github.com/ivgag/schedulr/storage v0.0.0
 	github.com/rs/zerolog v1.33.0
+	github.com/zsefvlol/timezonemapper v1.0.0
 	golang.org/x/oauth2 v0.26.0
 	google.golang.org/api v0.222.0
 )
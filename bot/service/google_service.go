@@ -21,129 +21,112 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 	_ "time/tzdata"
 
 	"github.com/cenkalti/backoff/v5"
-	"github.com/gofrs/uuid"
 	"github.com/ivgag/schedulr/model"
 	"github.com/ivgag/schedulr/storage"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 )
 
-var stateTokens = make(map[string]int)
-var callbacks = make(map[string]func(error))
+const (
+	primaryCalendarID = "primary"
+	googleRevokeURL   = "https://oauth2.googleapis.com/revoke"
+	// googleEventKeyProperty is the private extended property that tags the events
+	// created by the bot with the event's dedupe key.
+	googleEventKeyProperty = "schedulrKey"
+)
 
 // NewGoogleTokenService creates a new TokenService.
 func NewGoogleTokenService(
 	config *GoogleConfig,
+	stateService *OAuthStateService,
 	linkedAccountsRepository storage.LinkedAccountRepository,
 ) *GoogleTokenService {
 	oauth2Config := &oauth2.Config{
 		ClientID:     config.ClientID,
 		ClientSecret: config.ClientSecret,
 		RedirectURL:  config.RedirectURL,
-		Endpoint:     google.Endpoint,
+		Endpoint:     googleEndpoint(config),
 		Scopes:       []string{calendar.CalendarScope},
 	}
 
+	revokeURL := config.RevokeURL
+	if revokeURL == "" {
+		revokeURL = googleRevokeURL
+	}
+
 	return &GoogleTokenService{
-		oauth2Config:             oauth2Config,
-		linkedAccountsRepository: linkedAccountsRepository,
+		oauth2TokenService: &oauth2TokenService{
+			provider:     model.ProviderGoogle,
+			oauth2Config: oauth2Config,
+			// Google issues a refresh token only with offline access, and only on the first consent
+			// unless it is asked again.
+			authCodeOptions: []oauth2.AuthCodeOption{
+				oauth2.AccessTypeOffline,
+				oauth2.SetAuthURLParam("prompt", "consent"),
+			},
+			stateService:             stateService,
+			linkedAccountsRepository: linkedAccountsRepository,
+		},
+		revokeURL:   revokeURL,
+		calendarURL: config.CalendarURL,
 	}
 }
 
 // GoogleTokenService encapsulates OAuth2 token logic.
 type GoogleTokenService struct {
-	oauth2Config             *oauth2.Config
-	linkedAccountsRepository storage.LinkedAccountRepository
+	*oauth2TokenService
+	revokeURL   string
+	calendarURL string
 }
 
-// GetOAuth2URL returns the URL to redirect users for Google OAuth2 consent.
-func (s *GoogleTokenService) GetOAuth2URL(userID int, callback func(error)) (string, error) {
-	uuid, err := uuid.NewV7()
+// RevokeToken revokes the user's grant, so that Google no longer lists Schedulr
+// among the apps with access to the account. Grants that are already revoked are not an error.
+func (s *GoogleTokenService) RevokeToken(userID int) error {
+	account, err := s.linkedAccountsRepository.GetByUserIDAndProvider(userID, model.ProviderGoogle)
 	if err != nil {
-		return "", err
+		return err
+	} else if account.Status == storage.LinkedAccountBroken {
+		return nil
 	}
 
-	state := uuid.String()
-	stateTokens[state] = userID
-	callbacks[state] = callback
-
-	return s.oauth2Config.AuthCodeURL(
-		state,
-		oauth2.AccessTypeOffline,
-		oauth2.SetAuthURLParam("prompt", "consent"),
-	), nil
-}
-
-// ExchangeCodeForToken exchanges an authorization code for a token.
-func (s *GoogleTokenService) ExchangeCodeForToken(state string, code string) error {
-	usedID, found := stateTokens[state]
-	if !found {
-		return fmt.Errorf("state not found: %s", state)
+	// Revoking the refresh token revokes the access tokens issued for it as well.
+	token := account.RefreshToken
+	if token == "" {
+		token = account.AccessToken
 	}
-	delete(stateTokens, state)
 
-	gToken, err := s.oauth2Config.Exchange(context.Background(), code)
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.PostForm(s.revokeURL, url.Values{"token": {token}})
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
-	err = s.linkedAccountsRepository.Save(storage.LinkedAccount{
-		UserID:       usedID,
-		Provider:     model.ProviderGoogle,
-		AccessToken:  gToken.AccessToken,
-		RefreshToken: gToken.RefreshToken,
-		Expiry:       gToken.Expiry.UTC(),
-	})
-
-	callback := callbacks[state]
-	callback(err)
-	delete(callbacks, state)
-
-	return err
-}
-
-// ClientFromToken creates an HTTP client authenticated with the given token.
-func (s *GoogleTokenService) ClientForUser(userID int) (*http.Client, error) {
-	account, err := s.linkedAccountsRepository.GetByUserIDAndProvider(userID, model.ProviderGoogle)
-	if err != nil {
-		return nil, err
+	if resp.StatusCode == http.StatusOK {
+		return nil
 	}
 
-	if time.Now().UTC().After(account.Expiry.UTC()) {
-		tokenSource := s.oauth2Config.TokenSource(context.Background(), &oauth2.Token{
-			RefreshToken: account.RefreshToken,
-		})
-		newToken, err := tokenSource.Token()
-		if err != nil {
-			return nil, err
-		}
-
-		account.AccessToken = newToken.AccessToken
-		account.RefreshToken = newToken.RefreshToken
-		account.Expiry = newToken.Expiry.UTC()
-
-		err = s.linkedAccountsRepository.Save(account)
-		if err != nil {
-			return nil, err
-		}
+	var body struct {
+		Error string `json:"error"`
 	}
-
-	oauthToken := &oauth2.Token{
-		AccessToken:  account.AccessToken,
-		RefreshToken: account.RefreshToken,
-		Expiry:       account.Expiry,
-		TokenType:    "Bearer",
+	if json.NewDecoder(resp.Body).Decode(&body) == nil && body.Error == "invalid_token" {
+		return nil
 	}
-	return s.oauth2Config.Client(context.Background(), oauthToken), nil
+	return fmt.Errorf("token revocation failed with status %d %s", resp.StatusCode, body.Error)
 }
 
 // CalendarService handles calendar-related operations.
@@ -156,58 +139,225 @@ func NewGoogleCalendarService(tokenService *GoogleTokenService) *GoogleCalendarS
 	return &GoogleCalendarService{tokenService: tokenService}
 }
 
-// CreateEvent creates a new calendar event using the provided token and event data.
-func (c *GoogleCalendarService) CreateEvent(userID int, event *model.Event) (model.ScheduledEvent, error) {
-	client, err := c.tokenService.ClientForUser(userID)
+// ListCalendars implements CalendarService. Calendars the user can only read are left out.
+func (c *GoogleCalendarService) ListCalendars(userID int) ([]model.Calendar, error) {
+	srv, err := c.calendarForUser(userID)
 	if err != nil {
-		return model.ScheduledEvent{}, err
+		return nil, err
+	}
+
+	var calendars []model.Calendar
+	err = srv.CalendarList.List().MinAccessRole("writer").Pages(
+		context.Background(),
+		func(list *calendar.CalendarList) error {
+			for _, item := range list.Items {
+				name := item.SummaryOverride
+				if name == "" {
+					name = item.Summary
+				}
+				calendars = append(calendars, model.Calendar{ID: item.Id, Name: name, Primary: item.Primary})
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		log.Error().
+			Int("userID", userID).
+			Err(err).
+			Msg("Failed to list calendars")
+
+		return nil, c.tokenService.checkGrantError(userID, err)
 	}
 
-	ctx := context.Background()
+	return calendars, nil
+}
+
+// CreateEvent creates a new calendar event using the provided token and event data.
+func (c *GoogleCalendarService) CreateEvent(userID int, calendarID string, event *model.Event) (model.ScheduledEvent, error) {
+	if calendarID == "" {
+		calendarID = primaryCalendarID
+	}
 
-	srv, err := calendar.NewService(ctx, option.WithHTTPClient(client))
+	srv, calEvent, err := c.prepareEvent(userID, calendarID, event)
 	if err != nil {
 		return model.ScheduledEvent{}, err
 	}
 
-	cal, err := srv.Calendars.Get("primary").Do()
+	createdEvent, err := doWithRetries(srv.Events.Insert(calendarID, calEvent).Do)
 	if err != nil {
-		return model.ScheduledEvent{}, err
+		log.Error().
+			Interface("event", calEvent).
+			Err(err).
+			Msg("Failed to create event")
+
+		return model.ScheduledEvent{}, c.tokenService.checkGrantError(userID, err)
 	}
 
-	calEvent, err := toGoogleCalendarEvent(event, cal.TimeZone)
+	return toScheduledEvent(createdEvent, calendarID, event), nil
+}
+
+// UpdateEvent patches an existing calendar event with the new event data.
+func (c *GoogleCalendarService) UpdateEvent(
+	userID int,
+	calendarID string,
+	eventID string,
+	event *model.Event,
+) (model.ScheduledEvent, error) {
+	srv, calEvent, err := c.prepareEvent(userID, calendarID, event)
 	if err != nil {
 		return model.ScheduledEvent{}, err
 	}
 
-	link, err := c.insertEventWithRetries(srv, calEvent)
+	updatedEvent, err := doWithRetries(srv.Events.Patch(calendarID, eventID, calEvent).Do)
 	if err != nil {
 		log.Error().
+			Str("eventID", eventID).
 			Interface("event", calEvent).
 			Err(err).
-			Msg("Failed to create event")
+			Msg("Failed to update event")
 
-		return model.ScheduledEvent{}, err
+		return model.ScheduledEvent{}, c.tokenService.checkGrantError(userID, err)
 	}
 
-	return model.ScheduledEvent{
-		Event: *event,
-		Link:  link,
-	}, nil
+	return toScheduledEvent(updatedEvent, calendarID, event), nil
+}
+
+// DeleteEvent removes the event from the calendar. Events that are already gone are not an error.
+func (c *GoogleCalendarService) DeleteEvent(userID int, calendarID string, eventID string) error {
+	srv, err := c.calendarForUser(userID)
+	if err != nil {
+		return err
+	}
+
+	operation := func() (struct{}, error) {
+		err := srv.Events.Delete(calendarID, eventID).Do()
+
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && (apiErr.Code == http.StatusNotFound || apiErr.Code == http.StatusGone) {
+			return struct{}{}, nil
+		} else if _, ok := grantErrorReason(err); ok {
+			return struct{}{}, backoff.Permanent(err)
+		}
+		return struct{}{}, err
+	}
+
+	_, err = backoff.Retry(
+		context.Background(),
+		operation,
+		backoff.WithBackOff(backoff.NewExponentialBackOff()),
+		backoff.WithMaxTries(3),
+	)
+	if err != nil {
+		log.Error().
+			Str("eventID", eventID).
+			Err(err).
+			Msg("Failed to delete event")
+
+		return c.tokenService.checkGrantError(userID, err)
+	}
+
+	return nil
+}
+
+// ListEvents implements CalendarService.
+func (c *GoogleCalendarService) ListEvents(
+	userID int,
+	calendarID string,
+	from time.Time,
+	to time.Time,
+) ([]model.ScheduledEvent, error) {
+	if calendarID == "" {
+		calendarID = primaryCalendarID
+	}
+
+	srv, err := c.calendarForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []model.ScheduledEvent
+	err = srv.Events.List(calendarID).
+		TimeMin(from.Format(time.RFC3339)).
+		TimeMax(to.Format(time.RFC3339)).
+		SingleEvents(true).
+		Pages(context.Background(), func(list *calendar.Events) error {
+			for _, item := range list.Items {
+				if event, ok := fromGoogleCalendarEvent(item); ok {
+					events = append(events, model.ScheduledEvent{
+						ID:         item.Id,
+						Provider:   model.ProviderGoogle,
+						CalendarID: calendarID,
+						Event:      event,
+						Link:       item.HtmlLink,
+						Key:        googleEventKey(item),
+					})
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		log.Error().
+			Int("userID", userID).
+			Err(err).
+			Msg("Failed to list events")
+
+		return nil, c.tokenService.checkGrantError(userID, err)
+	}
 
+	return events, nil
 }
 
-func (c *GoogleCalendarService) insertEventWithRetries(
-	srv *calendar.Service,
-	event *calendar.Event,
-) (string, error) {
-	operation := func() (string, error) {
-		createdEvent, err := srv.Events.Insert("primary", event).Do()
+// calendarForUser creates a calendar client authorized as the user.
+func (c *GoogleCalendarService) calendarForUser(userID int) (*calendar.Service, error) {
+	client, err := c.tokenService.ClientForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []option.ClientOption{option.WithHTTPClient(client)}
+	if c.tokenService.calendarURL != "" {
+		opts = append(opts, option.WithEndpoint(c.tokenService.calendarURL))
+	}
+	return calendar.NewService(context.Background(), opts...)
+}
+
+// prepareEvent creates a calendar client for the user and converts the event
+// into the calendar's time zone.
+func (c *GoogleCalendarService) prepareEvent(
+	userID int,
+	calendarID string,
+	event *model.Event,
+) (*calendar.Service, *calendar.Event, error) {
+	srv, err := c.calendarForUser(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Events without their own time zone are given in the calendar's one.
+	timezone := event.TimeZone
+	if timezone == "" {
+		cal, err := srv.Calendars.Get(calendarID).Do()
 		if err != nil {
-			return "", err
+			return nil, nil, c.tokenService.checkGrantError(userID, err)
 		}
+		timezone = cal.TimeZone
+	}
 
-		return createdEvent.HtmlLink, nil
+	calEvent, err := toGoogleCalendarEvent(event, timezone)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return srv, calEvent, nil
+}
+
+func doWithRetries(call func(opts ...googleapi.CallOption) (*calendar.Event, error)) (*calendar.Event, error) {
+	operation := func() (*calendar.Event, error) {
+		calEvent, err := call()
+		if _, ok := grantErrorReason(err); ok {
+			return nil, backoff.Permanent(err)
+		}
+		return calEvent, err
 	}
 
 	return backoff.Retry(
@@ -218,6 +368,17 @@ func (c *GoogleCalendarService) insertEventWithRetries(
 	)
 }
 
+func toScheduledEvent(calEvent *calendar.Event, calendarID string, event *model.Event) model.ScheduledEvent {
+	return model.ScheduledEvent{
+		ID:         calEvent.Id,
+		Provider:   model.ProviderGoogle,
+		CalendarID: calendarID,
+		Event:      *event,
+		Link:       calEvent.HtmlLink,
+		Key:        googleEventKey(calEvent),
+	}
+}
+
 func toGoogleCalendarEvent(event *model.Event, timezone string) (*calendar.Event, error) {
 	loc, err := time.LoadLocation(timezone)
 	if err != nil {
@@ -229,28 +390,176 @@ func toGoogleCalendarEvent(event *model.Event, timezone string) (*calendar.Event
 		return nil, err
 	}
 
-	startTime := toLocalTime(event.Start, loc)
-	endTime := toLocalTime(event.End, loc)
+	recurrence, err := toGoogleRecurrence(event, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	var start, end *calendar.EventDateTime
+	if event.AllDay {
+		start, end = toGoogleAllDay(event)
+	} else {
+		start = &calendar.EventDateTime{
+			DateTime: toLocalTime(event.Start, loc).Format(time.RFC3339),
+			TimeZone: timezone,
+			// Clear the date when an all-day event is patched into a timed one.
+			NullFields: []string{"Date"},
+		}
+		end = &calendar.EventDateTime{
+			DateTime:   toLocalTime(event.End, loc).Format(time.RFC3339),
+			TimeZone:   timezone,
+			NullFields: []string{"Date"},
+		}
+	}
 
 	return &calendar.Event{
 		Summary:     event.Title,
 		Location:    event.Location,
 		Description: event.Description,
-		Start: &calendar.EventDateTime{
-			DateTime: startTime.Format(time.RFC3339),
-			TimeZone: timezone,
-		},
-		End: &calendar.EventDateTime{
-			DateTime: endTime.Format(time.RFC3339),
-			TimeZone: timezone,
+		Start:       start,
+		End:         end,
+		Recurrence:  recurrence,
+		// Send an empty recurrence too, so that patching a recurring event can make it single.
+		ForceSendFields: []string{"Recurrence"},
+		ExtendedProperties: &calendar.EventExtendedProperties{
+			Private: map[string]string{googleEventKeyProperty: EventKey(event)},
 		},
 	}, nil
 }
 
+// googleEventKey returns the dedupe key the bot tagged the event with, if any.
+func googleEventKey(calEvent *calendar.Event) string {
+	if calEvent.ExtendedProperties == nil {
+		return ""
+	}
+	return calEvent.ExtendedProperties.Private[googleEventKeyProperty]
+}
+
+// fromGoogleCalendarEvent converts a listed event. Cancelled occurrences and
+// events with unreadable times are skipped.
+func fromGoogleCalendarEvent(calEvent *calendar.Event) (model.Event, bool) {
+	if calEvent.Status == "cancelled" || calEvent.Start == nil || calEvent.End == nil {
+		return model.Event{}, false
+	}
+
+	event := model.Event{
+		Title:       calEvent.Summary,
+		Description: calEvent.Description,
+		Location:    calEvent.Location,
+	}
+
+	if calEvent.Start.Date != "" {
+		start, startErr := time.Parse(time.DateOnly, calEvent.Start.Date)
+		end, endErr := time.Parse(time.DateOnly, calEvent.End.Date)
+		if startErr != nil || endErr != nil {
+			return model.Event{}, false
+		}
+
+		// Google's end date is exclusive.
+		event.Start, event.End, event.AllDay = start, end.AddDate(0, 0, -1), true
+		return event, true
+	}
+
+	start, startErr := time.Parse(time.RFC3339, calEvent.Start.DateTime)
+	end, endErr := time.Parse(time.RFC3339, calEvent.End.DateTime)
+	if startErr != nil || endErr != nil {
+		return model.Event{}, false
+	}
+
+	event.Start, event.End, event.TimeZone = start.UTC(), end.UTC(), time.UTC.String()
+	return event, true
+}
+
+// toGoogleAllDay returns the dates of an all-day event. Google expects
+// an exclusive end date, while the event keeps the last day of the event.
+func toGoogleAllDay(event *model.Event) (*calendar.EventDateTime, *calendar.EventDateTime) {
+	startDate := toDate(event.Start)
+	endDate := toDate(event.End)
+	if endDate.Before(startDate) {
+		endDate = startDate
+	}
+
+	start := &calendar.EventDateTime{
+		Date:       startDate.Format(time.DateOnly),
+		NullFields: []string{"DateTime", "TimeZone"},
+	}
+	end := &calendar.EventDateTime{
+		Date:       endDate.AddDate(0, 0, 1).Format(time.DateOnly),
+		NullFields: []string{"DateTime", "TimeZone"},
+	}
+	return start, end
+}
+
+// toGoogleRecurrence converts the event's recurrence rule and exception dates
+// into the RRULE and EXDATE lines expected by Google Calendar.
+func toGoogleRecurrence(event *model.Event, loc *time.Location) ([]string, error) {
+	rule, ok, err := event.RRule()
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return []string{}, nil
+	}
+
+	if event.AllDay {
+		// All-day events recur by date, so UNTIL and EXDATE are dates as well.
+		if !rule.Until.IsZero() && !rule.UntilDate {
+			rule.Until = toDate(rule.Until.In(loc))
+			rule.UntilDate = true
+		}
+
+		recurrence := []string{"RRULE:" + rule.String()}
+		if len(event.ExceptionDates) > 0 {
+			dates := make([]string, len(event.ExceptionDates))
+			for i, date := range event.ExceptionDates {
+				dates[i] = date.Format("20060102")
+			}
+			recurrence = append(recurrence, "EXDATE;VALUE=DATE:"+strings.Join(dates, ","))
+		}
+		return recurrence, nil
+	}
+
+	// UNTIL must be a UTC date-time when the event has a start time.
+	if rule.UntilDate {
+		rule.Until = time.Date(rule.Until.Year(), rule.Until.Month(), rule.Until.Day(), 23, 59, 59, 0, loc)
+		rule.UntilDate = false
+	}
+
+	recurrence := []string{"RRULE:" + rule.String()}
+	if len(event.ExceptionDates) > 0 {
+		dates := make([]string, len(event.ExceptionDates))
+		for i, date := range event.ExceptionDates {
+			dates[i] = toLocalTime(date, loc).Format("20060102T150405")
+		}
+		recurrence = append(recurrence, "EXDATE;TZID="+loc.String()+":"+strings.Join(dates, ","))
+	}
+
+	return recurrence, nil
+}
+
 type GoogleConfig struct {
 	ClientID     string `mapstructure:"client_id"`
 	ClientSecret string `mapstructure:"client_secret"`
 	RedirectURL  string `mapstructure:"redirect_url"`
+	// AuthURL, TokenURL, RevokeURL and CalendarURL override Google's endpoints, e.g. to point to a stand-in server.
+	AuthURL     string `mapstructure:"auth_url"`
+	TokenURL    string `mapstructure:"token_url"`
+	RevokeURL   string `mapstructure:"revoke_url"`
+	CalendarURL string `mapstructure:"calendar_url"`
+}
+
+func googleEndpoint(config *GoogleConfig) oauth2.Endpoint {
+	endpoint := google.Endpoint
+	if config.AuthURL != "" {
+		endpoint.AuthURL = config.AuthURL
+	}
+	if config.TokenURL != "" {
+		endpoint.TokenURL = config.TokenURL
+	}
+	return endpoint
+}
+
+func toDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
 }
 
 func toLocalTime(t time.Time, loc *time.Location) time.Time {
@@ -0,0 +1,329 @@
+package service_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/service"
+	"github.com/ivgag/schedulr/storage"
+)
+
+const googleUserID = 8
+
+// fakeGoogle stands in for Google's token, revocation and Calendar API endpoints.
+type fakeGoogle struct {
+	mu           sync.Mutex
+	server       *httptest.Server
+	tokenStatus  int
+	tokenError   string
+	apiStatus    int
+	tokenCalls   int
+	revokeStatus int
+	revokeError  string
+	revoked      []string
+	events       []map[string]any
+}
+
+func newFakeGoogle(t *testing.T) *fakeGoogle {
+	google := &fakeGoogle{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		google.mu.Lock()
+		defer google.mu.Unlock()
+		google.tokenCalls++
+		w.Header().Set("Content-Type", "application/json")
+		if google.tokenStatus != 0 {
+			w.WriteHeader(google.tokenStatus)
+			json.NewEncoder(w).Encode(map[string]any{"error": google.tokenError, "error_description": "Token has been expired or revoked."})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "new-access-token",
+			"refresh_token": "refresh-token",
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+		})
+	})
+	mux.HandleFunc("POST /revoke", func(w http.ResponseWriter, r *http.Request) {
+		google.mu.Lock()
+		defer google.mu.Unlock()
+		google.revoked = append(google.revoked, r.FormValue("token"))
+		w.Header().Set("Content-Type", "application/json")
+		if google.revokeStatus != 0 {
+			w.WriteHeader(google.revokeStatus)
+			json.NewEncoder(w).Encode(map[string]any{"error": google.revokeError})
+			return
+		}
+		w.Write([]byte("{}"))
+	})
+	mux.HandleFunc("POST /calendar/v3/calendars/{calendarID}/events", func(w http.ResponseWriter, r *http.Request) {
+		google.mu.Lock()
+		defer google.mu.Unlock()
+		var event map[string]any
+		json.NewDecoder(r.Body).Decode(&event)
+		event["id"] = fmt.Sprintf("event-%d", len(google.events)+1)
+		google.events = append(google.events, event)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(event)
+	})
+	mux.HandleFunc("GET /calendar/v3/calendars/{calendarID}/events", func(w http.ResponseWriter, r *http.Request) {
+		google.mu.Lock()
+		defer google.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"items": google.events})
+	})
+	mux.HandleFunc("/calendar/v3/", func(w http.ResponseWriter, r *http.Request) {
+		google.mu.Lock()
+		defer google.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if google.apiStatus != 0 {
+			w.WriteHeader(google.apiStatus)
+			json.NewEncoder(w).Encode(map[string]any{
+				"error": map[string]any{"code": google.apiStatus, "message": http.StatusText(google.apiStatus)},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"items": []map[string]any{{"id": "primary", "summary": "Calendar", "primary": true}}})
+	})
+
+	google.server = httptest.NewServer(mux)
+	t.Cleanup(google.server.Close)
+	return google
+}
+
+// newGoogleCalendar links a Google account whose access token has expired when
+// expired is set, so that the first call refreshes it.
+func newGoogleCalendar(t *testing.T, expired bool) (*service.GoogleCalendarService, *service.GoogleTokenService, *fakeGoogle, *fakeLinkedAccounts) {
+	google := newFakeGoogle(t)
+
+	expiry := time.Now().Add(time.Hour)
+	if expired {
+		expiry = time.Now().Add(-time.Hour)
+	}
+	accounts := &fakeLinkedAccounts{accounts: map[model.Provider]storage.LinkedAccount{
+		model.ProviderGoogle: {
+			UserID:       googleUserID,
+			Provider:     model.ProviderGoogle,
+			AccessToken:  "access-token",
+			RefreshToken: "refresh-token",
+			Expiry:       expiry,
+			Status:       storage.LinkedAccountActive,
+		},
+	}}
+
+	tokenService := service.NewGoogleTokenService(&service.GoogleConfig{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		TokenURL:     google.server.URL + "/token",
+		RevokeURL:    google.server.URL + "/revoke",
+		CalendarURL:  google.server.URL + "/calendar/v3/",
+	}, nil, accounts)
+
+	return service.NewGoogleCalendarService(tokenService), tokenService, google, accounts
+}
+
+func TestGoogleTokenService_RefreshGrantErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		errorCode  string
+		wantRelink bool
+	}{
+		{name: "invalid_grant", status: http.StatusBadRequest, errorCode: "invalid_grant", wantRelink: true},
+		{name: "invalid_client", status: http.StatusUnauthorized, errorCode: "invalid_client", wantRelink: true},
+		{name: "unauthorized_client", status: http.StatusBadRequest, errorCode: "unauthorized_client", wantRelink: true},
+		{name: "invalid_request", status: http.StatusBadRequest, errorCode: "invalid_request"},
+		{name: "server error", status: http.StatusInternalServerError, errorCode: "internal_failure"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, tokenService, google, accounts := newGoogleCalendar(t, true)
+			google.tokenStatus = tt.status
+			google.tokenError = tt.errorCode
+
+			_, err := tokenService.ClientForUser(googleUserID)
+			if err == nil {
+				t.Fatal("ClientForUser() error = nil, want an error")
+			}
+
+			var relinkErr model.RelinkRequiredError
+			if relink := errors.As(err, &relinkErr); relink != tt.wantRelink {
+				t.Fatalf("ClientForUser() error = %v, want RelinkRequiredError %v", err, tt.wantRelink)
+			}
+
+			wantStatus := storage.LinkedAccountActive
+			if tt.wantRelink {
+				wantStatus = storage.LinkedAccountBroken
+				if relinkErr.Provider != model.ProviderGoogle || relinkErr.Reason != tt.errorCode+": Token has been expired or revoked." {
+					t.Errorf("RelinkRequiredError = %+v, want Google with the error code as reason", relinkErr)
+				}
+			}
+			if account, _ := accounts.GetByUserIDAndProvider(googleUserID, model.ProviderGoogle); account.Status != wantStatus {
+				t.Errorf("account status = %s, want %s", account.Status, wantStatus)
+			}
+		})
+	}
+}
+
+func TestGoogleCalendarService_APIErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		wantRelink bool
+	}{
+		{name: "unauthorized", status: http.StatusUnauthorized, wantRelink: true},
+		{name: "forbidden", status: http.StatusForbidden},
+		{name: "not found", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calendar, _, google, accounts := newGoogleCalendar(t, false)
+			google.apiStatus = tt.status
+
+			_, err := calendar.ListCalendars(googleUserID)
+			if err == nil {
+				t.Fatal("ListCalendars() error = nil, want an error")
+			}
+
+			var relinkErr model.RelinkRequiredError
+			if relink := errors.As(err, &relinkErr); relink != tt.wantRelink {
+				t.Fatalf("ListCalendars() error = %v, want RelinkRequiredError %v", err, tt.wantRelink)
+			}
+
+			wantStatus := storage.LinkedAccountActive
+			if tt.wantRelink {
+				wantStatus = storage.LinkedAccountBroken
+			}
+			if account, _ := accounts.GetByUserIDAndProvider(googleUserID, model.ProviderGoogle); account.Status != wantStatus {
+				t.Errorf("account status = %s, want %s", account.Status, wantStatus)
+			}
+		})
+	}
+}
+
+func TestGoogleTokenService_BrokenAccountRequiresRelink(t *testing.T) {
+	calendar, tokenService, google, accounts := newGoogleCalendar(t, true)
+	accounts.UpdateStatus(googleUserID, model.ProviderGoogle, storage.LinkedAccountBroken, "invalid_grant")
+
+	var relinkErr model.RelinkRequiredError
+	if _, err := tokenService.ClientForUser(googleUserID); !errors.As(err, &relinkErr) || relinkErr.Reason != "invalid_grant" {
+		t.Errorf("ClientForUser() error = %v, want a RelinkRequiredError with the stored reason", err)
+	}
+	if _, err := calendar.ListCalendars(googleUserID); !errors.As(err, &relinkErr) {
+		t.Errorf("ListCalendars() error = %v, want a RelinkRequiredError", err)
+	}
+	if google.tokenCalls != 0 {
+		t.Errorf("token endpoint called %d times, want none for a broken account", google.tokenCalls)
+	}
+}
+
+func TestGoogleTokenService_RevokeToken(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		errorCode   string
+		broken      bool
+		wantErr     bool
+		wantRevoked bool
+		wantCalls   int
+	}{
+		{name: "revoked", wantRevoked: true, wantCalls: 1},
+		{name: "already revoked", status: http.StatusBadRequest, errorCode: "invalid_token", wantRevoked: true, wantCalls: 1},
+		{name: "other client error", status: http.StatusBadRequest, errorCode: "invalid_request", wantErr: true, wantCalls: 1},
+		{name: "server error", status: http.StatusServiceUnavailable, wantErr: true, wantCalls: 1},
+		{name: "broken account", broken: true, wantRevoked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, tokenService, google, accounts := newGoogleCalendar(t, false)
+			google.revokeStatus = tt.status
+			google.revokeError = tt.errorCode
+			if tt.broken {
+				accounts.UpdateStatus(googleUserID, model.ProviderGoogle, storage.LinkedAccountBroken, "invalid_grant")
+			}
+
+			err := tokenService.RevokeToken(googleUserID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RevokeToken() error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(google.revoked) != tt.wantCalls {
+				t.Fatalf("revoke endpoint called %d times, want %d", len(google.revoked), tt.wantCalls)
+			}
+			// The refresh token revokes the access tokens issued for it as well.
+			if tt.wantCalls > 0 && google.revoked[0] != "refresh-token" {
+				t.Errorf("revoked token = %q, want the refresh token", google.revoked[0])
+			}
+
+			// Unlinking forgets the account whether or not the grant was revoked.
+			users := &fakeUsers{user: storage.User{ID: googleUserID, TelegramID: 800}}
+			userService := service.NewUserService(users, accounts, map[model.Provider]service.TokenService{
+				model.ProviderGoogle: tokenService,
+			}, nil)
+
+			revoked, err := userService.UnlinkAccount(googleUserID, model.ProviderGoogle)
+			if err != nil {
+				t.Fatalf("UnlinkAccount() error = %v", err)
+			}
+			if revoked != tt.wantRevoked {
+				t.Errorf("UnlinkAccount() revoked = %v, want %v", revoked, tt.wantRevoked)
+			}
+			if _, err := accounts.GetByUserIDAndProvider(googleUserID, model.ProviderGoogle); err == nil {
+				t.Error("account is still linked after UnlinkAccount()")
+			}
+
+			var notFound model.NotFoundError
+			if _, err := userService.UnlinkAccount(googleUserID, model.ProviderGoogle); !errors.As(err, &notFound) {
+				t.Errorf("second UnlinkAccount() error = %v, want NotFoundError", err)
+			}
+		})
+	}
+}
+
+func TestGoogleCalendarService_TagsEventsWithKey(t *testing.T) {
+	calendar, _, _, _ := newGoogleCalendar(t, false)
+	event := model.Event{
+		Title:    "Jazz Concert!",
+		Start:    time.Date(2025, 3, 4, 19, 0, 0, 0, time.UTC),
+		End:      time.Date(2025, 3, 4, 21, 0, 0, 0, time.UTC),
+		TimeZone: "Europe/Berlin",
+	}
+	key := service.EventKey(&event)
+
+	created, err := calendar.CreateEvent(googleUserID, "", &event)
+	if err != nil {
+		t.Fatalf("CreateEvent() error = %v", err)
+	}
+	if created.Key != key {
+		t.Errorf("created event key = %q, want %q", created.Key, key)
+	}
+
+	events, err := calendar.ListEvents(googleUserID, "", event.Start.Add(-time.Hour), event.End)
+	if err != nil {
+		t.Fatalf("ListEvents() error = %v", err)
+	}
+	if len(events) != 1 || events[0].Key != key {
+		t.Errorf("ListEvents() = %+v, want the event tagged with %q", events, key)
+	}
+
+	same := event
+	same.Title = "jazz concert"
+	if service.EventKey(&same) != key {
+		t.Errorf("EventKey() differs for titles that only differ in case and punctuation")
+	}
+	later := event
+	later.Start = event.Start.Add(time.Hour)
+	if service.EventKey(&later) == key {
+		t.Errorf("EventKey() is the same for events starting at different times")
+	}
+}
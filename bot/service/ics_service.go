@@ -0,0 +1,118 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/ivgag/schedulr/ical"
+	"github.com/ivgag/schedulr/model"
+)
+
+const (
+	// icsAlarmBefore is how long before a timed event its file reminds of it.
+	icsAlarmBefore = 15 * time.Minute
+	// icsAllDayAlarmAt is the time of the day an all-day event reminds of itself.
+	icsAllDayAlarmAt = 9 * time.Hour
+)
+
+// NewICSCalendarService creates a CalendarService for users without a linked calendar.
+func NewICSCalendarService() *ICSCalendarService {
+	return &ICSCalendarService{}
+}
+
+// ICSCalendarService hands the events out as iCalendar files, see ICSDocument, which
+// the user adds to the calendar of their device. It keeps no calendar: nothing can be
+// listed or deleted, and an update is a new file with the same UID, which replaces
+// the event when it is opened.
+type ICSCalendarService struct{}
+
+// ListCalendars implements CalendarService. The files go to no calendar in particular.
+func (c *ICSCalendarService) ListCalendars(userID int) ([]model.Calendar, error) {
+	return nil, nil
+}
+
+// CreateEvent implements CalendarService. The event ID is the UID of its file.
+func (c *ICSCalendarService) CreateEvent(userID int, calendarID string, event *model.Event) (model.ScheduledEvent, error) {
+	uid, err := uuid.NewV4()
+	if err != nil {
+		return model.ScheduledEvent{}, err
+	}
+
+	return model.ScheduledEvent{
+		ID:       uid.String() + "@schedulr",
+		Provider: model.ProviderICS,
+		Event:    *event,
+	}, nil
+}
+
+// UpdateEvent implements CalendarService.
+func (c *ICSCalendarService) UpdateEvent(
+	userID int,
+	calendarID string,
+	eventID string,
+	event *model.Event,
+) (model.ScheduledEvent, error) {
+	return model.ScheduledEvent{
+		ID:       eventID,
+		Provider: model.ProviderICS,
+		Event:    *event,
+	}, nil
+}
+
+// DeleteEvent implements CalendarService. The files that were sent stay with the user.
+func (c *ICSCalendarService) DeleteEvent(userID int, calendarID string, eventID string) error {
+	return nil
+}
+
+// ListEvents implements CalendarService. The events on the user's device cannot be read.
+func (c *ICSCalendarService) ListEvents(
+	userID int,
+	calendarID string,
+	from time.Time,
+	to time.Time,
+) ([]model.ScheduledEvent, error) {
+	return nil, nil
+}
+
+// ICSDocument encodes the created event as an iCalendar file that reminds of the event,
+// see icsAlarms.
+func ICSDocument(event model.ScheduledEvent) ([]byte, error) {
+	return ical.Encode(ical.Event{
+		UID:    event.ID,
+		Stamp:  time.Now(),
+		Alarms: icsAlarms(event.Event),
+		Event:  event.Event,
+	})
+}
+
+// icsAlarms remind of timed events shortly before they start, of reminders when they
+// are due and of all-day events in the morning of their first day.
+func icsAlarms(event model.Event) []time.Duration {
+	var alarm time.Duration
+	switch {
+	case event.AllDay:
+		alarm = -icsAllDayAlarmAt
+	case event.EventType != "reminder":
+		alarm = icsAlarmBefore
+	}
+	return []time.Duration{alarm}
+}
@@ -0,0 +1,157 @@
+package service_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ivgag/schedulr/ical"
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/service"
+	"github.com/ivgag/schedulr/storage"
+)
+
+func TestICSDocument(t *testing.T) {
+	tests := []struct {
+		name       string
+		event      model.Event
+		wantAlarms []time.Duration
+	}{
+		{
+			name: "timed event",
+			event: model.Event{
+				Title:    "Dentist",
+				Start:    time.Date(2025, 3, 4, 9, 30, 0, 0, time.UTC),
+				End:      time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC),
+				TimeZone: "Europe/Berlin",
+			},
+			wantAlarms: []time.Duration{15 * time.Minute},
+		},
+		{
+			name: "reminder",
+			event: model.Event{
+				Title:     "Pay the rent",
+				Start:     time.Date(2025, 3, 4, 9, 0, 0, 0, time.UTC),
+				End:       time.Date(2025, 3, 4, 9, 0, 0, 0, time.UTC),
+				EventType: "reminder",
+			},
+			wantAlarms: []time.Duration{0},
+		},
+		{
+			name: "all-day event",
+			event: model.Event{
+				Title:  "Grandma's birthday",
+				Start:  time.Date(2025, 5, 12, 0, 0, 0, 0, time.UTC),
+				End:    time.Date(2025, 5, 12, 0, 0, 0, 0, time.UTC),
+				AllDay: true,
+			},
+			wantAlarms: []time.Duration{-9 * time.Hour},
+		},
+	}
+
+	calendar := service.NewICSCalendarService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created, err := calendar.CreateEvent(1, "", &tt.event)
+			if err != nil {
+				t.Fatalf("CreateEvent() error = %v", err)
+			}
+
+			data, err := service.ICSDocument(created)
+			if err != nil {
+				t.Fatalf("ICSDocument() error = %v", err)
+			}
+			events, err := ical.Decode(data)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			} else if len(events) != 1 {
+				t.Fatalf("document has %d events, want 1", len(events))
+			}
+
+			if events[0].UID != created.ID {
+				t.Errorf("UID = %q, want %q", events[0].UID, created.ID)
+			}
+			if !reflect.DeepEqual(events[0].Event, tt.event) {
+				t.Errorf("event = %+v, want %+v", events[0].Event, tt.event)
+			}
+			if !reflect.DeepEqual(events[0].Alarms, tt.wantAlarms) {
+				t.Errorf("alarms = %v, want %v", events[0].Alarms, tt.wantAlarms)
+			}
+
+			// Updating keeps the UID, so that the new file replaces the event.
+			updated, err := calendar.UpdateEvent(1, "", created.ID, &tt.event)
+			if err != nil {
+				t.Fatalf("UpdateEvent() error = %v", err)
+			} else if updated.ID != created.ID {
+				t.Errorf("updated ID = %q, want %q", updated.ID, created.ID)
+			}
+		})
+	}
+}
+
+func TestUserService_CalendarProvidersWithICS(t *testing.T) {
+	tests := []struct {
+		name            string
+		linked          []model.Provider
+		defaultProvider model.Provider
+		allProviders    bool
+		want            []model.Provider
+	}{
+		{
+			name: "no linked account",
+			want: []model.Provider{model.ProviderICS},
+		},
+		{
+			name:         "no linked account, all providers",
+			allProviders: true,
+			want:         []model.Provider{model.ProviderICS},
+		},
+		{
+			name:   "linked account",
+			linked: []model.Provider{model.ProviderGoogle},
+			want:   []model.Provider{model.ProviderGoogle},
+		},
+		{
+			name:            "files only",
+			linked:          []model.Provider{model.ProviderGoogle},
+			defaultProvider: model.ProviderICS,
+			want:            []model.Provider{model.ProviderICS},
+		},
+		{
+			name:            "files and every linked account",
+			linked:          []model.Provider{model.ProviderGoogle},
+			defaultProvider: model.ProviderICS,
+			allProviders:    true,
+			want:            []model.Provider{model.ProviderICS, model.ProviderGoogle},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &fakeUsers{user: storage.User{
+				ID:              eventUserID,
+				TelegramID:      eventTelegramID,
+				DefaultProvider: tt.defaultProvider,
+				AllProviders:    tt.allProviders,
+			}}
+			accounts := &fakeLinkedAccounts{accounts: make(map[model.Provider]storage.LinkedAccount)}
+			for _, provider := range tt.linked {
+				accounts.accounts[provider] = storage.LinkedAccount{
+					UserID:   eventUserID,
+					Provider: provider,
+					Status:   storage.LinkedAccountActive,
+				}
+			}
+			tokenServices := map[model.Provider]service.TokenService{model.ProviderGoogle: nil}
+			userService := service.NewUserService(users, accounts, tokenServices, nil)
+
+			providers, err := userService.CalendarProviders(eventUserID)
+			if err != nil {
+				t.Fatalf("CalendarProviders() error = %v", err)
+			}
+			if !reflect.DeepEqual(providers, tt.want) {
+				t.Errorf("CalendarProviders() = %v, want %v", providers, tt.want)
+			}
+		})
+	}
+}
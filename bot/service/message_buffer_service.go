@@ -0,0 +1,83 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/storage"
+)
+
+const (
+	defaultBufferDebounce = 3 * time.Second
+	// defaultBufferLease is how long an instance has to process a batch before
+	// another one takes it over. It covers the retries of the AI providers.
+	defaultBufferLease = 5 * time.Minute
+)
+
+// BufferConfig configures how messages sent in a burst are collected.
+type BufferConfig struct {
+	// Debounce is how long a chat has to be quiet before its messages are processed.
+	Debounce time.Duration `mapstructure:"debounce"`
+	// Lease is how long a batch may take to process before it is retried.
+	Lease time.Duration `mapstructure:"lease"`
+}
+
+func NewMessageBufferService(config BufferConfig, bufferRepository storage.MessageBufferRepository) *MessageBufferService {
+	debounce := config.Debounce
+	if debounce <= 0 {
+		debounce = defaultBufferDebounce
+	}
+	lease := config.Lease
+	if lease <= 0 {
+		lease = defaultBufferLease
+	}
+
+	return &MessageBufferService{
+		debounce:         debounce,
+		lease:            lease,
+		bufferRepository: bufferRepository,
+	}
+}
+
+// MessageBufferService collects the messages of each chat until the chat has been
+// quiet for the debounce window, so that a burst of forwarded messages is read as one.
+type MessageBufferService struct {
+	debounce         time.Duration
+	lease            time.Duration
+	bufferRepository storage.MessageBufferRepository
+}
+
+// Add buffers the message and restarts the debounce window of the chat.
+func (s *MessageBufferService) Add(chatID int64, message model.TextMessage) error {
+	return s.bufferRepository.Append(chatID, message, s.debounce)
+}
+
+// ClaimDue returns up to limit batches of chats that have been quiet for the debounce window.
+// Each batch has to be completed once processed, or it is handed out again after the lease.
+func (s *MessageBufferService) ClaimDue(limit int) ([]storage.BufferedBatch, error) {
+	return s.bufferRepository.ClaimDue(limit, s.lease)
+}
+
+// Complete removes the processed messages from the buffer.
+func (s *MessageBufferService) Complete(batch storage.BufferedBatch) error {
+	return s.bufferRepository.Complete(batch)
+}
@@ -0,0 +1,90 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/service"
+	"github.com/ivgag/schedulr/storage"
+)
+
+func TestMessageBufferService(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       service.BufferConfig
+		wantDebounce time.Duration
+		wantLease    time.Duration
+	}{
+		{
+			name:         "defaults",
+			wantDebounce: 3 * time.Second,
+			wantLease:    5 * time.Minute,
+		},
+		{
+			name:         "configured",
+			config:       service.BufferConfig{Debounce: 10 * time.Second, Lease: time.Minute},
+			wantDebounce: 10 * time.Second,
+			wantLease:    time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := &fakeMessageBuffer{}
+			buffers := service.NewMessageBufferService(tt.config, buffer)
+
+			message := model.TextMessage{MessageID: 7, Text: "Concert on Friday at 19:00"}
+			if err := buffers.Add(eventTelegramID, message); err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+			if buffer.debounce != tt.wantDebounce {
+				t.Errorf("debounce = %v, want %v", buffer.debounce, tt.wantDebounce)
+			}
+
+			batches, err := buffers.ClaimDue(10)
+			if err != nil {
+				t.Fatalf("ClaimDue() error = %v", err)
+			}
+			if buffer.lease != tt.wantLease {
+				t.Errorf("lease = %v, want %v", buffer.lease, tt.wantLease)
+			}
+			if len(batches) != 1 || len(batches[0].Messages) != 1 || batches[0].Messages[0] != message {
+				t.Fatalf("ClaimDue() = %+v, want the buffered message", batches)
+			}
+
+			if err := buffers.Complete(batches[0]); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if len(buffer.messages) != 0 {
+				t.Errorf("buffered messages after Complete() = %v, want none", buffer.messages)
+			}
+		})
+	}
+}
+
+// fakeMessageBuffer buffers the messages of a single chat and makes them due at once.
+type fakeMessageBuffer struct {
+	messages []model.TextMessage
+	debounce time.Duration
+	lease    time.Duration
+}
+
+func (r *fakeMessageBuffer) Append(chatID int64, message model.TextMessage, debounce time.Duration) error {
+	r.messages = append(r.messages, message)
+	r.debounce = debounce
+	return nil
+}
+
+func (r *fakeMessageBuffer) ClaimDue(limit int, lease time.Duration) ([]storage.BufferedBatch, error) {
+	r.lease = lease
+	if len(r.messages) == 0 {
+		return nil, nil
+	}
+	return []storage.BufferedBatch{{ChatID: eventTelegramID, Messages: r.messages, LastID: len(r.messages)}}, nil
+}
+
+func (r *fakeMessageBuffer) Complete(batch storage.BufferedBatch) error {
+	r.messages = r.messages[batch.LastID:]
+	return nil
+}
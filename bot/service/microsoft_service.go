@@ -0,0 +1,739 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff/v5"
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/storage"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/microsoft"
+)
+
+const (
+	defaultMicrosoftTenant = "common"
+	defaultGraphURL        = "https://graph.microsoft.com/v1.0"
+	// graphDateTimeFormat is the wall-clock time format of Graph's dateTimeTimeZone.
+	graphDateTimeFormat = "2006-01-02T15:04:05"
+)
+
+// ErrRevocationUnsupported is returned by providers that cannot revoke a single grant.
+var ErrRevocationUnsupported = model.ErrorForMessage("the provider does not support revoking the access")
+
+// NewMicrosoftTokenService creates a TokenService for the Azure AD v2 authorization code flow.
+func NewMicrosoftTokenService(
+	config *MicrosoftConfig,
+	stateService *OAuthStateService,
+	linkedAccountsRepository storage.LinkedAccountRepository,
+) *MicrosoftTokenService {
+	oauth2Config := &oauth2.Config{
+		ClientID:     config.ClientID,
+		ClientSecret: config.ClientSecret,
+		RedirectURL:  config.RedirectURL,
+		Endpoint:     microsoftEndpoint(config),
+		// offline_access makes Azure AD issue a refresh token.
+		Scopes: []string{"offline_access", "Calendars.ReadWrite", "MailboxSettings.Read"},
+	}
+
+	return &MicrosoftTokenService{
+		oauth2TokenService: &oauth2TokenService{
+			provider:                 model.ProviderMicrosoft,
+			oauth2Config:             oauth2Config,
+			authCodeOptions:          []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("prompt", "select_account")},
+			stateService:             stateService,
+			linkedAccountsRepository: linkedAccountsRepository,
+		},
+	}
+}
+
+// MicrosoftTokenService encapsulates OAuth2 token logic for Microsoft accounts.
+type MicrosoftTokenService struct {
+	*oauth2TokenService
+}
+
+// RevokeToken implements TokenService. Azure AD can only revoke every session of the user,
+// so the user has to remove the app's access on the account page instead.
+func (s *MicrosoftTokenService) RevokeToken(userID int) error {
+	return ErrRevocationUnsupported
+}
+
+// GraphError is an error response of Microsoft Graph.
+type GraphError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *GraphError) Error() string {
+	return fmt.Sprintf("graph request failed with status %d: %s %s", e.StatusCode, e.Code, e.Message)
+}
+
+// NewMicrosoftCalendarService creates a CalendarService backed by Microsoft Graph.
+func NewMicrosoftCalendarService(config *MicrosoftConfig, tokenService *MicrosoftTokenService) *MicrosoftCalendarService {
+	graphURL := strings.TrimSuffix(config.GraphURL, "/")
+	if graphURL == "" {
+		graphURL = defaultGraphURL
+	}
+
+	return &MicrosoftCalendarService{
+		graphURL:     graphURL,
+		tokenService: tokenService,
+	}
+}
+
+// MicrosoftCalendarService handles calendar-related operations through Graph's /me/events.
+// An empty calendar ID stands for the user's default calendar.
+type MicrosoftCalendarService struct {
+	graphURL     string
+	tokenService *MicrosoftTokenService
+}
+
+// ListCalendars implements CalendarService. Calendars the user cannot edit are left out.
+func (c *MicrosoftCalendarService) ListCalendars(userID int) ([]model.Calendar, error) {
+	client, err := c.tokenService.ClientForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var list struct {
+		Value []graphCalendar `json:"value"`
+	}
+	path := "/me/calendars?" + url.Values{
+		"$select": {"id,name,canEdit,isDefaultCalendar"},
+		"$top":    {"100"},
+	}.Encode()
+	if err := c.do(client, http.MethodGet, path, nil, &list); err != nil {
+		log.Error().
+			Int("userID", userID).
+			Err(err).
+			Msg("Failed to list calendars")
+
+		return nil, c.tokenService.checkGrantError(userID, err)
+	}
+
+	var calendars []model.Calendar
+	for _, calendar := range list.Value {
+		if calendar.CanEdit {
+			calendars = append(calendars, model.Calendar{
+				ID:      calendar.ID,
+				Name:    calendar.Name,
+				Primary: calendar.IsDefaultCalendar,
+			})
+		}
+	}
+	return calendars, nil
+}
+
+// CreateEvent implements CalendarService.
+func (c *MicrosoftCalendarService) CreateEvent(userID int, calendarID string, event *model.Event) (model.ScheduledEvent, error) {
+	client, graphEvent, err := c.prepareEvent(userID, event)
+	if err != nil {
+		return model.ScheduledEvent{}, err
+	}
+
+	// Event IDs are unique within the mailbox, so only the creation depends on the calendar.
+	path := "/me/events"
+	if calendarID != "" {
+		path = "/me/calendars/" + url.PathEscape(calendarID) + "/events"
+	}
+
+	var created graphEventResponse
+	if err := c.do(client, http.MethodPost, path, graphEvent, &created); err != nil {
+		log.Error().
+			Interface("event", graphEvent).
+			Err(err).
+			Msg("Failed to create event")
+
+		return model.ScheduledEvent{}, c.tokenService.checkGrantError(userID, err)
+	}
+
+	if err := c.deleteExceptions(client, created.ID, event, graphEvent.Start.TimeZone); err != nil {
+		return model.ScheduledEvent{}, c.tokenService.checkGrantError(userID, err)
+	}
+
+	return c.toScheduledEvent(created, calendarID, event), nil
+}
+
+// UpdateEvent implements CalendarService.
+func (c *MicrosoftCalendarService) UpdateEvent(
+	userID int,
+	calendarID string,
+	eventID string,
+	event *model.Event,
+) (model.ScheduledEvent, error) {
+	client, graphEvent, err := c.prepareEvent(userID, event)
+	if err != nil {
+		return model.ScheduledEvent{}, err
+	}
+
+	var updated graphEventResponse
+	if err := c.do(client, http.MethodPatch, "/me/events/"+url.PathEscape(eventID), graphEvent, &updated); err != nil {
+		log.Error().
+			Str("eventID", eventID).
+			Interface("event", graphEvent).
+			Err(err).
+			Msg("Failed to update event")
+
+		return model.ScheduledEvent{}, c.tokenService.checkGrantError(userID, err)
+	}
+
+	if err := c.deleteExceptions(client, updated.ID, event, graphEvent.Start.TimeZone); err != nil {
+		return model.ScheduledEvent{}, c.tokenService.checkGrantError(userID, err)
+	}
+
+	return c.toScheduledEvent(updated, calendarID, event), nil
+}
+
+// DeleteEvent implements CalendarService. Events that are already gone are not an error.
+func (c *MicrosoftCalendarService) DeleteEvent(userID int, calendarID string, eventID string) error {
+	client, err := c.tokenService.ClientForUser(userID)
+	if err != nil {
+		return err
+	}
+
+	err = c.do(client, http.MethodDelete, "/me/events/"+url.PathEscape(eventID), nil, nil)
+	var graphErr *GraphError
+	if errors.As(err, &graphErr) && (graphErr.StatusCode == http.StatusNotFound || graphErr.StatusCode == http.StatusGone) {
+		return nil
+	} else if err != nil {
+		log.Error().
+			Str("eventID", eventID).
+			Err(err).
+			Msg("Failed to delete event")
+
+		return c.tokenService.checkGrantError(userID, err)
+	}
+
+	return nil
+}
+
+// ListEvents implements CalendarService. Graph's calendar view expands recurring events.
+func (c *MicrosoftCalendarService) ListEvents(
+	userID int,
+	calendarID string,
+	from time.Time,
+	to time.Time,
+) ([]model.ScheduledEvent, error) {
+	client, err := c.tokenService.ClientForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	path := "/me/calendarView?"
+	if calendarID != "" {
+		path = "/me/calendars/" + url.PathEscape(calendarID) + "/calendarView?"
+	}
+	path += url.Values{
+		"startDateTime": {from.UTC().Format(graphDateTimeFormat)},
+		"endDateTime":   {to.UTC().Format(graphDateTimeFormat)},
+		"$select":       {"id,subject,start,end,location,isAllDay,isCancelled,webLink"},
+		"$top":          {"100"},
+	}.Encode()
+
+	var list struct {
+		Value []graphEventResponse `json:"value"`
+	}
+	if err := c.doWithTimeZone(client, http.MethodGet, path, time.UTC.String(), nil, &list); err != nil {
+		log.Error().
+			Int("userID", userID).
+			Err(err).
+			Msg("Failed to list events")
+
+		return nil, c.tokenService.checkGrantError(userID, err)
+	}
+
+	var events []model.ScheduledEvent
+	for _, item := range list.Value {
+		if event, ok := fromGraphEvent(item); ok {
+			events = append(events, c.toScheduledEvent(item, calendarID, &event))
+		}
+	}
+	return events, nil
+}
+
+// prepareEvent creates a client authorized as the user and converts the event
+// into the user's mailbox time zone unless it has its own.
+func (c *MicrosoftCalendarService) prepareEvent(userID int, event *model.Event) (*http.Client, *graphEvent, error) {
+	client, err := c.tokenService.ClientForUser(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	timezone := event.TimeZone
+	if timezone == "" {
+		var settings struct {
+			TimeZone string `json:"timeZone"`
+		}
+		if err := c.do(client, http.MethodGet, "/me/mailboxSettings", nil, &settings); err != nil {
+			return nil, nil, c.tokenService.checkGrantError(userID, err)
+		}
+		timezone = settings.TimeZone
+	}
+
+	graphEvent, err := toGraphEvent(event, timezone)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, graphEvent, nil
+}
+
+// deleteExceptions removes the skipped occurrences of a recurring event.
+// Graph has no exception dates, so the occurrences are deleted once the series exists.
+func (c *MicrosoftCalendarService) deleteExceptions(
+	client *http.Client,
+	eventID string,
+	event *model.Event,
+	timezone string,
+) error {
+	if event.Recurrence == "" || len(event.ExceptionDates) == 0 {
+		return nil
+	}
+
+	for _, date := range event.ExceptionDates {
+		// The window is given in UTC, so it is widened by a day to cover every time zone.
+		from := date.AddDate(0, 0, -1)
+		to := date.AddDate(0, 0, 2)
+		path := "/me/events/" + url.PathEscape(eventID) + "/instances?" + url.Values{
+			"startDateTime": {from.Format(graphDateTimeFormat)},
+			"endDateTime":   {to.Format(graphDateTimeFormat)},
+		}.Encode()
+
+		var instances struct {
+			Value []graphEventResponse `json:"value"`
+		}
+		if err := c.doWithTimeZone(client, http.MethodGet, path, timezone, nil, &instances); err != nil {
+			return err
+		}
+
+		expected := date.Format(graphDateTimeFormat)
+		if event.AllDay {
+			expected = toDate(date).Format(graphDateTimeFormat)
+		}
+		for _, instance := range instances.Value {
+			if !strings.HasPrefix(instance.Start.DateTime, expected) {
+				continue
+			}
+			err := c.do(client, http.MethodDelete, "/me/events/"+url.PathEscape(instance.ID), nil, nil)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *MicrosoftCalendarService) do(client *http.Client, method string, path string, body any, out any) error {
+	return c.doWithTimeZone(client, method, path, "", body, out)
+}
+
+// doWithTimeZone sends a Graph request, retrying throttled and failed ones.
+// If timezone is set, Graph returns the times in it instead of UTC.
+func (c *MicrosoftCalendarService) doWithTimeZone(
+	client *http.Client,
+	method string,
+	path string,
+	timezone string,
+	body any,
+	out any,
+) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	operation := func() (struct{}, error) {
+		req, err := http.NewRequest(method, c.graphURL+path, bytes.NewReader(payload))
+		if err != nil {
+			return struct{}{}, backoff.Permanent(err)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if timezone != "" {
+			req.Header.Set("Prefer", `outlook.timezone="`+timezone+`"`)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			if _, ok := grantErrorReason(err); ok {
+				return struct{}{}, backoff.Permanent(err)
+			}
+			return struct{}{}, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusBadRequest {
+			graphErr := readGraphError(resp)
+			if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+				return struct{}{}, graphErr
+			}
+			return struct{}{}, backoff.Permanent(graphErr)
+		}
+
+		if out != nil && resp.StatusCode != http.StatusNoContent {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return struct{}{}, backoff.Permanent(err)
+			}
+		}
+		return struct{}{}, nil
+	}
+
+	_, err := backoff.Retry(
+		context.Background(),
+		operation,
+		backoff.WithBackOff(backoff.NewExponentialBackOff()),
+		backoff.WithMaxTries(3),
+	)
+	return err
+}
+
+func readGraphError(resp *http.Response) *GraphError {
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	data, _ := io.ReadAll(resp.Body)
+	_ = json.Unmarshal(data, &body)
+
+	return &GraphError{
+		StatusCode: resp.StatusCode,
+		Code:       body.Error.Code,
+		Message:    body.Error.Message,
+	}
+}
+
+func (c *MicrosoftCalendarService) toScheduledEvent(
+	created graphEventResponse,
+	calendarID string,
+	event *model.Event,
+) model.ScheduledEvent {
+	return model.ScheduledEvent{
+		ID:         created.ID,
+		Provider:   model.ProviderMicrosoft,
+		CalendarID: calendarID,
+		Event:      *event,
+		Link:       created.WebLink,
+	}
+}
+
+type graphEvent struct {
+	Subject  string                `json:"subject"`
+	Body     graphItemBody         `json:"body"`
+	Start    graphDateTimeTimeZone `json:"start"`
+	End      graphDateTimeTimeZone `json:"end"`
+	Location graphLocation         `json:"location"`
+	IsAllDay bool                  `json:"isAllDay"`
+	// Recurrence is sent as null for single events, so that patching a recurring event makes it single.
+	Recurrence *graphRecurrence `json:"recurrence"`
+}
+
+type graphCalendar struct {
+	ID                string `json:"id"`
+	Name              string `json:"name"`
+	CanEdit           bool   `json:"canEdit"`
+	IsDefaultCalendar bool   `json:"isDefaultCalendar"`
+}
+
+type graphEventResponse struct {
+	ID          string                `json:"id"`
+	WebLink     string                `json:"webLink"`
+	Subject     string                `json:"subject"`
+	Start       graphDateTimeTimeZone `json:"start"`
+	End         graphDateTimeTimeZone `json:"end"`
+	Location    graphLocation         `json:"location"`
+	IsAllDay    bool                  `json:"isAllDay"`
+	IsCancelled bool                  `json:"isCancelled"`
+}
+
+type graphItemBody struct {
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+type graphDateTimeTimeZone struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+type graphLocation struct {
+	DisplayName string `json:"displayName"`
+}
+
+type graphRecurrence struct {
+	Pattern graphRecurrencePattern `json:"pattern"`
+	Range   graphRecurrenceRange   `json:"range"`
+}
+
+type graphRecurrencePattern struct {
+	Type           string   `json:"type"`
+	Interval       int      `json:"interval"`
+	DaysOfWeek     []string `json:"daysOfWeek,omitempty"`
+	DayOfMonth     int      `json:"dayOfMonth,omitempty"`
+	Month          int      `json:"month,omitempty"`
+	Index          string   `json:"index,omitempty"`
+	FirstDayOfWeek string   `json:"firstDayOfWeek,omitempty"`
+}
+
+type graphRecurrenceRange struct {
+	Type                string `json:"type"`
+	StartDate           string `json:"startDate"`
+	EndDate             string `json:"endDate,omitempty"`
+	NumberOfOccurrences int    `json:"numberOfOccurrences,omitempty"`
+	RecurrenceTimeZone  string `json:"recurrenceTimeZone,omitempty"`
+}
+
+var graphDaysOfWeek = map[string]string{
+	"MO": "monday",
+	"TU": "tuesday",
+	"WE": "wednesday",
+	"TH": "thursday",
+	"FR": "friday",
+	"SA": "saturday",
+	"SU": "sunday",
+}
+
+var graphWeekIndexes = map[int]string{
+	1:  "first",
+	2:  "second",
+	3:  "third",
+	4:  "fourth",
+	-1: "last",
+}
+
+// fromGraphEvent converts a listed event whose times are given in UTC.
+// Cancelled occurrences and events with unreadable times are skipped.
+func fromGraphEvent(item graphEventResponse) (model.Event, bool) {
+	if item.IsCancelled {
+		return model.Event{}, false
+	}
+
+	// Graph sends up to seven fractional digits.
+	const layout = graphDateTimeFormat + ".9999999"
+	start, startErr := time.Parse(layout, item.Start.DateTime)
+	end, endErr := time.Parse(layout, item.End.DateTime)
+	if startErr != nil || endErr != nil {
+		return model.Event{}, false
+	}
+
+	event := model.Event{
+		Title:    item.Subject,
+		Location: item.Location.DisplayName,
+		Start:    start,
+		End:      end,
+		TimeZone: time.UTC.String(),
+	}
+	if item.IsAllDay {
+		// All-day events span whole dates, and the end is exclusive.
+		event.Start, event.End, event.AllDay, event.TimeZone = toDate(start), toDate(end).AddDate(0, 0, -1), true, ""
+	}
+	return event, true
+}
+
+func toGraphEvent(event *model.Event, timezone string) (*graphEvent, error) {
+	start := event.Start
+	end := event.End
+	if event.AllDay {
+		// Graph expects all-day events from midnight to the midnight after the last day.
+		start = toDate(event.Start)
+		end = toDate(event.End)
+		if end.Before(start) {
+			end = start
+		}
+		end = end.AddDate(0, 0, 1)
+	}
+
+	recurrence, err := toGraphRecurrence(event, timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	return &graphEvent{
+		Subject:    event.Title,
+		Body:       graphItemBody{ContentType: "text", Content: event.Description},
+		Start:      graphDateTimeTimeZone{DateTime: start.Format(graphDateTimeFormat), TimeZone: timezone},
+		End:        graphDateTimeTimeZone{DateTime: end.Format(graphDateTimeFormat), TimeZone: timezone},
+		Location:   graphLocation{DisplayName: event.Location},
+		IsAllDay:   event.AllDay,
+		Recurrence: recurrence,
+	}, nil
+}
+
+// toGraphRecurrence converts the event's recurrence rule into Graph's recurrence pattern and range.
+// Rules Outlook cannot represent, such as several months or days of the month, are rejected.
+func toGraphRecurrence(event *model.Event, timezone string) (*graphRecurrence, error) {
+	rule, ok, err := event.RRule()
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	days, index, err := graphDays(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern := graphRecurrencePattern{Interval: rule.Interval}
+	if rule.WeekStart != "" {
+		pattern.FirstDayOfWeek = graphDaysOfWeek[rule.WeekStart]
+	}
+
+	switch rule.Freq {
+	case "DAILY":
+		pattern.Type = "daily"
+	case "WEEKLY":
+		pattern.Type = "weekly"
+		pattern.DaysOfWeek = days
+		if len(days) == 0 {
+			pattern.DaysOfWeek = []string{strings.ToLower(event.Start.Weekday().String())}
+		}
+	case "MONTHLY", "YEARLY":
+		if len(rule.ByMonthDay) > 1 || len(rule.ByMonth) > 1 {
+			return nil, fmt.Errorf("recurrence rule %s cannot be represented in Outlook", rule.String())
+		}
+
+		prefix := "Monthly"
+		if rule.Freq == "YEARLY" {
+			prefix = "Yearly"
+			pattern.Month = int(event.Start.Month())
+			if len(rule.ByMonth) == 1 {
+				pattern.Month = rule.ByMonth[0]
+			}
+		}
+
+		if len(days) > 0 {
+			pattern.Type = "relative" + prefix
+			pattern.DaysOfWeek = days
+			pattern.Index = index
+		} else {
+			pattern.Type = "absolute" + prefix
+			pattern.DayOfMonth = event.Start.Day()
+			if len(rule.ByMonthDay) == 1 {
+				if rule.ByMonthDay[0] < 0 {
+					return nil, fmt.Errorf("recurrence rule %s cannot be represented in Outlook", rule.String())
+				}
+				pattern.DayOfMonth = rule.ByMonthDay[0]
+			}
+		}
+	}
+
+	recurrenceRange := graphRecurrenceRange{
+		Type:               "noEnd",
+		StartDate:          event.Start.Format(time.DateOnly),
+		RecurrenceTimeZone: timezone,
+	}
+	if rule.Count > 0 {
+		recurrenceRange.Type = "numbered"
+		recurrenceRange.NumberOfOccurrences = rule.Count
+	} else if !rule.Until.IsZero() {
+		until := rule.Until
+		if !rule.UntilDate {
+			if loc, err := time.LoadLocation(timezone); err == nil {
+				until = until.In(loc)
+			}
+		}
+		recurrenceRange.Type = "endDate"
+		recurrenceRange.EndDate = until.Format(time.DateOnly)
+	}
+
+	return &graphRecurrence{Pattern: pattern, Range: recurrenceRange}, nil
+}
+
+// graphDays converts BYDAY into Graph's days of the week and the week index
+// taken from an ordinal ("2TU") or BYSETPOS.
+func graphDays(rule model.RRule) ([]string, string, error) {
+	var days []string
+	ordinal := 0
+	for _, day := range rule.ByDay {
+		prefix, name := day[:len(day)-2], day[len(day)-2:]
+		if prefix != "" {
+			n, err := strconv.Atoi(prefix)
+			if err != nil {
+				return nil, "", err
+			} else if ordinal != 0 && ordinal != n {
+				return nil, "", fmt.Errorf("recurrence rule %s cannot be represented in Outlook", rule.String())
+			}
+			ordinal = n
+		}
+		days = append(days, graphDaysOfWeek[name])
+	}
+
+	if len(rule.BySetPos) == 1 && ordinal == 0 {
+		ordinal = rule.BySetPos[0]
+	}
+
+	index := ""
+	if ordinal != 0 {
+		var ok bool
+		if index, ok = graphWeekIndexes[ordinal]; !ok {
+			return nil, "", fmt.Errorf("recurrence rule %s cannot be represented in Outlook", rule.String())
+		}
+	} else if len(days) > 0 && (rule.Freq == "MONTHLY" || rule.Freq == "YEARLY") {
+		return nil, "", fmt.Errorf("recurrence rule %s cannot be represented in Outlook", rule.String())
+	}
+
+	return days, index, nil
+}
+
+type MicrosoftConfig struct {
+	ClientID     string `mapstructure:"client_id"`
+	ClientSecret string `mapstructure:"client_secret"`
+	RedirectURL  string `mapstructure:"redirect_url"`
+	// Tenant is "common", "organizations", "consumers" or a tenant ID. It defaults to "common".
+	Tenant string `mapstructure:"tenant"`
+	// AuthURL, TokenURL and GraphURL override Microsoft's endpoints, e.g. to point to a stand-in server.
+	AuthURL  string `mapstructure:"auth_url"`
+	TokenURL string `mapstructure:"token_url"`
+	GraphURL string `mapstructure:"graph_url"`
+}
+
+func microsoftEndpoint(config *MicrosoftConfig) oauth2.Endpoint {
+	tenant := config.Tenant
+	if tenant == "" {
+		tenant = defaultMicrosoftTenant
+	}
+
+	endpoint := microsoft.AzureADEndpoint(tenant)
+	if config.AuthURL != "" {
+		endpoint.AuthURL = config.AuthURL
+	}
+	if config.TokenURL != "" {
+		endpoint.TokenURL = config.TokenURL
+	}
+	return endpoint
+}
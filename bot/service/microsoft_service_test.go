@@ -0,0 +1,497 @@
+package service_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/service"
+	"github.com/ivgag/schedulr/storage"
+)
+
+type fakeGraph struct {
+	mu         sync.Mutex
+	server     *httptest.Server
+	status     int
+	created    []map[string]any
+	createdIn  []string
+	patched    map[string]map[string]any
+	deleted    []string
+	instances  []map[string]any
+	authHeader string
+	prefer     string
+}
+
+func newFakeGraph(t *testing.T) *fakeGraph {
+	graph := &fakeGraph{patched: make(map[string]map[string]any)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /me/mailboxSettings", func(w http.ResponseWriter, r *http.Request) {
+		graph.writeJSON(w, r, map[string]any{"timeZone": "W. Europe Standard Time"})
+	})
+	mux.HandleFunc("POST /me/events", func(w http.ResponseWriter, r *http.Request) {
+		body := decodeBody(t, r)
+		graph.mu.Lock()
+		graph.created = append(graph.created, body)
+		graph.mu.Unlock()
+		graph.writeJSON(w, r, map[string]any{"id": "event-1", "webLink": "https://outlook.example/event-1"})
+	})
+	mux.HandleFunc("GET /me/calendars", func(w http.ResponseWriter, r *http.Request) {
+		graph.writeJSON(w, r, map[string]any{"value": []map[string]any{
+			{"id": "calendar-1", "name": "Calendar", "canEdit": true, "isDefaultCalendar": true},
+			{"id": "calendar-2", "name": "Birthdays", "canEdit": false},
+			{"id": "calendar-3", "name": "Family", "canEdit": true},
+		}})
+	})
+	mux.HandleFunc("POST /me/calendars/{calendarID}/events", func(w http.ResponseWriter, r *http.Request) {
+		body := decodeBody(t, r)
+		graph.mu.Lock()
+		graph.created = append(graph.created, body)
+		graph.createdIn = append(graph.createdIn, r.PathValue("calendarID"))
+		graph.mu.Unlock()
+		graph.writeJSON(w, r, map[string]any{"id": "event-2", "webLink": "https://outlook.example/event-2"})
+	})
+	mux.HandleFunc("GET /me/calendarView", func(w http.ResponseWriter, r *http.Request) {
+		graph.mu.Lock()
+		graph.prefer = r.Header.Get("Prefer")
+		graph.mu.Unlock()
+		graph.writeJSON(w, r, map[string]any{"value": []map[string]any{
+			{
+				"id":       "event-3",
+				"subject":  "Jazz concert",
+				"start":    map[string]any{"dateTime": "2025-03-04T18:00:00.0000000", "timeZone": "UTC"},
+				"end":      map[string]any{"dateTime": "2025-03-04T20:00:00.0000000", "timeZone": "UTC"},
+				"location": map[string]any{"displayName": "Club"},
+				"webLink":  "https://outlook.example/event-3",
+			},
+			{
+				"id":       "event-4",
+				"subject":  "Festival",
+				"start":    map[string]any{"dateTime": "2025-03-04T00:00:00.0000000", "timeZone": "UTC"},
+				"end":      map[string]any{"dateTime": "2025-03-06T00:00:00.0000000", "timeZone": "UTC"},
+				"isAllDay": true,
+			},
+			{
+				"id":          "event-5",
+				"subject":     "Cancelled standup",
+				"start":       map[string]any{"dateTime": "2025-03-04T09:00:00.0000000", "timeZone": "UTC"},
+				"end":         map[string]any{"dateTime": "2025-03-04T09:15:00.0000000", "timeZone": "UTC"},
+				"isCancelled": true,
+			},
+		}})
+	})
+	mux.HandleFunc("PATCH /me/events/{id}", func(w http.ResponseWriter, r *http.Request) {
+		body := decodeBody(t, r)
+		graph.mu.Lock()
+		graph.patched[r.PathValue("id")] = body
+		graph.mu.Unlock()
+		graph.writeJSON(w, r, map[string]any{"id": r.PathValue("id"), "webLink": "https://outlook.example/" + r.PathValue("id")})
+	})
+	mux.HandleFunc("GET /me/events/{id}/instances", func(w http.ResponseWriter, r *http.Request) {
+		graph.mu.Lock()
+		graph.prefer = r.Header.Get("Prefer")
+		graph.mu.Unlock()
+		graph.writeJSON(w, r, map[string]any{"value": graph.instances})
+	})
+	mux.HandleFunc("DELETE /me/events/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if graph.fail(w, r) {
+			return
+		}
+		graph.mu.Lock()
+		defer graph.mu.Unlock()
+		if r.PathValue("id") == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		graph.deleted = append(graph.deleted, r.PathValue("id"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	graph.server = httptest.NewServer(mux)
+	t.Cleanup(graph.server.Close)
+	return graph
+}
+
+// fail answers with the configured error status, if any.
+func (g *fakeGraph) fail(w http.ResponseWriter, r *http.Request) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.authHeader = r.Header.Get("Authorization")
+	if g.status == 0 {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(g.status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"code": "InvalidAuthenticationToken", "message": "Access token has expired."},
+	})
+	return true
+}
+
+func (g *fakeGraph) writeJSON(w http.ResponseWriter, r *http.Request, body any) {
+	if g.fail(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+func decodeBody(t *testing.T, r *http.Request) map[string]any {
+	var body map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		t.Errorf("failed to decode request body: %v", err)
+	}
+	return body
+}
+
+type fakeLinkedAccounts struct {
+	mu       sync.Mutex
+	accounts map[model.Provider]storage.LinkedAccount
+}
+
+func (r *fakeLinkedAccounts) Save(account storage.LinkedAccount) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account.Status = storage.LinkedAccountActive
+	r.accounts[account.Provider] = account
+	return nil
+}
+
+func (r *fakeLinkedAccounts) GetByUserIDAndProvider(userID int, provider model.Provider) (storage.LinkedAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account, ok := r.accounts[provider]
+	if !ok || account.UserID != userID {
+		return storage.LinkedAccount{}, model.NotFoundError{Message: "account not found"}
+	}
+	return account, nil
+}
+
+func (r *fakeLinkedAccounts) ListByUserID(userID int) ([]storage.LinkedAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var accounts []storage.LinkedAccount
+	for _, account := range r.accounts {
+		if account.UserID == userID {
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts, nil
+}
+
+func (r *fakeLinkedAccounts) UpdateStatus(
+	userID int,
+	provider model.Provider,
+	status storage.LinkedAccountStatus,
+	reason string,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account := r.accounts[provider]
+	account.Status = status
+	account.StatusReason = reason
+	r.accounts[provider] = account
+	return nil
+}
+
+func (r *fakeLinkedAccounts) Delete(userID int, provider model.Provider) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.accounts, provider)
+	return nil
+}
+
+func (r *fakeLinkedAccounts) ReencryptTokens() (int, error) {
+	return 0, nil
+}
+
+const microsoftUserID = 7
+
+func newMicrosoftCalendar(t *testing.T) (*service.MicrosoftCalendarService, *fakeGraph, *fakeLinkedAccounts) {
+	graph := newFakeGraph(t)
+	accounts := &fakeLinkedAccounts{accounts: map[model.Provider]storage.LinkedAccount{
+		model.ProviderMicrosoft: {
+			UserID:       microsoftUserID,
+			Provider:     model.ProviderMicrosoft,
+			AccessToken:  "access-token",
+			RefreshToken: "refresh-token",
+			Expiry:       time.Now().Add(time.Hour),
+			Status:       storage.LinkedAccountActive,
+		},
+	}}
+
+	config := &service.MicrosoftConfig{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "https://schedulr.example/oauth2callback/microsoft",
+		TokenURL:     graph.server.URL + "/token",
+		GraphURL:     graph.server.URL,
+	}
+	tokenService := service.NewMicrosoftTokenService(config, nil, accounts)
+	return service.NewMicrosoftCalendarService(config, tokenService), graph, accounts
+}
+
+func TestMicrosoftCalendarService_CreateEvent(t *testing.T) {
+	calendar, graph, _ := newMicrosoftCalendar(t)
+
+	event := &model.Event{
+		Title:       "Book club",
+		Description: "Bring the book",
+		Start:       time.Date(2025, 3, 4, 19, 0, 0, 0, time.UTC),
+		End:         time.Date(2025, 3, 4, 21, 0, 0, 0, time.UTC),
+		Location:    "Library",
+		Recurrence:  "FREQ=MONTHLY;BYDAY=1TU;COUNT=6",
+	}
+
+	scheduled, err := calendar.CreateEvent(microsoftUserID, "", event)
+	if err != nil {
+		t.Fatalf("CreateEvent() error = %v", err)
+	}
+
+	if scheduled.ID != "event-1" || scheduled.Link != "https://outlook.example/event-1" {
+		t.Errorf("CreateEvent() = %+v, want the created event", scheduled)
+	} else if scheduled.Provider != model.ProviderMicrosoft {
+		t.Errorf("CreateEvent() provider = %s, want %s", scheduled.Provider, model.ProviderMicrosoft)
+	}
+	if graph.authHeader != "Bearer access-token" {
+		t.Errorf("Authorization = %q, want the user's access token", graph.authHeader)
+	}
+
+	if len(graph.created) != 1 {
+		t.Fatalf("created %d events, want 1", len(graph.created))
+	}
+	created, _ := json.Marshal(graph.created[0])
+	for _, want := range []string{
+		`"subject":"Book club"`,
+		`"start":{"dateTime":"2025-03-04T19:00:00","timeZone":"W. Europe Standard Time"}`,
+		`"end":{"dateTime":"2025-03-04T21:00:00","timeZone":"W. Europe Standard Time"}`,
+		`"location":{"displayName":"Library"}`,
+		`"pattern":{"daysOfWeek":["tuesday"],"index":"first","interval":1,"type":"relativeMonthly"}`,
+		`"range":{"numberOfOccurrences":6,"recurrenceTimeZone":"W. Europe Standard Time","startDate":"2025-03-04","type":"numbered"}`,
+	} {
+		if !strings.Contains(string(created), want) {
+			t.Errorf("created event %s does not contain %s", created, want)
+		}
+	}
+}
+
+func TestMicrosoftCalendarService_ListCalendars(t *testing.T) {
+	calendar, _, _ := newMicrosoftCalendar(t)
+
+	calendars, err := calendar.ListCalendars(microsoftUserID)
+	if err != nil {
+		t.Fatalf("ListCalendars() error = %v", err)
+	}
+
+	want := []model.Calendar{
+		{ID: "calendar-1", Name: "Calendar", Primary: true},
+		{ID: "calendar-3", Name: "Family"},
+	}
+	if !reflect.DeepEqual(calendars, want) {
+		t.Errorf("ListCalendars() = %+v, want %+v", calendars, want)
+	}
+}
+
+func TestMicrosoftCalendarService_ListEvents(t *testing.T) {
+	calendar, graph, _ := newMicrosoftCalendar(t)
+
+	events, err := calendar.ListEvents(
+		microsoftUserID,
+		"",
+		time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 5, 0, 0, 0, 0, time.UTC),
+	)
+	if err != nil {
+		t.Fatalf("ListEvents() error = %v", err)
+	}
+
+	want := []model.ScheduledEvent{
+		{
+			ID:       "event-3",
+			Provider: model.ProviderMicrosoft,
+			Event: model.Event{
+				Title:    "Jazz concert",
+				Start:    time.Date(2025, 3, 4, 18, 0, 0, 0, time.UTC),
+				End:      time.Date(2025, 3, 4, 20, 0, 0, 0, time.UTC),
+				Location: "Club",
+				TimeZone: "UTC",
+			},
+			Link: "https://outlook.example/event-3",
+		},
+		{
+			ID:       "event-4",
+			Provider: model.ProviderMicrosoft,
+			Event: model.Event{
+				Title:  "Festival",
+				Start:  time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC),
+				End:    time.Date(2025, 3, 5, 0, 0, 0, 0, time.UTC),
+				AllDay: true,
+			},
+		},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("ListEvents() = %+v, want %+v", events, want)
+	}
+	if graph.prefer != `outlook.timezone="UTC"` {
+		t.Errorf("Prefer = %q, want the times in UTC", graph.prefer)
+	}
+}
+
+func TestMicrosoftCalendarService_CreateEventInCalendar(t *testing.T) {
+	calendar, graph, _ := newMicrosoftCalendar(t)
+
+	scheduled, err := calendar.CreateEvent(microsoftUserID, "calendar-3", &model.Event{
+		Title:  "Grandma's birthday",
+		Start:  time.Date(2025, 5, 12, 0, 0, 0, 0, time.UTC),
+		End:    time.Date(2025, 5, 12, 0, 0, 0, 0, time.UTC),
+		AllDay: true,
+	})
+	if err != nil {
+		t.Fatalf("CreateEvent() error = %v", err)
+	}
+
+	if scheduled.ID != "event-2" || scheduled.CalendarID != "calendar-3" {
+		t.Errorf("CreateEvent() = %+v, want event-2 in calendar-3", scheduled)
+	}
+	if !reflect.DeepEqual(graph.createdIn, []string{"calendar-3"}) {
+		t.Errorf("created in %v, want [calendar-3]", graph.createdIn)
+	}
+}
+
+func TestMicrosoftCalendarService_CreateAllDayEvent(t *testing.T) {
+	calendar, graph, _ := newMicrosoftCalendar(t)
+
+	_, err := calendar.CreateEvent(microsoftUserID, "", &model.Event{
+		Title:  "Festival",
+		Start:  time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC),
+		End:    time.Date(2025, 5, 3, 0, 0, 0, 0, time.UTC),
+		AllDay: true,
+	})
+	if err != nil {
+		t.Fatalf("CreateEvent() error = %v", err)
+	}
+
+	created, _ := json.Marshal(graph.created[0])
+	for _, want := range []string{
+		`"isAllDay":true`,
+		`"start":{"dateTime":"2025-05-01T00:00:00"`,
+		// Graph's end is the midnight after the last day.
+		`"end":{"dateTime":"2025-05-04T00:00:00"`,
+		`"recurrence":null`,
+	} {
+		if !strings.Contains(string(created), want) {
+			t.Errorf("created event %s does not contain %s", created, want)
+		}
+	}
+}
+
+func TestMicrosoftCalendarService_DeletesExceptionOccurrences(t *testing.T) {
+	calendar, graph, _ := newMicrosoftCalendar(t)
+	graph.instances = []map[string]any{
+		{"id": "occurrence-1", "start": map[string]any{"dateTime": "2025-03-11T19:00:00.0000000", "timeZone": "Europe/Berlin"}},
+		{"id": "occurrence-2", "start": map[string]any{"dateTime": "2025-03-18T19:00:00.0000000", "timeZone": "Europe/Berlin"}},
+	}
+
+	_, err := calendar.CreateEvent(microsoftUserID, "", &model.Event{
+		Title:          "Training",
+		Start:          time.Date(2025, 3, 4, 19, 0, 0, 0, time.UTC),
+		End:            time.Date(2025, 3, 4, 20, 0, 0, 0, time.UTC),
+		TimeZone:       "Europe/Berlin",
+		Recurrence:     "FREQ=WEEKLY;BYDAY=TU",
+		ExceptionDates: []time.Time{time.Date(2025, 3, 18, 19, 0, 0, 0, time.UTC)},
+	})
+	if err != nil {
+		t.Fatalf("CreateEvent() error = %v", err)
+	}
+
+	if len(graph.deleted) != 1 || graph.deleted[0] != "occurrence-2" {
+		t.Errorf("deleted %v, want only the skipped occurrence", graph.deleted)
+	}
+	if graph.prefer != `outlook.timezone="Europe/Berlin"` {
+		t.Errorf("Prefer = %q, want the event's time zone", graph.prefer)
+	}
+}
+
+func TestMicrosoftCalendarService_UpdateEvent(t *testing.T) {
+	calendar, graph, _ := newMicrosoftCalendar(t)
+
+	scheduled, err := calendar.UpdateEvent(microsoftUserID, "", "event-9", &model.Event{
+		Title:    "Moved",
+		Start:    time.Date(2025, 3, 4, 19, 30, 0, 0, time.UTC),
+		End:      time.Date(2025, 3, 4, 20, 30, 0, 0, time.UTC),
+		TimeZone: "Europe/Berlin",
+	})
+	if err != nil {
+		t.Fatalf("UpdateEvent() error = %v", err)
+	}
+
+	if scheduled.ID != "event-9" {
+		t.Errorf("UpdateEvent() ID = %s, want event-9", scheduled.ID)
+	}
+	if patch, ok := graph.patched["event-9"]; !ok || patch["subject"] != "Moved" {
+		t.Errorf("patched %v, want event-9 with the new subject", graph.patched)
+	}
+}
+
+func TestMicrosoftCalendarService_DeleteEvent(t *testing.T) {
+	calendar, graph, _ := newMicrosoftCalendar(t)
+
+	if err := calendar.DeleteEvent(microsoftUserID, "", "event-1"); err != nil {
+		t.Fatalf("DeleteEvent() error = %v", err)
+	}
+	if len(graph.deleted) != 1 || graph.deleted[0] != "event-1" {
+		t.Errorf("deleted %v, want event-1", graph.deleted)
+	}
+
+	if err := calendar.DeleteEvent(microsoftUserID, "", "missing"); err != nil {
+		t.Errorf("DeleteEvent() of a missing event error = %v, want nil", err)
+	}
+}
+
+func TestMicrosoftCalendarService_RejectedTokenRequiresRelink(t *testing.T) {
+	calendar, graph, accounts := newMicrosoftCalendar(t)
+	graph.status = http.StatusUnauthorized
+
+	err := calendar.DeleteEvent(microsoftUserID, "", "event-1")
+
+	var relinkErr model.RelinkRequiredError
+	if !errors.As(err, &relinkErr) || relinkErr.Provider != model.ProviderMicrosoft {
+		t.Fatalf("DeleteEvent() error = %v, want a RelinkRequiredError", err)
+	}
+
+	account, _ := accounts.GetByUserIDAndProvider(microsoftUserID, model.ProviderMicrosoft)
+	if account.Status != storage.LinkedAccountBroken {
+		t.Errorf("account status = %s, want %s", account.Status, storage.LinkedAccountBroken)
+	}
+
+	// Broken accounts are not used until they are linked again.
+	graph.status = 0
+	if _, err := calendar.CreateEvent(microsoftUserID, "", &model.Event{Title: "Later"}); !errors.As(err, &relinkErr) {
+		t.Errorf("CreateEvent() on a broken account error = %v, want a RelinkRequiredError", err)
+	}
+}
+
+func TestMicrosoftCalendarService_RejectsUnsupportedRecurrence(t *testing.T) {
+	calendar, graph, _ := newMicrosoftCalendar(t)
+
+	_, err := calendar.CreateEvent(microsoftUserID, "", &model.Event{
+		Title:      "Rent",
+		Start:      time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC),
+		End:        time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC),
+		Recurrence: "FREQ=MONTHLY;BYMONTHDAY=1,15",
+	})
+	if err == nil {
+		t.Fatal("CreateEvent() error = nil, want an error for a rule Outlook cannot represent")
+	}
+	if len(graph.created) != 0 {
+		t.Errorf("created %d events, want none", len(graph.created))
+	}
+}
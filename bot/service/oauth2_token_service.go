@@ -0,0 +1,178 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/storage"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// oauth2TokenService implements the authorization code flow with PKCE and
+// keeps the user's tokens in the linked accounts. Providers embed it and add
+// what differs between them, such as revocation.
+type oauth2TokenService struct {
+	provider                 model.Provider
+	oauth2Config             *oauth2.Config
+	authCodeOptions          []oauth2.AuthCodeOption
+	stateService             *OAuthStateService
+	linkedAccountsRepository storage.LinkedAccountRepository
+}
+
+// GetOAuth2URL returns the URL to redirect users for the provider's OAuth2 consent.
+func (s *oauth2TokenService) GetOAuth2URL(userID int) (string, error) {
+	state, err := s.stateService.Create(userID, s.provider)
+	if err != nil {
+		return "", err
+	}
+
+	opts := append([]oauth2.AuthCodeOption{oauth2.S256ChallengeOption(state.CodeVerifier)}, s.authCodeOptions...)
+	return s.oauth2Config.AuthCodeURL(state.State, opts...), nil
+}
+
+// ExchangeCodeForToken exchanges an authorization code for a token.
+func (s *oauth2TokenService) ExchangeCodeForToken(state string, code string) error {
+	oauthState, err := s.stateService.Consume(state, s.provider)
+	if err != nil {
+		return err
+	}
+
+	err = s.exchangeCodeForToken(oauthState, code)
+	if completeErr := s.stateService.Complete(state, err); completeErr != nil {
+		log.Error().
+			Int("userID", oauthState.UserID).
+			Err(completeErr).
+			Msg("Failed to record the outcome of the authorization request")
+	}
+
+	return err
+}
+
+func (s *oauth2TokenService) exchangeCodeForToken(state storage.OAuthState, code string) error {
+	token, err := s.oauth2Config.Exchange(context.Background(), code, oauth2.VerifierOption(state.CodeVerifier))
+	if err != nil {
+		return err
+	}
+
+	return s.linkedAccountsRepository.Save(storage.LinkedAccount{
+		UserID:       state.UserID,
+		Provider:     s.provider,
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		Expiry:       token.Expiry.UTC(),
+	})
+}
+
+// ClientForUser creates an HTTP client authenticated as the user, refreshing the token if it has expired.
+func (s *oauth2TokenService) ClientForUser(userID int) (*http.Client, error) {
+	account, err := s.linkedAccountsRepository.GetByUserIDAndProvider(userID, s.provider)
+	if err != nil {
+		return nil, err
+	} else if account.Status == storage.LinkedAccountBroken {
+		return nil, model.RelinkRequiredError{Provider: s.provider, Reason: account.StatusReason}
+	}
+
+	if time.Now().UTC().After(account.Expiry.UTC()) {
+		tokenSource := s.oauth2Config.TokenSource(context.Background(), &oauth2.Token{
+			RefreshToken: account.RefreshToken,
+		})
+		newToken, err := tokenSource.Token()
+		if err != nil {
+			return nil, s.checkGrantError(userID, err)
+		}
+
+		account.AccessToken = newToken.AccessToken
+		account.RefreshToken = newToken.RefreshToken
+		account.Expiry = newToken.Expiry.UTC()
+
+		err = s.linkedAccountsRepository.Save(account)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	oauthToken := &oauth2.Token{
+		AccessToken:  account.AccessToken,
+		RefreshToken: account.RefreshToken,
+		Expiry:       account.Expiry,
+		TokenType:    "Bearer",
+	}
+	return s.oauth2Config.Client(context.Background(), oauthToken), nil
+}
+
+// checkGrantError marks the user's account as broken if the provider no longer accepts its grant,
+// and turns the error into a RelinkRequiredError. Other errors are returned as is.
+func (s *oauth2TokenService) checkGrantError(userID int, err error) error {
+	reason, ok := grantErrorReason(err)
+	if !ok {
+		return err
+	}
+
+	log.Warn().
+		Int("userID", userID).
+		Str("provider", string(s.provider)).
+		Str("reason", reason).
+		Err(err).
+		Msg("Provider no longer accepts the user's grant, marking the account as broken")
+
+	updateErr := s.linkedAccountsRepository.UpdateStatus(userID, s.provider, storage.LinkedAccountBroken, reason)
+	if updateErr != nil {
+		log.Error().
+			Int("userID", userID).
+			Err(updateErr).
+			Msg("Failed to mark the linked account as broken")
+	}
+
+	return model.RelinkRequiredError{Provider: s.provider, Reason: reason}
+}
+
+// grantErrorReason tells whether the error means that the grant was revoked or has expired,
+// so that retrying will not help until the user links the account again.
+func grantErrorReason(err error) (string, bool) {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		switch retrieveErr.ErrorCode {
+		case "invalid_grant", "unauthorized_client", "invalid_client":
+			if retrieveErr.ErrorDescription != "" {
+				return retrieveErr.ErrorCode + ": " + retrieveErr.ErrorDescription, true
+			}
+			return retrieveErr.ErrorCode, true
+		}
+		return "", false
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusUnauthorized {
+		return "access token rejected", true
+	}
+
+	var graphErr *GraphError
+	if errors.As(err, &graphErr) && graphErr.StatusCode == http.StatusUnauthorized {
+		return "access token rejected", true
+	}
+	return "", false
+}
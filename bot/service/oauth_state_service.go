@@ -0,0 +1,143 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/storage"
+	"golang.org/x/oauth2"
+)
+
+const defaultOAuthStateTTL = 15 * time.Minute
+
+var (
+	ErrOAuthStateNotFound = model.ErrorForMessage("authorization request not found")
+	ErrOAuthStateExpired  = model.ErrorForMessage("authorization request has expired")
+	ErrOAuthStateUsed     = model.ErrorForMessage("authorization request was already used")
+)
+
+// NewOAuthStateService creates the state store shared by every TokenService.
+func NewOAuthStateService(
+	config OAuthConfig,
+	stateRepository storage.OAuthStateRepository,
+) *OAuthStateService {
+	ttl := config.StateTTL
+	if ttl <= 0 {
+		ttl = defaultOAuthStateTTL
+	}
+
+	return &OAuthStateService{
+		ttl:             ttl,
+		stateRepository: stateRepository,
+	}
+}
+
+// OAuthStateService keeps authorization requests in the database, so that they
+// survive restarts and can be used from the bot and the REST handlers alike.
+type OAuthStateService struct {
+	ttl             time.Duration
+	stateRepository storage.OAuthStateRepository
+}
+
+// Create starts a new authorization request of the user with an unguessable
+// state and a PKCE code verifier.
+func (s *OAuthStateService) Create(userID int, provider model.Provider) (storage.OAuthState, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return storage.OAuthState{}, err
+	}
+
+	state := storage.OAuthState{
+		State:        base64.RawURLEncoding.EncodeToString(token),
+		UserID:       userID,
+		Provider:     provider,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		Status:       storage.OAuthStatePending,
+		ExpiresAt:    time.Now().UTC().Add(s.ttl),
+	}
+	if err := s.stateRepository.Save(&state); err != nil {
+		return storage.OAuthState{}, err
+	}
+
+	return state, nil
+}
+
+// Consume returns the pending request for the state. A state can be consumed once,
+// and only before it expires.
+func (s *OAuthStateService) Consume(state string, provider model.Provider) (storage.OAuthState, error) {
+	consumed, err := s.stateRepository.Consume(state, provider)
+	var notFound model.NotFoundError
+	if !errors.As(err, &notFound) {
+		return consumed, err
+	}
+
+	// Tell apart why the state cannot be used, so that the user gets a helpful answer.
+	existing, err := s.stateRepository.GetByState(state)
+	if errors.As(err, &notFound) || (err == nil && existing.Provider != provider) {
+		return storage.OAuthState{}, ErrOAuthStateNotFound
+	} else if err != nil {
+		return storage.OAuthState{}, err
+	} else if existing.Status != storage.OAuthStatePending {
+		return storage.OAuthState{}, ErrOAuthStateUsed
+	}
+	return storage.OAuthState{}, ErrOAuthStateExpired
+}
+
+// Reject consumes the request and records that the user has not granted access.
+func (s *OAuthStateService) Reject(state string, provider model.Provider, reason string) error {
+	if _, err := s.Consume(state, provider); err != nil {
+		return err
+	}
+	return s.Complete(state, errors.New(reason))
+}
+
+// Complete records the outcome of the request, to be reported to the user later.
+func (s *OAuthStateService) Complete(state string, err error) error {
+	if err != nil {
+		return s.stateRepository.Complete(state, storage.OAuthStateFailed, err.Error())
+	}
+	return s.stateRepository.Complete(state, storage.OAuthStateCompleted, "")
+}
+
+// ClaimNotifications returns the finished requests the user has not been told about yet.
+func (s *OAuthStateService) ClaimNotifications(limit int) ([]storage.OAuthState, error) {
+	return s.stateRepository.ClaimUnnotified(limit)
+}
+
+// ReleaseNotification hands the request out again, for a notification that could not be sent.
+func (s *OAuthStateService) ReleaseNotification(state string) error {
+	return s.stateRepository.Unclaim(state)
+}
+
+// DeleteStale removes requests that are long finished or expired.
+func (s *OAuthStateService) DeleteStale() error {
+	// Keep finished requests around for a while, so that late notifications still go out.
+	return s.stateRepository.DeleteStale(time.Now().UTC().Add(-24 * time.Hour))
+}
+
+type OAuthConfig struct {
+	// StateTTL is how long a consent link stays valid.
+	StateTTL time.Duration `mapstructure:"state_ttl"`
+}
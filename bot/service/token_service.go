@@ -19,7 +19,16 @@
 
 package service
 
+import "github.com/ivgag/schedulr/model"
+
+// ErrProviderUnavailable is returned for providers this instance has no credentials for.
+var ErrProviderUnavailable = model.ErrorForMessage("calendar provider is not available")
+
 type TokenService interface {
-	GetOAuth2URL(userID int, callback func(error)) (string, error)
+	// GetOAuth2URL starts an authorization request and returns the consent URL.
+	// The outcome is recorded in the OAuthStateService once the user comes back.
+	GetOAuth2URL(userID int) (string, error)
 	ExchangeCodeForToken(state string, code string) error
+	// RevokeToken asks the provider to invalidate the user's grant.
+	RevokeToken(userID int) error
 }
@@ -21,24 +21,38 @@ package service
 
 import (
 	"errors"
+	"slices"
+	"time"
 
 	"github.com/ivgag/schedulr/model"
 	"github.com/ivgag/schedulr/storage"
+	"github.com/rs/zerolog/log"
+	"github.com/zsefvlol/timezonemapper"
 )
 
+// ErrUnknownTimeZone is returned when a time zone is not a known IANA name.
+var ErrUnknownTimeZone = model.ErrorForMessage("unknown time zone")
+
 func NewUserService(
 	userRepository storage.UserRepository,
+	linkedAccountRepository storage.LinkedAccountRepository,
 	tokenServices map[model.Provider]TokenService,
+	calDAVService *CalDAVCalendarService,
 ) *UserService {
 	return &UserService{
-		userRepository: userRepository,
-		tokenServices:  tokenServices,
+		userRepository:          userRepository,
+		linkedAccountRepository: linkedAccountRepository,
+		tokenServices:           tokenServices,
+		calDAVService:           calDAVService,
 	}
 }
 
 type UserService struct {
-	userRepository storage.UserRepository
-	tokenServices  map[model.Provider]TokenService
+	userRepository          storage.UserRepository
+	linkedAccountRepository storage.LinkedAccountRepository
+	tokenServices           map[model.Provider]TokenService
+	// calDAVService links CalDAV accounts, which use credentials instead of OAuth.
+	calDAVService *CalDAVCalendarService
 }
 
 func (s *UserService) GetUserByID(id int) (storage.User, error) {
@@ -53,7 +67,44 @@ func (s *UserService) CreateUser(user *storage.User) error {
 	return s.userRepository.Save(user)
 }
 
-func (s *UserService) GetOAuth2Url(telegramID int64, callback func(error), provider model.Provider) (string, error) {
+// SetTimeZone stores the user's time zone given as an IANA name, e.g. "Europe/Berlin".
+func (s *UserService) SetTimeZone(telegramID int64, timeZone string) (*time.Location, error) {
+	location, err := time.LoadLocation(timeZone)
+	if err != nil || timeZone == "" || timeZone == "Local" {
+		return nil, ErrUnknownTimeZone
+	}
+
+	user, err := s.userRepository.GetByTelegramID(telegramID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepository.UpdateTimeZone(user.ID, location.String()); err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+// SetTimeZoneFromLocation stores the time zone of the place the user has shared.
+func (s *UserService) SetTimeZoneFromLocation(telegramID int64, latitude float64, longitude float64) (*time.Location, error) {
+	return s.SetTimeZone(telegramID, timezonemapper.LatLngToTimezoneString(latitude, longitude))
+}
+
+// UserLocation returns the user's time zone, falling back to the server's one
+// for users who have not set it.
+func UserLocation(user storage.User) *time.Location {
+	if user.TimeZone == "" {
+		return time.Local
+	}
+
+	location, err := time.LoadLocation(user.TimeZone)
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
+
+func (s *UserService) GetOAuth2Url(telegramID int64, provider model.Provider) (string, error) {
 	user, err := s.userRepository.GetByTelegramID(telegramID)
 	if err != nil {
 		return "", err
@@ -61,9 +112,154 @@ func (s *UserService) GetOAuth2Url(telegramID int64, callback func(error), provi
 		return "", errors.New("user not found")
 	}
 
-	return s.tokenServices[provider].GetOAuth2URL(user.ID, callback)
+	tokenService, err := s.tokenService(provider)
+	if err != nil {
+		return "", err
+	}
+	return tokenService.GetOAuth2URL(user.ID)
+}
+
+// LinkedAccounts returns the accounts the user has linked, including broken ones.
+func (s *UserService) LinkedAccounts(telegramID int64) ([]storage.LinkedAccount, error) {
+	user, err := s.userRepository.GetByTelegramID(telegramID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.linkedAccountRepository.ListByUserID(user.ID)
+}
+
+// UnlinkAccount revokes the user's grant and forgets the linked account.
+// The account is forgotten even if the provider could not revoke the grant,
+// in which case revoked is false and the user has to remove the access on the provider's side.
+// CalDAV accounts sign in with an app password, which only the user can revoke.
+func (s *UserService) UnlinkAccount(userID int, provider model.Provider) (revoked bool, err error) {
+	if _, err := s.linkedAccountRepository.GetByUserIDAndProvider(userID, provider); err != nil {
+		return false, err
+	}
+
+	if provider == model.ProviderCalDAV {
+		return false, s.linkedAccountRepository.Delete(userID, provider)
+	}
+
+	revokeErr := ErrProviderUnavailable
+	if tokenService, ok := s.tokenServices[provider]; ok {
+		revokeErr = tokenService.RevokeToken(userID)
+	}
+	if revokeErr != nil {
+		log.Warn().
+			Int("userID", userID).
+			Str("provider", string(provider)).
+			Err(revokeErr).
+			Msg("Failed to revoke the grant, unlinking the account anyway")
+	}
+
+	if err := s.linkedAccountRepository.Delete(userID, provider); err != nil {
+		return false, err
+	}
+	return revokeErr == nil, nil
 }
 
 func (s *UserService) LinkAccount(state string, provider model.Provider, code string) error {
-	return s.tokenServices[provider].ExchangeCodeForToken(state, code)
+	tokenService, err := s.tokenService(provider)
+	if err != nil {
+		return err
+	}
+	return tokenService.ExchangeCodeForToken(state, code)
+}
+
+// LinkCalDAVAccount checks the credentials against the CalDAV server and links the account.
+func (s *UserService) LinkCalDAVAccount(telegramID int64, serverURL string, username string, password string) error {
+	if s.calDAVService == nil {
+		return ErrProviderUnavailable
+	}
+
+	user, err := s.userRepository.GetByTelegramID(telegramID)
+	if err != nil {
+		return err
+	}
+
+	return s.calDAVService.LinkAccount(user.ID, serverURL, username, password)
+}
+
+// Providers returns the calendar providers users can link, in the order they are offered.
+func (s *UserService) Providers() []model.Provider {
+	var providers []model.Provider
+	for _, provider := range model.Providers {
+		if _, ok := s.tokenServices[provider]; ok {
+			providers = append(providers, provider)
+		} else if provider == model.ProviderCalDAV && s.calDAVService != nil {
+			providers = append(providers, provider)
+		}
+	}
+	return providers
+}
+
+// CalendarProvider returns the default provider new events of the user are created in:
+// the chosen or the first one with a working linked account, or ProviderICS without one.
+func (s *UserService) CalendarProvider(userID int) (model.Provider, error) {
+	providers, err := s.CalendarProviders(userID)
+	if err != nil {
+		return "", err
+	}
+	return providers[0], nil
+}
+
+// CalendarProviders returns the providers new events are created in: the default
+// one first, followed by the other linked ones if the user creates events in all of them.
+func (s *UserService) CalendarProviders(userID int) ([]model.Provider, error) {
+	user, err := s.userRepository.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := s.linkedAccountRepository.ListByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var linked []model.Provider
+	for _, provider := range s.Providers() {
+		for _, account := range accounts {
+			if account.Provider == provider && account.Status != storage.LinkedAccountBroken {
+				linked = append(linked, provider)
+			}
+		}
+	}
+
+	// Users without a working linked account get their events as iCalendar files.
+	if user.DefaultProvider == model.ProviderICS || len(linked) == 0 {
+		linked = append([]model.Provider{model.ProviderICS}, linked...)
+	}
+
+	// The chosen default goes first, as long as its account can be used.
+	if i := slices.Index(linked, user.DefaultProvider); i > 0 {
+		linked = append([]model.Provider{user.DefaultProvider}, slices.Delete(linked, i, i+1)...)
+	}
+	if !user.AllProviders {
+		return linked[:1], nil
+	}
+	return linked, nil
+}
+
+// SetCalendarProviders makes new events of the user go to the default provider
+// and, if all is set, to every other linked provider as well.
+func (s *UserService) SetCalendarProviders(telegramID int64, defaultProvider model.Provider, all bool) error {
+	if defaultProvider != "" && defaultProvider != model.ProviderICS && !slices.Contains(s.Providers(), defaultProvider) {
+		return ErrProviderUnavailable
+	}
+
+	user, err := s.userRepository.GetByTelegramID(telegramID)
+	if err != nil {
+		return err
+	}
+	return s.userRepository.UpdateCalendarProviders(user.ID, defaultProvider, all)
+}
+
+func (s *UserService) tokenService(provider model.Provider) (TokenService, error) {
+	tokenService, ok := s.tokenServices[provider]
+	if !ok {
+		return nil, ErrProviderUnavailable
+	}
+	return tokenService, nil
 }
@@ -0,0 +1,109 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/service"
+	"github.com/ivgag/schedulr/storage"
+)
+
+func newTimeZoneUserService(timeZone string) (*service.UserService, *fakeUsers) {
+	users := &fakeUsers{user: storage.User{ID: eventUserID, TelegramID: eventTelegramID, TimeZone: timeZone}}
+	accounts := &fakeLinkedAccounts{accounts: map[model.Provider]storage.LinkedAccount{}}
+	return service.NewUserService(users, accounts, nil, nil), users
+}
+
+func TestUserService_SetTimeZone(t *testing.T) {
+	tests := []struct {
+		name       string
+		telegramID int64
+		timeZone   string
+		want       string
+		wantErr    error
+	}{
+		{name: "IANA name", telegramID: eventTelegramID, timeZone: "America/New_York", want: "America/New_York"},
+		{name: "UTC", telegramID: eventTelegramID, timeZone: "UTC", want: "UTC"},
+		{name: "unknown name", telegramID: eventTelegramID, timeZone: "Mars/Olympus_Mons", wantErr: service.ErrUnknownTimeZone},
+		{name: "empty name", telegramID: eventTelegramID, timeZone: "", wantErr: service.ErrUnknownTimeZone},
+		{name: "server's zone", telegramID: eventTelegramID, timeZone: "Local", wantErr: service.ErrUnknownTimeZone},
+		{name: "unknown user", telegramID: eventTelegramID + 1, timeZone: "Europe/Berlin", wantErr: model.NotFoundError{Message: "user not found"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userService, users := newTimeZoneUserService("Europe/Berlin")
+
+			location, err := userService.SetTimeZone(tt.telegramID, tt.timeZone)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SetTimeZone() error = %v, want %v", err, tt.wantErr)
+			}
+
+			want := tt.want
+			if tt.wantErr != nil {
+				want = "Europe/Berlin"
+			} else if location.String() != tt.want {
+				t.Errorf("SetTimeZone() = %v, want %s", location, tt.want)
+			}
+			if users.user.TimeZone != want {
+				t.Errorf("stored time zone = %q, want %q", users.user.TimeZone, want)
+			}
+		})
+	}
+}
+
+func TestUserService_SetTimeZoneFromLocation(t *testing.T) {
+	tests := []struct {
+		name      string
+		latitude  float64
+		longitude float64
+		want      string
+	}{
+		{name: "Berlin", latitude: 52.52, longitude: 13.405, want: "Europe/Berlin"},
+		{name: "Tokyo", latitude: 35.6762, longitude: 139.6503, want: "Asia/Tokyo"},
+		{name: "New York", latitude: 40.7128, longitude: -74.006, want: "America/New_York"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userService, users := newTimeZoneUserService("")
+
+			location, err := userService.SetTimeZoneFromLocation(eventTelegramID, tt.latitude, tt.longitude)
+			if err != nil {
+				t.Fatalf("SetTimeZoneFromLocation() error = %v", err)
+			}
+			if location.String() != tt.want || users.user.TimeZone != tt.want {
+				t.Errorf("SetTimeZoneFromLocation() = %v, stored %q, want %s", location, users.user.TimeZone, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserLocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeZone string
+		want     *time.Location
+	}{
+		{name: "without a time zone", want: time.Local},
+		{name: "unknown time zone", timeZone: "Mars/Olympus_Mons", want: time.Local},
+		{name: "IANA name", timeZone: "Asia/Tokyo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			location := service.UserLocation(storage.User{TimeZone: tt.timeZone})
+			if tt.want != nil {
+				if location != tt.want {
+					t.Errorf("UserLocation() = %v, want the server's zone", location)
+				}
+				return
+			}
+			if location.String() != tt.timeZone {
+				t.Errorf("UserLocation() = %v, want %s", location, tt.timeZone)
+			}
+		})
+	}
+}
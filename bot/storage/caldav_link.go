@@ -0,0 +1,51 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage
+
+import "time"
+
+// CalDAVLinkStep is the answer a /linkcaldav conversation waits for.
+type CalDAVLinkStep int
+
+const (
+	CalDAVStepServer CalDAVLinkStep = iota
+	CalDAVStepUsername
+	CalDAVStepPassword
+)
+
+// CalDAVLink is a /linkcaldav conversation waiting for the user's next answer.
+// It is kept in the database, so that whichever instance of the bot receives
+// the answer can continue it. The password is never stored.
+type CalDAVLink struct {
+	ChatID    int64
+	Step      CalDAVLinkStep
+	ServerURL string
+	Username  string
+	ExpiresAt time.Time
+}
+
+type CalDAVLinkRepository interface {
+	// Save creates or replaces the conversation of the chat.
+	Save(link CalDAVLink) error
+	// GetByChatID returns the conversation of the chat unless it has expired.
+	GetByChatID(chatID int64) (CalDAVLink, error)
+	// Delete removes the conversation of the chat and reports whether it was still going.
+	Delete(chatID int64) (bool, error)
+}
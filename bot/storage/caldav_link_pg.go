@@ -0,0 +1,84 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/ivgag/schedulr/model"
+)
+
+func NewCalDAVLinkRepository(db *sql.DB) CalDAVLinkRepository {
+	return &PgCalDAVLinkRepository{db: db}
+}
+
+type PgCalDAVLinkRepository struct {
+	db *sql.DB
+}
+
+// Save implements CalDAVLinkRepository.
+func (r *PgCalDAVLinkRepository) Save(link CalDAVLink) error {
+	// Forgotten conversations are dropped here, so that their answers do not linger.
+	if _, err := r.db.Exec("DELETE FROM caldav_links WHERE expires_at <= timezone('utc', now())"); err != nil {
+		return err
+	}
+
+	_, err := r.db.Exec(`
+	INSERT INTO caldav_links(chat_id, step, server_url, username, expires_at)
+	VALUES($1, $2, $3, $4, $5)
+	ON CONFLICT (chat_id) DO UPDATE
+	SET step = EXCLUDED.step, server_url = EXCLUDED.server_url,
+		username = EXCLUDED.username, expires_at = EXCLUDED.expires_at
+	`,
+		link.ChatID, link.Step, link.ServerURL, link.Username, link.ExpiresAt.UTC(),
+	)
+	return err
+}
+
+// GetByChatID implements CalDAVLinkRepository.
+func (r *PgCalDAVLinkRepository) GetByChatID(chatID int64) (CalDAVLink, error) {
+	var link CalDAVLink
+
+	err := r.db.QueryRow(`
+	SELECT chat_id, step, server_url, username, expires_at
+	FROM caldav_links
+	WHERE chat_id = $1 AND expires_at > timezone('utc', now())
+	`, chatID).Scan(&link.ChatID, &link.Step, &link.ServerURL, &link.Username, &link.ExpiresAt)
+	if err != nil && err.Error() == noRowsError {
+		return CalDAVLink{}, model.NotFoundError{Message: "no CalDAV link in progress"}
+	} else if err != nil {
+		return CalDAVLink{}, err
+	}
+	return link, nil
+}
+
+// Delete implements CalDAVLinkRepository.
+func (r *PgCalDAVLinkRepository) Delete(chatID int64) (bool, error) {
+	var active bool
+
+	err := r.db.QueryRow(
+		"DELETE FROM caldav_links WHERE chat_id = $1 RETURNING expires_at > timezone('utc', now())",
+		chatID,
+	).Scan(&active)
+	if err != nil && err.Error() == noRowsError {
+		return false, nil
+	}
+	return active, err
+}
@@ -0,0 +1,40 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage
+
+import "github.com/ivgag/schedulr/model"
+
+// CalendarPreference is the calendar the user's events of the provider are created in.
+// The preference with an empty EventType is the default for events of every other type.
+type CalendarPreference struct {
+	UserID       int
+	Provider     model.Provider
+	EventType    string
+	CalendarID   string
+	CalendarName string
+}
+
+type CalendarPreferenceRepository interface {
+	// Save creates or replaces the preference for its user, provider and event type.
+	Save(preference CalendarPreference) error
+	ListByUserID(userID int) ([]CalendarPreference, error)
+	Delete(userID int, provider model.Provider, eventType string) error
+	DeleteByProvider(userID int, provider model.Provider) error
+}
@@ -0,0 +1,98 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/ivgag/schedulr/model"
+)
+
+func NewCalendarPreferenceRepository(db *sql.DB) CalendarPreferenceRepository {
+	return &PgCalendarPreferenceRepository{db: db}
+}
+
+type PgCalendarPreferenceRepository struct {
+	db *sql.DB
+}
+
+// Save implements CalendarPreferenceRepository.
+func (r *PgCalendarPreferenceRepository) Save(preference CalendarPreference) error {
+	_, err := r.db.Exec(`
+	INSERT INTO calendar_preferences(user_id, provider, event_type, calendar_id, calendar_name)
+	VALUES($1, $2, $3, $4, $5)
+	ON CONFLICT (user_id, provider, event_type) DO UPDATE
+	SET calendar_id = EXCLUDED.calendar_id,
+		calendar_name = EXCLUDED.calendar_name,
+		updated_at = timezone('utc', now())
+	`,
+		preference.UserID, preference.Provider, preference.EventType, preference.CalendarID, preference.CalendarName,
+	)
+	return err
+}
+
+// ListByUserID implements CalendarPreferenceRepository.
+func (r *PgCalendarPreferenceRepository) ListByUserID(userID int) ([]CalendarPreference, error) {
+	rows, err := r.db.Query(`
+	SELECT user_id, provider, event_type, calendar_id, calendar_name
+	FROM calendar_preferences
+	WHERE user_id = $1
+	ORDER BY provider, event_type
+	`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var preferences []CalendarPreference
+	for rows.Next() {
+		var preference CalendarPreference
+		err := rows.Scan(
+			&preference.UserID, &preference.Provider, &preference.EventType,
+			&preference.CalendarID, &preference.CalendarName,
+		)
+		if err != nil {
+			return nil, err
+		}
+		preferences = append(preferences, preference)
+	}
+
+	return preferences, rows.Err()
+}
+
+// Delete implements CalendarPreferenceRepository.
+func (r *PgCalendarPreferenceRepository) Delete(userID int, provider model.Provider, eventType string) error {
+	_, err := r.db.Exec(
+		"DELETE FROM calendar_preferences WHERE user_id = $1 AND provider = $2 AND event_type = $3",
+		userID, provider, eventType,
+	)
+	return err
+}
+
+// DeleteByProvider implements CalendarPreferenceRepository.
+func (r *PgCalendarPreferenceRepository) DeleteByProvider(userID int, provider model.Provider) error {
+	_, err := r.db.Exec(
+		"DELETE FROM calendar_preferences WHERE user_id = $1 AND provider = $2",
+		userID, provider,
+	)
+	return err
+}
@@ -1,5 +1,14 @@
 package storage
 
 type DatabaseConfig struct {
-	URL string `mapstructure:"url"`
+	URL        string           `mapstructure:"url"`
+	Encryption EncryptionConfig `mapstructure:"encryption"`
+}
+
+// EncryptionConfig holds the key-encryption keys for OAuth tokens.
+// Keys are base64-encoded 32-byte AES keys, indexed by their ID. Retired keys
+// stay in the map until the tokens are re-encrypted with the active one.
+type EncryptionConfig struct {
+	ActiveKeyID string            `mapstructure:"active_key_id"`
+	Keys        map[string]string `mapstructure:"keys"`
 }
@@ -0,0 +1,70 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage
+
+import (
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+)
+
+type EventDraftStatus string
+
+const (
+	DraftStatusPending   EventDraftStatus = "pending"
+	DraftStatusCreated   EventDraftStatus = "created"
+	DraftStatusDiscarded EventDraftStatus = "discarded"
+)
+
+// EventDraft is an extracted event waiting for the user to confirm it
+// before it is written to the calendar. Drafts extracted from the same
+// messages share a BatchID.
+type EventDraft struct {
+	ID               int
+	UserID           int
+	BatchID          string
+	ChatID           int64
+	MessageID        int
+	SourceMessageIDs []int
+	Provider         model.Provider
+	// CalendarID is the calendar of the provider the event is created in,
+	// empty for the user's default calendar.
+	CalendarID   string
+	CalendarName string
+	Event        model.Event
+	Status       EventDraftStatus
+	CreatedAt    time.Time
+}
+
+type EventDraftRepository interface {
+	Save(draft *EventDraft) error
+	GetByID(id int) (EventDraft, error)
+	GetByMessageID(chatID int64, messageID int) (EventDraft, error)
+	ListByBatchID(batchID string) ([]EventDraft, error)
+	// GetLatestActiveBatchID returns the most recent batch of the user
+	// that still has pending or created drafts.
+	GetLatestActiveBatchID(userID int) (string, error)
+	// UpdateStatus moves the draft to the new status only if it is currently
+	// in the expected one. It reports whether the draft was updated.
+	UpdateStatus(id int, from EventDraftStatus, to EventDraftStatus) (bool, error)
+	// DiscardPendingByProvider discards the user's pending drafts for the provider
+	// and returns them.
+	DiscardPendingByProvider(userID int, provider model.Provider) ([]EventDraft, error)
+}
@@ -0,0 +1,207 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/ivgag/schedulr/model"
+)
+
+func NewEventDraftRepository(db *sql.DB) EventDraftRepository {
+	return &PgEventDraftRepository{db: db}
+}
+
+type PgEventDraftRepository struct {
+	db *sql.DB
+}
+
+// Save implements EventDraftRepository.
+func (r *PgEventDraftRepository) Save(draft *EventDraft) error {
+	event, err := json.Marshal(&draft.Event)
+	if err != nil {
+		return err
+	}
+
+	if draft.ID == 0 {
+		sourceMessageIDs, err := json.Marshal(draft.SourceMessageIDs)
+		if err != nil {
+			return err
+		}
+
+		return r.db.QueryRow(`
+		INSERT INTO event_drafts(
+			user_id, batch_id, chat_id, message_id, source_message_ids, provider, calendar_id, calendar_name, event, status
+		)
+		VALUES($1, $2, $3, NULLIF($4, 0), $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at
+		`,
+			draft.UserID, draft.BatchID, draft.ChatID, draft.MessageID, sourceMessageIDs, draft.Provider,
+			draft.CalendarID, draft.CalendarName, event, draft.Status,
+		).Scan(&draft.ID, &draft.CreatedAt)
+	}
+
+	_, err = r.db.Exec(`
+	UPDATE event_drafts
+	SET message_id = NULLIF($2, 0),
+		provider = $3,
+		calendar_id = $4,
+		calendar_name = $5,
+		event = $6,
+		updated_at = timezone('utc', now())
+	WHERE id = $1
+	`,
+		draft.ID, draft.MessageID, draft.Provider, draft.CalendarID, draft.CalendarName, event,
+	)
+	return err
+}
+
+// GetByID implements EventDraftRepository.
+func (r *PgEventDraftRepository) GetByID(id int) (EventDraft, error) {
+	return scanEventDraft(r.db.QueryRow(selectEventDraftQuery+"WHERE id = $1", id))
+}
+
+// GetByMessageID implements EventDraftRepository.
+func (r *PgEventDraftRepository) GetByMessageID(chatID int64, messageID int) (EventDraft, error) {
+	return scanEventDraft(r.db.QueryRow(
+		selectEventDraftQuery+"WHERE chat_id = $1 AND message_id = $2",
+		chatID, messageID,
+	))
+}
+
+// ListByBatchID implements EventDraftRepository.
+func (r *PgEventDraftRepository) ListByBatchID(batchID string) ([]EventDraft, error) {
+	rows, err := r.db.Query(selectEventDraftQuery+"WHERE batch_id = $1 ORDER BY id", batchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var drafts []EventDraft
+	for rows.Next() {
+		draft, err := scanEventDraft(rows)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, draft)
+	}
+
+	return drafts, rows.Err()
+}
+
+// GetLatestActiveBatchID implements EventDraftRepository.
+func (r *PgEventDraftRepository) GetLatestActiveBatchID(userID int) (string, error) {
+	var batchID string
+
+	err := r.db.QueryRow(`
+	SELECT batch_id
+	FROM event_drafts
+	WHERE user_id = $1 AND batch_id <> '' AND status IN ($2, $3)
+	ORDER BY created_at DESC, id DESC
+	LIMIT 1`,
+		userID, DraftStatusPending, DraftStatusCreated,
+	).Scan(&batchID)
+
+	if err != nil && err.Error() == noRowsError {
+		return "", model.NotFoundError{Message: "nothing to undo"}
+	} else if err != nil {
+		return "", err
+	}
+	return batchID, nil
+}
+
+// UpdateStatus implements EventDraftRepository.
+func (r *PgEventDraftRepository) UpdateStatus(id int, from EventDraftStatus, to EventDraftStatus) (bool, error) {
+	result, err := r.db.Exec(`
+	UPDATE event_drafts
+	SET status = $3, updated_at = timezone('utc', now())
+	WHERE id = $1 AND status = $2
+	`,
+		id, from, to,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// DiscardPendingByProvider implements EventDraftRepository.
+func (r *PgEventDraftRepository) DiscardPendingByProvider(userID int, provider model.Provider) ([]EventDraft, error) {
+	rows, err := r.db.Query(`
+	UPDATE event_drafts
+	SET status = $4, updated_at = timezone('utc', now())
+	WHERE user_id = $1 AND provider = $2 AND status = $3
+	RETURNING `+eventDraftColumns,
+		userID, provider, DraftStatusPending, DraftStatusDiscarded,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var drafts []EventDraft
+	for rows.Next() {
+		draft, err := scanEventDraft(rows)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, draft)
+	}
+
+	return drafts, rows.Err()
+}
+
+const eventDraftColumns = `id, user_id, batch_id, chat_id, COALESCE(message_id, 0), source_message_ids, provider,
+	calendar_id, calendar_name, event, status, created_at`
+
+const selectEventDraftQuery = `
+	SELECT ` + eventDraftColumns + `
+	FROM event_drafts
+	`
+
+func scanEventDraft(row rowScanner) (EventDraft, error) {
+	var draft EventDraft
+	var sourceMessageIDs []byte
+	var event []byte
+
+	err := row.Scan(
+		&draft.ID, &draft.UserID, &draft.BatchID, &draft.ChatID, &draft.MessageID, &sourceMessageIDs,
+		&draft.Provider, &draft.CalendarID, &draft.CalendarName, &event, &draft.Status, &draft.CreatedAt,
+	)
+	if err != nil && err.Error() == noRowsError {
+		return EventDraft{}, model.NotFoundError{Message: "draft not found"}
+	} else if err != nil {
+		return EventDraft{}, err
+	}
+
+	if err := json.Unmarshal(sourceMessageIDs, &draft.SourceMessageIDs); err != nil {
+		return EventDraft{}, err
+	}
+	if err := json.Unmarshal(event, &draft.Event); err != nil {
+		return EventDraft{}, err
+	}
+	return draft, nil
+}
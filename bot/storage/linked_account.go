@@ -25,6 +25,14 @@ import (
 	"github.com/ivgag/schedulr/model"
 )
 
+type LinkedAccountStatus string
+
+const (
+	LinkedAccountActive LinkedAccountStatus = "active"
+	// LinkedAccountBroken accounts have a grant the provider no longer accepts.
+	LinkedAccountBroken LinkedAccountStatus = "broken"
+)
+
 type LinkedAccount struct {
 	ID           int
 	UserID       int
@@ -32,9 +40,22 @@ type LinkedAccount struct {
 	AccessToken  string
 	RefreshToken string
 	Expiry       time.Time
+	// ServerURL and Username identify accounts of providers that use credentials
+	// instead of OAuth, such as CalDAV. AccessToken then holds the password.
+	ServerURL       string
+	Username        string
+	Status          LinkedAccountStatus
+	StatusReason    string
+	StatusChangedAt time.Time
 }
 
 type LinkedAccountRepository interface {
 	Save(account LinkedAccount) error
 	GetByUserIDAndProvider(userID int, provider model.Provider) (LinkedAccount, error)
+	ListByUserID(userID int) ([]LinkedAccount, error)
+	UpdateStatus(userID int, provider model.Provider, status LinkedAccountStatus, reason string) error
+	Delete(userID int, provider model.Provider) error
+	// ReencryptTokens encrypts plaintext tokens and tokens sealed with a retired key
+	// with the active key. It returns the number of updated accounts.
+	ReencryptTokens() (int, error)
 }
@@ -25,27 +25,48 @@ import (
 	"github.com/ivgag/schedulr/model"
 )
 
-func NewLinkedAccountRepository(db *sql.DB) LinkedAccountRepository {
-	return &PgLinkedAccountRepository{db: db}
+func NewLinkedAccountRepository(db *sql.DB, tokenCipher *TokenCipher) LinkedAccountRepository {
+	return &PgLinkedAccountRepository{db: db, tokenCipher: tokenCipher}
 }
 
 type PgLinkedAccountRepository struct {
-	db *sql.DB
+	db          *sql.DB
+	tokenCipher *TokenCipher
 }
 
-// Save implements ConnectedAccountRepository.
+// Save implements ConnectedAccountRepository. Saving fresh tokens makes the account active again.
 func (p *PgLinkedAccountRepository) Save(account LinkedAccount) error {
+	accessToken, err := p.tokenCipher.Encrypt(account.AccessToken)
+	if err != nil {
+		return err
+	}
+	refreshToken, err := p.tokenCipher.Encrypt(account.RefreshToken)
+	if err != nil {
+		return err
+	}
+
 	row := p.db.QueryRow(`
-	INSERT INTO linked_accounts(user_id, provider, access_token, refresh_token, expiry, created_at, updated_at)
-	VALUES($1, $2, $3, $4, $5, timezone('utc', now()), timezone('utc', now()))
+	INSERT INTO linked_accounts(
+		user_id, provider, access_token, refresh_token, expiry, server_url, username, created_at, updated_at
+	)
+	VALUES($1, $2, $3, $4, $5, $7, $8, timezone('utc', now()), timezone('utc', now()))
 	ON CONFLICT (user_id, provider) DO UPDATE
 	SET access_token = EXCLUDED.access_token,
 		refresh_token = EXCLUDED.refresh_token,
 		expiry = EXCLUDED.expiry,
+		server_url = EXCLUDED.server_url,
+		username = EXCLUDED.username,
+		status = $6,
+		status_reason = '',
+		status_changed_at = CASE
+			WHEN linked_accounts.status = $6 THEN linked_accounts.status_changed_at
+			ELSE timezone('utc', now())
+		END,
 		updated_at = timezone('utc', now())
 	RETURNING id
 	`,
-		account.UserID, account.Provider, account.AccessToken, account.RefreshToken, account.Expiry,
+		account.UserID, account.Provider, accessToken, refreshToken, account.Expiry, LinkedAccountActive,
+		account.ServerURL, account.Username,
 	)
 
 	if err := row.Scan(&account.ID); err != nil {
@@ -57,20 +78,143 @@ func (p *PgLinkedAccountRepository) Save(account LinkedAccount) error {
 
 // GetByUserIDAndProvider implements ConnectedAccountRepository.
 func (p *PgLinkedAccountRepository) GetByUserIDAndProvider(userID int, provider model.Provider) (LinkedAccount, error) {
-	var account LinkedAccount
+	return p.scanLinkedAccount(p.db.QueryRow(
+		selectLinkedAccountQuery+"WHERE user_id = $1 AND provider = $2",
+		userID, provider,
+	))
+}
 
-	err := p.db.QueryRow(`
-	SELECT id, user_id, provider, access_token, refresh_token, expiry 
+// ListByUserID implements LinkedAccountRepository.
+func (p *PgLinkedAccountRepository) ListByUserID(userID int) ([]LinkedAccount, error) {
+	rows, err := p.db.Query(selectLinkedAccountQuery+"WHERE user_id = $1 ORDER BY provider", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []LinkedAccount
+	for rows.Next() {
+		account, err := p.scanLinkedAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+
+	return accounts, rows.Err()
+}
+
+// UpdateStatus implements LinkedAccountRepository.
+func (p *PgLinkedAccountRepository) UpdateStatus(
+	userID int,
+	provider model.Provider,
+	status LinkedAccountStatus,
+	reason string,
+) error {
+	_, err := p.db.Exec(`
+	UPDATE linked_accounts
+	SET status = $3,
+		status_reason = $4,
+		status_changed_at = timezone('utc', now()),
+		updated_at = timezone('utc', now())
+	WHERE user_id = $1 AND provider = $2
+	`,
+		userID, provider, status, reason,
+	)
+	return err
+}
+
+// Delete implements LinkedAccountRepository.
+func (p *PgLinkedAccountRepository) Delete(userID int, provider model.Provider) error {
+	_, err := p.db.Exec("DELETE FROM linked_accounts WHERE user_id = $1 AND provider = $2", userID, provider)
+	return err
+}
+
+const selectLinkedAccountQuery = `
+	SELECT id, user_id, provider, COALESCE(access_token, ''), COALESCE(refresh_token, ''), expiry,
+		server_url, username, status, status_reason, COALESCE(status_changed_at, created_at)
 	FROM linked_accounts
-	WHERE user_id = $1 AND provider = $2`,
-		userID, provider,
-	).Scan(&account.ID, &account.UserID, &account.Provider, &account.AccessToken, &account.RefreshToken, &account.Expiry)
+	`
 
+func (p *PgLinkedAccountRepository) scanLinkedAccount(row rowScanner) (LinkedAccount, error) {
+	var account LinkedAccount
+
+	err := row.Scan(
+		&account.ID, &account.UserID, &account.Provider, &account.AccessToken, &account.RefreshToken, &account.Expiry,
+		&account.ServerURL, &account.Username, &account.Status, &account.StatusReason, &account.StatusChangedAt,
+	)
 	if err != nil && err.Error() == noRowsError {
 		return LinkedAccount{}, model.NotFoundError{Message: "account not found"}
 	} else if err != nil {
 		return LinkedAccount{}, err
-	} else {
-		return account, nil
 	}
+
+	if account.AccessToken, err = p.tokenCipher.Decrypt(account.AccessToken); err != nil {
+		return LinkedAccount{}, err
+	}
+	if account.RefreshToken, err = p.tokenCipher.Decrypt(account.RefreshToken); err != nil {
+		return LinkedAccount{}, err
+	}
+	return account, nil
+}
+
+// ReencryptTokens implements LinkedAccountRepository.
+func (p *PgLinkedAccountRepository) ReencryptTokens() (int, error) {
+	rows, err := p.db.Query("SELECT id, COALESCE(access_token, ''), COALESCE(refresh_token, '') FROM linked_accounts")
+	if err != nil {
+		return 0, err
+	}
+
+	type tokens struct {
+		id           int
+		accessToken  string
+		refreshToken string
+	}
+	var stale []tokens
+	for rows.Next() {
+		var t tokens
+		if err := rows.Scan(&t.id, &t.accessToken, &t.refreshToken); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if p.tokenCipher.NeedsReencryption(t.accessToken) || p.tokenCipher.NeedsReencryption(t.refreshToken) {
+			stale = append(stale, t)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for i, t := range stale {
+		accessToken, err := p.reencrypt(t.accessToken)
+		if err != nil {
+			return i, err
+		}
+		refreshToken, err := p.reencrypt(t.refreshToken)
+		if err != nil {
+			return i, err
+		}
+
+		_, err = p.db.Exec(`
+		UPDATE linked_accounts
+		SET access_token = $2, refresh_token = $3, updated_at = timezone('utc', now())
+		WHERE id = $1
+		`,
+			t.id, accessToken, refreshToken,
+		)
+		if err != nil {
+			return i, err
+		}
+	}
+
+	return len(stale), nil
+}
+
+func (p *PgLinkedAccountRepository) reencrypt(value string) (string, error) {
+	plaintext, err := p.tokenCipher.Decrypt(value)
+	if err != nil {
+		return "", err
+	}
+	return p.tokenCipher.Encrypt(plaintext)
 }
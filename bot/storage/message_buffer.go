@@ -0,0 +1,50 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage
+
+import (
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+)
+
+// BufferedBatch is the messages of a chat that are processed together,
+// oldest first.
+type BufferedBatch struct {
+	ChatID   int64
+	Messages []model.TextMessage
+	// LastID is the ID of the newest buffered message in the batch.
+	LastID int
+}
+
+// MessageBufferRepository collects the messages users send in a burst, so that they
+// are processed together once the chat has been quiet for a while. The buffer is
+// shared by every instance of the bot and survives restarts.
+type MessageBufferRepository interface {
+	// Append adds the message to the buffer of the chat and moves the deadline
+	// of the buffer to debounce from now.
+	Append(chatID int64, message model.TextMessage, debounce time.Duration) error
+	// ClaimDue leases up to limit buffers whose deadline has passed and returns their
+	// messages. A buffer that is not completed within the lease is claimed again.
+	ClaimDue(limit int, lease time.Duration) ([]BufferedBatch, error)
+	// Complete removes the messages of the batch. Messages added since it was claimed
+	// stay buffered until their own deadline.
+	Complete(batch BufferedBatch) error
+}
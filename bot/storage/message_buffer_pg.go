@@ -0,0 +1,175 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+)
+
+func NewMessageBufferRepository(db *sql.DB) MessageBufferRepository {
+	return &PgMessageBufferRepository{db: db}
+}
+
+// PgMessageBufferRepository keeps a row per chat with its deadline and lease,
+// and the messages of the chat in buffered_messages. Deadlines use the clock of
+// the database, so that the instances agree on them.
+type PgMessageBufferRepository struct {
+	db *sql.DB
+}
+
+// Append implements MessageBufferRepository.
+func (r *PgMessageBufferRepository) Append(chatID int64, message model.TextMessage, debounce time.Duration) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The buffer row goes first: it is locked until commit, so Complete cannot
+	// drop it before the message lands.
+	if _, err := tx.Exec(`
+	INSERT INTO message_buffers(chat_id, due_at)
+	VALUES($1, timezone('utc', now()) + make_interval(secs => $2))
+	ON CONFLICT (chat_id) DO UPDATE SET due_at = EXCLUDED.due_at
+	`, chatID, debounce.Seconds()); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO buffered_messages(chat_id, message) VALUES($1, $2)", chatID, data); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ClaimDue implements MessageBufferRepository.
+func (r *PgMessageBufferRepository) ClaimDue(limit int, lease time.Duration) ([]BufferedBatch, error) {
+	rows, err := r.db.Query(`
+	UPDATE message_buffers
+	SET claimed_until = timezone('utc', now()) + make_interval(secs => $2)
+	WHERE chat_id IN (
+		SELECT chat_id
+		FROM message_buffers
+		WHERE due_at <= timezone('utc', now())
+		AND (claimed_until IS NULL OR claimed_until <= timezone('utc', now()))
+		ORDER BY due_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING chat_id
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	var chatIDs []int64
+	for rows.Next() {
+		var chatID int64
+		if err := rows.Scan(&chatID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		chatIDs = append(chatIDs, chatID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	batches := make([]BufferedBatch, 0, len(chatIDs))
+	for _, chatID := range chatIDs {
+		batch, err := r.listMessages(chatID)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, batch)
+	}
+	return batches, nil
+}
+
+func (r *PgMessageBufferRepository) listMessages(chatID int64) (BufferedBatch, error) {
+	rows, err := r.db.Query("SELECT id, message FROM buffered_messages WHERE chat_id = $1 ORDER BY id", chatID)
+	if err != nil {
+		return BufferedBatch{}, err
+	}
+	defer rows.Close()
+
+	batch := BufferedBatch{ChatID: chatID}
+	for rows.Next() {
+		var data []byte
+		var message model.TextMessage
+		if err := rows.Scan(&batch.LastID, &data); err != nil {
+			return BufferedBatch{}, err
+		}
+		if err := json.Unmarshal(data, &message); err != nil {
+			return BufferedBatch{}, err
+		}
+		batch.Messages = append(batch.Messages, message)
+	}
+
+	return batch, rows.Err()
+}
+
+// Complete implements MessageBufferRepository.
+func (r *PgMessageBufferRepository) Complete(batch BufferedBatch) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locking the buffer row waits for running appends and holds off new ones,
+	// so the check for remaining messages below cannot miss one.
+	var chatID int64
+	err = tx.QueryRow("SELECT chat_id FROM message_buffers WHERE chat_id = $1 FOR UPDATE", batch.ChatID).Scan(&chatID)
+	if err != nil && err.Error() == noRowsError {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(
+		"DELETE FROM buffered_messages WHERE chat_id = $1 AND id <= $2",
+		batch.ChatID, batch.LastID,
+	); err != nil {
+		return err
+	}
+
+	// Messages appended in the meantime keep the buffer, which waits for its deadline again.
+	if _, err := tx.Exec(`
+	DELETE FROM message_buffers
+	WHERE chat_id = $1 AND NOT EXISTS (SELECT 1 FROM buffered_messages WHERE chat_id = $1)
+	`, batch.ChatID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE message_buffers SET claimed_until = NULL WHERE chat_id = $1", batch.ChatID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,67 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage
+
+import (
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+)
+
+type OAuthStateStatus string
+
+const (
+	// OAuthStatePending states wait for the user to come back from the consent screen.
+	OAuthStatePending OAuthStateStatus = "pending"
+	// OAuthStateConsumed states were used by a callback whose code exchange is in progress.
+	OAuthStateConsumed  OAuthStateStatus = "consumed"
+	OAuthStateCompleted OAuthStateStatus = "completed"
+	OAuthStateFailed    OAuthStateStatus = "failed"
+)
+
+// OAuthState is an authorization request started by a user. It outlives
+// restarts, so that the callback and the completion notice survive a deploy.
+type OAuthState struct {
+	State        string
+	UserID       int
+	Provider     model.Provider
+	CodeVerifier string
+	Status       OAuthStateStatus
+	Error        string
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
+
+type OAuthStateRepository interface {
+	Save(state *OAuthState) error
+	GetByState(state string) (OAuthState, error)
+	// Consume moves a pending, unexpired state of the provider to consumed and returns it,
+	// so that every state can be used by a single callback only.
+	Consume(state string, provider model.Provider) (OAuthState, error)
+	// Complete records the outcome of the code exchange.
+	Complete(state string, status OAuthStateStatus, errorMessage string) error
+	// ClaimUnnotified marks up to limit completed or failed states as notified
+	// and returns them, so that every outcome is reported to the user once.
+	ClaimUnnotified(limit int) ([]OAuthState, error)
+	// Unclaim clears the notified mark of the state, so that it is claimed again.
+	Unclaim(state string) error
+	// DeleteStale removes states created before the given time.
+	DeleteStale(before time.Time) error
+}
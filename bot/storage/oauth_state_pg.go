@@ -0,0 +1,136 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+)
+
+func NewOAuthStateRepository(db *sql.DB) OAuthStateRepository {
+	return &PgOAuthStateRepository{db: db}
+}
+
+type PgOAuthStateRepository struct {
+	db *sql.DB
+}
+
+// Save implements OAuthStateRepository.
+func (r *PgOAuthStateRepository) Save(state *OAuthState) error {
+	return r.db.QueryRow(`
+	INSERT INTO oauth_states(state, user_id, provider, code_verifier, status, expires_at)
+	VALUES($1, $2, $3, $4, $5, $6)
+	RETURNING created_at
+	`,
+		state.State, state.UserID, state.Provider, state.CodeVerifier, state.Status, state.ExpiresAt.UTC(),
+	).Scan(&state.CreatedAt)
+}
+
+// GetByState implements OAuthStateRepository.
+func (r *PgOAuthStateRepository) GetByState(state string) (OAuthState, error) {
+	return scanOAuthState(r.db.QueryRow("SELECT "+oauthStateColumns+" FROM oauth_states WHERE state = $1", state))
+}
+
+// Consume implements OAuthStateRepository.
+func (r *PgOAuthStateRepository) Consume(state string, provider model.Provider) (OAuthState, error) {
+	return scanOAuthState(r.db.QueryRow(`
+	UPDATE oauth_states
+	SET status = $3
+	WHERE state = $1 AND provider = $2 AND status = $4 AND expires_at > timezone('utc', now())
+	RETURNING `+oauthStateColumns,
+		state, provider, OAuthStateConsumed, OAuthStatePending,
+	))
+}
+
+// Complete implements OAuthStateRepository.
+func (r *PgOAuthStateRepository) Complete(state string, status OAuthStateStatus, errorMessage string) error {
+	_, err := r.db.Exec(`
+	UPDATE oauth_states
+	SET status = $2, error = $3, completed_at = timezone('utc', now())
+	WHERE state = $1
+	`,
+		state, status, errorMessage,
+	)
+	return err
+}
+
+// ClaimUnnotified implements OAuthStateRepository.
+func (r *PgOAuthStateRepository) ClaimUnnotified(limit int) ([]OAuthState, error) {
+	rows, err := r.db.Query(`
+	UPDATE oauth_states
+	SET notified_at = timezone('utc', now())
+	WHERE state IN (
+		SELECT state
+		FROM oauth_states
+		WHERE status IN ($1, $2) AND notified_at IS NULL
+		ORDER BY completed_at
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING `+oauthStateColumns,
+		OAuthStateCompleted, OAuthStateFailed, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var states []OAuthState
+	for rows.Next() {
+		state, err := scanOAuthState(rows)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+
+	return states, rows.Err()
+}
+
+// Unclaim implements OAuthStateRepository.
+func (r *PgOAuthStateRepository) Unclaim(state string) error {
+	_, err := r.db.Exec("UPDATE oauth_states SET notified_at = NULL WHERE state = $1", state)
+	return err
+}
+
+// DeleteStale implements OAuthStateRepository.
+func (r *PgOAuthStateRepository) DeleteStale(before time.Time) error {
+	_, err := r.db.Exec("DELETE FROM oauth_states WHERE created_at < $1", before.UTC())
+	return err
+}
+
+const oauthStateColumns = "state, user_id, provider, code_verifier, status, error, expires_at, created_at"
+
+func scanOAuthState(row rowScanner) (OAuthState, error) {
+	var state OAuthState
+
+	err := row.Scan(
+		&state.State, &state.UserID, &state.Provider, &state.CodeVerifier,
+		&state.Status, &state.Error, &state.ExpiresAt, &state.CreatedAt,
+	)
+	if err != nil && err.Error() == noRowsError {
+		return OAuthState{}, model.NotFoundError{Message: "authorization request not found or expired"}
+	} else if err != nil {
+		return OAuthState{}, err
+	}
+	return state, nil
+}
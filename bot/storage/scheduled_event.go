@@ -0,0 +1,59 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage
+
+import (
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+)
+
+// ScheduledEvent is an event the bot created in a provider's calendar.
+// It inherits the BatchID of the draft it was created from.
+type ScheduledEvent struct {
+	ID               int
+	UserID           int
+	BatchID          string
+	Provider         model.Provider
+	ProviderEventID  string
+	CalendarID       string
+	SourceChatID     int64
+	SourceMessageIDs []int
+	// CardMessageID is the bot's message that shows the event.
+	CardMessageID int
+	Event         model.Event
+	Link          string
+	CreatedAt     time.Time
+}
+
+type ScheduledEventRepository interface {
+	Save(event *ScheduledEvent) error
+	// ListByCardMessageID returns the events shown in the card, one per provider they were created in.
+	ListByCardMessageID(chatID int64, messageID int) ([]ScheduledEvent, error)
+	ListByBatchID(batchID string) ([]ScheduledEvent, error)
+	// ListByUserID returns every event of the user, oldest first.
+	ListByUserID(userID int) ([]ScheduledEvent, error)
+	// ListByUserIDBetween returns the user's events whose wall-clock times overlap the range.
+	ListByUserIDBetween(userID int, from time.Time, to time.Time) ([]ScheduledEvent, error)
+	Delete(id int) error
+	// DeleteByProvider forgets every event the user has in the provider's calendars
+	// and returns them. The events themselves stay in the calendars.
+	DeleteByProvider(userID int, provider model.Provider) ([]ScheduledEvent, error)
+}
@@ -0,0 +1,171 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+)
+
+func NewScheduledEventRepository(db *sql.DB) ScheduledEventRepository {
+	return &PgScheduledEventRepository{db: db}
+}
+
+type PgScheduledEventRepository struct {
+	db *sql.DB
+}
+
+// Save implements ScheduledEventRepository.
+func (r *PgScheduledEventRepository) Save(event *ScheduledEvent) error {
+	payload, err := json.Marshal(&event.Event)
+	if err != nil {
+		return err
+	}
+
+	sourceMessageIDs, err := json.Marshal(event.SourceMessageIDs)
+	if err != nil {
+		return err
+	}
+
+	return r.db.QueryRow(`
+	INSERT INTO scheduled_events(
+		user_id, batch_id, provider, provider_event_id, calendar_id,
+		source_chat_id, source_message_ids, card_message_id, event, link
+	)
+	VALUES($1, $2, $3, $4, $5, $6, $7, NULLIF($8, 0), $9, $10)
+	ON CONFLICT (user_id, provider, calendar_id, provider_event_id) DO UPDATE
+	SET batch_id = EXCLUDED.batch_id,
+		source_chat_id = EXCLUDED.source_chat_id,
+		source_message_ids = EXCLUDED.source_message_ids,
+		card_message_id = EXCLUDED.card_message_id,
+		event = EXCLUDED.event,
+		link = EXCLUDED.link,
+		updated_at = timezone('utc', now())
+	RETURNING id, created_at
+	`,
+		event.UserID, event.BatchID, event.Provider, event.ProviderEventID, event.CalendarID,
+		event.SourceChatID, sourceMessageIDs, event.CardMessageID, payload, event.Link,
+	).Scan(&event.ID, &event.CreatedAt)
+}
+
+// ListByCardMessageID implements ScheduledEventRepository.
+func (r *PgScheduledEventRepository) ListByCardMessageID(chatID int64, messageID int) ([]ScheduledEvent, error) {
+	return r.list(
+		selectScheduledEventQuery+"WHERE source_chat_id = $1 AND card_message_id = $2 ORDER BY id",
+		chatID, messageID,
+	)
+}
+
+// ListByBatchID implements ScheduledEventRepository.
+func (r *PgScheduledEventRepository) ListByBatchID(batchID string) ([]ScheduledEvent, error) {
+	return r.list(selectScheduledEventQuery+"WHERE batch_id = $1 ORDER BY id", batchID)
+}
+
+// ListByUserID implements ScheduledEventRepository.
+func (r *PgScheduledEventRepository) ListByUserID(userID int) ([]ScheduledEvent, error) {
+	return r.list(selectScheduledEventQuery+"WHERE user_id = $1 ORDER BY id", userID)
+}
+
+// ListByUserIDBetween implements ScheduledEventRepository.
+func (r *PgScheduledEventRepository) ListByUserIDBetween(userID int, from time.Time, to time.Time) ([]ScheduledEvent, error) {
+	// The times are stored as they are shown to the user, without a zone.
+	// All-day events end with their last day, so they last until the next midnight.
+	return r.list(
+		selectScheduledEventQuery+`WHERE user_id = $1
+		AND (event->>'end')::timestamp
+			+ CASE WHEN (event->>'allDay')::boolean THEN interval '1 day' ELSE interval '0' END
+			>= $2::timestamp
+		AND (event->>'start')::timestamp <= $3::timestamp
+		ORDER BY id`,
+		userID, from.Format(time.DateTime), to.Format(time.DateTime),
+	)
+}
+
+// Delete implements ScheduledEventRepository.
+func (r *PgScheduledEventRepository) Delete(id int) error {
+	_, err := r.db.Exec("DELETE FROM scheduled_events WHERE id = $1", id)
+	return err
+}
+
+// DeleteByProvider implements ScheduledEventRepository.
+func (r *PgScheduledEventRepository) DeleteByProvider(userID int, provider model.Provider) ([]ScheduledEvent, error) {
+	return r.list(
+		"DELETE FROM scheduled_events WHERE user_id = $1 AND provider = $2 RETURNING "+scheduledEventColumns,
+		userID, provider,
+	)
+}
+
+func (r *PgScheduledEventRepository) list(query string, args ...any) ([]ScheduledEvent, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []ScheduledEvent
+	for rows.Next() {
+		event, err := scanScheduledEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+const scheduledEventColumns = `id, user_id, batch_id, provider, provider_event_id, calendar_id,
+		source_chat_id, source_message_ids, COALESCE(card_message_id, 0), event, link, created_at`
+
+const selectScheduledEventQuery = `
+	SELECT ` + scheduledEventColumns + `
+	FROM scheduled_events
+	`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanScheduledEvent(row rowScanner) (ScheduledEvent, error) {
+	var event ScheduledEvent
+	var sourceMessageIDs []byte
+	var payload []byte
+
+	err := row.Scan(
+		&event.ID, &event.UserID, &event.BatchID, &event.Provider, &event.ProviderEventID, &event.CalendarID,
+		&event.SourceChatID, &sourceMessageIDs, &event.CardMessageID, &payload, &event.Link, &event.CreatedAt,
+	)
+	if err != nil && err.Error() == noRowsError {
+		return ScheduledEvent{}, model.NotFoundError{Message: "event not found"}
+	} else if err != nil {
+		return ScheduledEvent{}, err
+	}
+
+	if err := json.Unmarshal(sourceMessageIDs, &event.SourceMessageIDs); err != nil {
+		return ScheduledEvent{}, err
+	}
+	if err := json.Unmarshal(payload, &event.Event); err != nil {
+		return ScheduledEvent{}, err
+	}
+	return event, nil
+}
@@ -0,0 +1,111 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/storage"
+)
+
+func TestScheduledEventRepository_ListByUserIDBetween(t *testing.T) {
+	db := openTestDB(t)
+
+	user := storage.User{TelegramID: -time.Now().UnixNano()}
+	if err := storage.NewUserRepository(db).Save(&user); err != nil {
+		t.Fatalf("Save() user error = %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM scheduled_events WHERE user_id = $1", user.ID)
+		db.Exec("DELETE FROM users WHERE id = $1", user.ID)
+	})
+
+	events := storage.NewScheduledEventRepository(db)
+	for i, event := range []model.Event{
+		{
+			Title:  "Festival",
+			Start:  time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC),
+			End:    time.Date(2025, 3, 5, 0, 0, 0, 0, time.UTC),
+			AllDay: true,
+		},
+		{
+			Title: "Dinner",
+			Start: time.Date(2025, 3, 4, 19, 0, 0, 0, time.UTC),
+			End:   time.Date(2025, 3, 4, 21, 0, 0, 0, time.UTC),
+		},
+	} {
+		err := events.Save(&storage.ScheduledEvent{
+			UserID:          user.ID,
+			Provider:        model.ProviderGoogle,
+			ProviderEventID: event.Title,
+			CalendarID:      "primary",
+			SourceChatID:    user.TelegramID,
+			CardMessageID:   i + 1,
+			Event:           event,
+		})
+		if err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		from time.Time
+		to   time.Time
+		want []string
+	}{
+		{
+			name: "last day of an all-day event",
+			from: time.Date(2025, 3, 5, 10, 0, 0, 0, time.UTC),
+			to:   time.Date(2025, 3, 5, 11, 0, 0, 0, time.UTC),
+			want: []string{"Festival"},
+		},
+		{
+			name: "after an all-day event",
+			from: time.Date(2025, 3, 6, 0, 0, 0, 0, time.UTC),
+			to:   time.Date(2025, 3, 6, 1, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "evening after a timed event",
+			from: time.Date(2025, 3, 4, 22, 0, 0, 0, time.UTC),
+			to:   time.Date(2025, 3, 4, 23, 0, 0, 0, time.UTC),
+			want: []string{"Festival"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listed, err := events.ListByUserIDBetween(user.ID, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("ListByUserIDBetween() error = %v", err)
+			}
+
+			var titles []string
+			for _, event := range listed {
+				titles = append(titles, event.Event.Title)
+			}
+			if len(titles) != len(tt.want) || (len(titles) > 0 && titles[0] != tt.want[0]) {
+				t.Errorf("ListByUserIDBetween() = %v, want %v", titles, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var errMalformedToken = errors.New("malformed encrypted token")
+
+// encryptedTokenPrefix marks values written by TokenCipher.
+// Values without it are legacy plaintext tokens.
+const encryptedTokenPrefix = "enc:v1:"
+
+// NewTokenCipher creates a cipher from the configured key-encryption keys.
+// Without keys, tokens are stored as plaintext.
+func NewTokenCipher(config EncryptionConfig) (*TokenCipher, error) {
+	keys := make(map[string]cipher.AEAD, len(config.Keys))
+	for id, encoded := range config.Keys {
+		if strings.Contains(id, ":") {
+			return nil, fmt.Errorf("encryption key ID %q must not contain ':'", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q is not valid base64: %w", id, err)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q: %w", id, err)
+		}
+		keys[id] = aead
+	}
+
+	if len(keys) > 0 {
+		if _, ok := keys[config.ActiveKeyID]; !ok {
+			return nil, fmt.Errorf("active encryption key %q is not configured", config.ActiveKeyID)
+		}
+	}
+
+	return &TokenCipher{activeKeyID: config.ActiveKeyID, keys: keys}, nil
+}
+
+// TokenCipher encrypts OAuth tokens with envelope encryption: every value is sealed
+// with its own data key, which is in turn sealed with a key-encryption key. The ID of
+// the key-encryption key is kept with the value, so that keys can be rotated.
+type TokenCipher struct {
+	activeKeyID string
+	keys        map[string]cipher.AEAD
+}
+
+// Enabled tells whether tokens are encrypted.
+func (c *TokenCipher) Enabled() bool {
+	return len(c.keys) > 0
+}
+
+// Encrypt seals the value with the active key as
+// "enc:v1:<key ID>:<sealed data key>:<sealed value>".
+func (c *TokenCipher) Encrypt(plaintext string) (string, error) {
+	if !c.Enabled() || plaintext == "" {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	sealedKey, err := seal(c.keys[c.activeKeyID], dataKey, []byte(c.activeKeyID))
+	if err != nil {
+		return "", err
+	}
+	sealedValue, err := seal(dataAEAD, []byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+
+	return encryptedTokenPrefix + c.activeKeyID + ":" +
+		base64.RawStdEncoding.EncodeToString(sealedKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(sealedValue), nil
+}
+
+// Decrypt opens a value sealed with any of the configured keys.
+// Legacy plaintext values are returned as they are.
+func (c *TokenCipher) Decrypt(value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedTokenPrefix) {
+		return value, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, encryptedTokenPrefix), ":")
+	if len(parts) != 3 {
+		return "", errMalformedToken
+	}
+
+	keyID := parts[0]
+	keyAEAD, ok := c.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("encryption key %q is not configured", keyID)
+	}
+
+	sealedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted token: %w", err)
+	}
+	sealedValue, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted token: %w", err)
+	}
+
+	dataKey, err := open(keyAEAD, sealedKey, []byte(keyID))
+	if err != nil {
+		return "", err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataAEAD, sealedValue, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// NeedsReencryption tells whether the value is plaintext or sealed with a retired key.
+func (c *TokenCipher) NeedsReencryption(value string) bool {
+	if !c.Enabled() || value == "" {
+		return false
+	}
+	return !strings.HasPrefix(value, encryptedTokenPrefix+c.activeKeyID+":")
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes long, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plaintext []byte, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errMalformedToken
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
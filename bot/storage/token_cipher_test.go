@@ -0,0 +1,121 @@
+/*
+ * Created on Sat Oct 17 2026
+ *
+ *  Copyright (c) 2025 Ivan Gagarkin
+ * SPDX-License-Identifier: EPL-2.0
+ *
+ * Licensed under the Eclipse Public License - v 2.0 (the "License").
+ * You may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.eclipse.org/legal/epl-2.0/
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/ivgag/schedulr/storage"
+)
+
+var (
+	oldKey = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	newKey = base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210"))
+)
+
+func TestTokenCipher(t *testing.T) {
+	oldCipher := newCipher(t, "k1", map[string]string{"k1": oldKey})
+	rotatedCipher := newCipher(t, "k2", map[string]string{"k1": oldKey, "k2": newKey})
+	otherCipher := newCipher(t, "k1", map[string]string{"k1": newKey})
+
+	sealed, err := oldCipher.Encrypt("1//refresh-token")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if !strings.HasPrefix(sealed, "enc:v1:k1:") || strings.Contains(sealed, "refresh-token") {
+		t.Errorf("Encrypt() = %q, want a value sealed with k1", sealed)
+	}
+
+	tests := []struct {
+		name          string
+		cipher        *storage.TokenCipher
+		value         string
+		want          string
+		wantErr       bool
+		wantReencrypt bool
+	}{
+		{name: "Same key", cipher: oldCipher, value: sealed, want: "1//refresh-token"},
+		{name: "Retired key", cipher: rotatedCipher, value: sealed, want: "1//refresh-token", wantReencrypt: true},
+		{name: "Legacy plaintext", cipher: rotatedCipher, value: "ya29.legacy", want: "ya29.legacy", wantReencrypt: true},
+		{name: "Wrong key material", cipher: otherCipher, value: sealed, wantErr: true},
+		{name: "Tampered value", cipher: oldCipher, value: sealed[:len(sealed)-2] + "AA", wantErr: true},
+		{name: "Unknown key", cipher: newCipher(t, "k3", map[string]string{"k3": newKey}), value: sealed, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cipher.Decrypt(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Decrypt() = %q, want error", got)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("Decrypt() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Decrypt() = %q, want %q", got, tt.want)
+			}
+			if needs := tt.cipher.NeedsReencryption(tt.value); needs != tt.wantReencrypt {
+				t.Errorf("NeedsReencryption() = %v, want %v", needs, tt.wantReencrypt)
+			}
+		})
+	}
+}
+
+func TestNewTokenCipher(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  storage.EncryptionConfig
+		wantErr bool
+		enabled bool
+	}{
+		{name: "No keys", config: storage.EncryptionConfig{}},
+		{name: "Active key", config: storage.EncryptionConfig{ActiveKeyID: "k1", Keys: map[string]string{"k1": oldKey}}, enabled: true},
+		{name: "Missing active key", config: storage.EncryptionConfig{ActiveKeyID: "k2", Keys: map[string]string{"k1": oldKey}}, wantErr: true},
+		{name: "Short key", config: storage.EncryptionConfig{ActiveKeyID: "k1", Keys: map[string]string{"k1": "c2hvcnQ="}}, wantErr: true},
+		{name: "Invalid base64", config: storage.EncryptionConfig{ActiveKeyID: "k1", Keys: map[string]string{"k1": "!"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenCipher, err := storage.NewTokenCipher(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTokenCipher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && tokenCipher.Enabled() != tt.enabled {
+				t.Errorf("Enabled() = %v, want %v", tokenCipher.Enabled(), tt.enabled)
+			}
+		})
+	}
+}
+
+func newCipher(t *testing.T, activeKeyID string, keys map[string]string) *storage.TokenCipher {
+	t.Helper()
+
+	tokenCipher, err := storage.NewTokenCipher(storage.EncryptionConfig{ActiveKeyID: activeKeyID, Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokenCipher
+}
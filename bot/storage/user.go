@@ -19,14 +19,29 @@
 
 package storage
 
+import "github.com/ivgag/schedulr/model"
+
 type User struct {
 	ID         int
 	TelegramID int64
 	Username   string
+	// TimeZone is the IANA name of the user's time zone, empty if not set.
+	TimeZone string
+	// DefaultProvider is the provider events are created in, empty for the first linked one.
+	DefaultProvider model.Provider
+	// AllProviders creates events in every linked provider, not only the default one.
+	AllProviders bool
+	// FeedToken is the secret in the URL of the user's calendar feed, empty if it is turned off.
+	FeedToken string
 }
 
 type UserRepository interface {
 	GetByID(id int) (User, error)
 	GetByTelegramID(telegramID int64) (User, error)
+	GetByFeedToken(token string) (User, error)
 	Save(user *User) error
+	UpdateTimeZone(id int, timeZone string) error
+	UpdateCalendarProviders(id int, defaultProvider model.Provider, allProviders bool) error
+	// UpdateFeedToken replaces the feed token, an empty one turns the feed off.
+	UpdateFeedToken(id int, token string) error
 }
@@ -25,6 +25,9 @@ import (
 	"github.com/ivgag/schedulr/model"
 )
 
+const selectUserQuery = `SELECT id, telegram_id, username, timezone, default_provider, all_providers, feed_token
+	FROM users `
+
 type PgUserRepository struct {
 	db *sql.DB
 }
@@ -34,32 +37,33 @@ func NewUserRepository(db *sql.DB) UserRepository {
 }
 
 func (r *PgUserRepository) GetByID(id int) (User, error) {
-	var user User
-	query := "SELECT id, telegram_id, username FROM users WHERE id = $1"
-	err := r.db.QueryRow(query, id).Scan(&user.ID, &user.TelegramID, &user.Username)
-
-	if err != nil && err.Error() == noRowsError {
-		return User{}, model.NotFoundError{Message: "user not found"}
-	} else if err != nil {
-		return User{}, err
-	} else {
-		return user, nil
-	}
+	return r.get(selectUserQuery+"WHERE id = $1", id)
 }
 
 // GetByTelegramID implements UserRepository.
 func (r *PgUserRepository) GetByTelegramID(telegramID int64) (User, error) {
+	return r.get(selectUserQuery+"WHERE telegram_id = $1", telegramID)
+}
+
+// GetByFeedToken implements UserRepository.
+func (r *PgUserRepository) GetByFeedToken(token string) (User, error) {
+	return r.get(selectUserQuery+"WHERE feed_token = $1", token)
+}
+
+func (r *PgUserRepository) get(query string, args ...any) (User, error) {
 	var user User
-	query := "SELECT id, telegram_id, username FROM users WHERE telegram_id = $1"
-	err := r.db.QueryRow(query, telegramID).Scan(&user.ID, &user.TelegramID, &user.Username)
+	var feedToken sql.NullString
+	err := r.db.QueryRow(query, args...).Scan(
+		&user.ID, &user.TelegramID, &user.Username, &user.TimeZone, &user.DefaultProvider, &user.AllProviders, &feedToken,
+	)
 
 	if err != nil && err.Error() == noRowsError {
 		return User{}, model.NotFoundError{Message: "user not found"}
 	} else if err != nil {
 		return User{}, err
-	} else {
-		return user, nil
 	}
+	user.FeedToken = feedToken.String
+	return user, nil
 }
 
 func (r *PgUserRepository) Save(user *User) error {
@@ -72,3 +76,24 @@ func (r *PgUserRepository) Save(user *User) error {
 	`
 	return r.db.QueryRow(query, user.TelegramID, user.Username).Scan(&user.ID)
 }
+
+// UpdateTimeZone implements UserRepository.
+func (r *PgUserRepository) UpdateTimeZone(id int, timeZone string) error {
+	_, err := r.db.Exec("UPDATE users SET timezone = $2 WHERE id = $1", id, timeZone)
+	return err
+}
+
+// UpdateCalendarProviders implements UserRepository.
+func (r *PgUserRepository) UpdateCalendarProviders(id int, defaultProvider model.Provider, allProviders bool) error {
+	_, err := r.db.Exec(
+		"UPDATE users SET default_provider = $2, all_providers = $3 WHERE id = $1",
+		id, defaultProvider, allProviders,
+	)
+	return err
+}
+
+// UpdateFeedToken implements UserRepository.
+func (r *PgUserRepository) UpdateFeedToken(id int, token string) error {
+	_, err := r.db.Exec("UPDATE users SET feed_token = NULLIF($2, '') WHERE id = $1", id, token)
+	return err
+}
@@ -0,0 +1,346 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/storage"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	unlinkCommand        = "/unlink"
+	unlinkCallbackPrefix = "unlink:"
+
+	providersCommand        = "/providers"
+	providersCallbackPrefix = "providers:"
+	// providersAll stands for every linked provider in callback data.
+	providersAll = "all"
+)
+
+var providerNames = map[model.Provider]string{
+	model.ProviderGoogle:    "Google",
+	model.ProviderMicrosoft: "Microsoft",
+	model.ProviderCalDAV:    "CalDAV",
+	model.ProviderICS:       "ICS file",
+}
+
+// linkCommands are the commands that link an account of each provider.
+var linkCommands = map[model.Provider]string{
+	model.ProviderGoogle:    "/linkgoogle",
+	model.ProviderMicrosoft: "/linkmicrosoft",
+	model.ProviderCalDAV:    linkCalDAVCommand,
+}
+
+// linkCommandsText lists the commands linking each of the providers.
+func linkCommandsText(providers []model.Provider) string {
+	lines := make([]string, len(providers))
+	for i, provider := range providers {
+		lines[i] = "Link your " + providerNames[provider] + " Calendar: " + linkCommands[provider]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sendRelinkPrompt tells the user that the provider no longer accepts their grant
+// and offers a fresh link to authorize again. It returns false if err is not a RelinkRequiredError.
+func (b *Bot) sendRelinkPrompt(ctx context.Context, chatID int64, err error) bool {
+	var relinkErr model.RelinkRequiredError
+	if !errors.As(err, &relinkErr) {
+		return false
+	}
+
+	link, linkErr := b.userService.GetOAuth2Url(chatID, relinkErr.Provider)
+	if linkErr != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Err(linkErr).
+			Msg("Failed to create a link to relink the account")
+	}
+
+	params, _ := RelinkPrompt(chatID, err, link)
+	b.chatBot.SendMessage(ctx, params)
+	return true
+}
+
+// RelinkPrompt builds the message asking the user to link the account again, with
+// a button opening link. Without a link it names the command to send instead.
+// It returns false if err is not a RelinkRequiredError.
+func RelinkPrompt(chatID int64, err error, link string) (*bot.SendMessageParams, bool) {
+	var relinkErr model.RelinkRequiredError
+	if !errors.As(err, &relinkErr) {
+		return nil, false
+	}
+
+	name := providerNames[relinkErr.Provider]
+	text := "Schedulr has lost access to your " + name + " calendar, " +
+		"probably because the access was revoked. Link the account again to keep creating events."
+	if link == "" {
+		return &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   text + "\nSend " + linkCommands[relinkErr.Provider] + " to link it.",
+		}, true
+	}
+
+	return &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   text,
+		ReplyMarkup: &models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{
+				{{Text: "Relink " + name + " Calendar", URL: link}},
+			},
+		},
+	}, true
+}
+
+// statusHandler shows the health of each account the user can link.
+func (b *Bot) statusHandler(ctx context.Context, botAPI *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	accounts, err := b.userService.LinkedAccounts(chatID)
+	if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Err(err).
+			Msg("Failed to load linked accounts")
+		b.sendMessage(ctx, chatID, "Failed to load your accounts. Try later.", "")
+		return
+	}
+
+	b.sendMessage(ctx, chatID, FormatAccountsStatus(accounts, b.userService.Providers()), "")
+}
+
+// FormatAccountsStatus lists the state of the user's account for each of the providers.
+func FormatAccountsStatus(accounts []storage.LinkedAccount, providers []model.Provider) string {
+	linked := make(map[model.Provider]storage.LinkedAccount, len(accounts))
+	for _, account := range accounts {
+		linked[account.Provider] = account
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Linked accounts:\n")
+	for _, provider := range providers {
+		account, ok := linked[provider]
+		sb.WriteString(providerNames[provider] + ": ")
+		switch {
+		case !ok:
+			sb.WriteString("not linked, link it with " + linkCommands[provider])
+		case account.Status == storage.LinkedAccountBroken:
+			sb.WriteString("access revoked since " + account.StatusChangedAt.Format("2006-01-02") +
+				", relink it with " + linkCommands[provider])
+		default:
+			sb.WriteString("linked")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// unlinkHandler offers to pick the linked account to disconnect.
+func (b *Bot) unlinkHandler(ctx context.Context, botAPI *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	accounts, err := b.userService.LinkedAccounts(chatID)
+	if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Err(err).
+			Msg("Failed to load linked accounts")
+		b.sendMessage(ctx, chatID, "Failed to load your accounts. Try later.", "")
+		return
+	} else if len(accounts) == 0 {
+		b.sendMessage(ctx, chatID, "You have no linked accounts.", "")
+		return
+	}
+
+	var buttons [][]models.InlineKeyboardButton
+	for _, account := range accounts {
+		buttons = append(buttons, []models.InlineKeyboardButton{{
+			Text:         providerNames[account.Provider],
+			CallbackData: unlinkCallbackPrefix + string(account.Provider),
+		}})
+	}
+
+	b.chatBot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      chatID,
+		Text:        "Which account do you want to unlink?",
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: buttons},
+	})
+}
+
+// unlinkCallbackHandler disconnects the picked account and updates the cards
+// of the drafts and events that belonged to it.
+func (b *Bot) unlinkCallbackHandler(ctx context.Context, botAPI *bot.Bot, update *models.Update) {
+	query := update.CallbackQuery
+	if query.Message.Message == nil {
+		b.answerCallback(ctx, query.ID, "", false)
+		return
+	}
+
+	chatID := query.Message.Message.Chat.ID
+	messageID := query.Message.Message.ID
+	provider := model.Provider(strings.TrimPrefix(query.Data, unlinkCallbackPrefix))
+
+	result, err := b.eventService.UnlinkAccount(chatID, provider)
+	var notFound model.NotFoundError
+	if errors.As(err, &notFound) {
+		b.answerCallback(ctx, query.ID, "This account is not linked.", true)
+		b.editMessage(ctx, chatID, messageID, "This account is not linked.", nil)
+		return
+	} else if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Str("provider", string(provider)).
+			Err(err).
+			Msg("Failed to unlink account")
+		b.answerCallback(ctx, query.ID, "Failed to unlink the account. Try later.", true)
+		return
+	}
+
+	for _, draft := range result.DiscardedDrafts {
+		if draft.MessageID != 0 {
+			b.editMessage(ctx, chatID, draft.MessageID, "_Discarded:_ "+draft.Event.Title, nil)
+		}
+	}
+	for _, event := range result.ForgottenEvents {
+		if event.CardMessageID != 0 {
+			b.editReplyMarkup(ctx, event.SourceChatID, event.CardMessageID, &models.InlineKeyboardMarkup{
+				InlineKeyboard: [][]models.InlineKeyboardButton{},
+			})
+		}
+	}
+
+	text := providerNames[provider] + " account unlinked. Events already created stay in your calendar."
+	if result.AppPassword {
+		text += "\nDelete the app password you gave Schedulr in your " +
+			providerNames[provider] + " account settings."
+	} else if !result.Revoked {
+		text += "\nSchedulr could not revoke its access, remove it in your " +
+			providerNames[provider] + " account settings."
+	}
+
+	b.answerCallback(ctx, query.ID, "Account unlinked.", false)
+	b.editMessage(ctx, chatID, messageID, text, nil)
+}
+
+// providersHandler lets users pick the provider new events go to, or with several linked
+// accounts create them in all of them. Events can also be sent as files only.
+func (b *Bot) providersHandler(ctx context.Context, botAPI *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	user, err := b.userService.GetUserByTelegramID(chatID)
+	if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Err(err).
+			Msg("Failed to load user")
+		b.sendMessage(ctx, chatID, "Failed to load your accounts. Try later.", "")
+		return
+	}
+
+	accounts, err := b.userService.LinkedAccounts(chatID)
+	if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Err(err).
+			Msg("Failed to load linked accounts")
+		b.sendMessage(ctx, chatID, "Failed to load your accounts. Try later.", "")
+		return
+	}
+
+	var buttons [][]models.InlineKeyboardButton
+	for _, account := range accounts {
+		if account.Status == storage.LinkedAccountActive {
+			buttons = append(buttons, []models.InlineKeyboardButton{{
+				Text:         providerNames[account.Provider] + " only",
+				CallbackData: providersCallbackPrefix + string(account.Provider),
+			}})
+		}
+	}
+	if len(buttons) == 0 {
+		b.sendMessage(ctx, chatID, "New events are sent to you as .ics files until you link a calendar.\n"+
+			linkCommandsText(b.userService.Providers()), "")
+		return
+	} else if len(buttons) > 1 {
+		buttons = append(buttons, []models.InlineKeyboardButton{{
+			Text:         "All linked calendars",
+			CallbackData: providersCallbackPrefix + providersAll,
+		}})
+	}
+	buttons = append(buttons, []models.InlineKeyboardButton{{
+		Text:         providerNames[model.ProviderICS] + " only",
+		CallbackData: providersCallbackPrefix + string(model.ProviderICS),
+	}})
+
+	provider, err := b.userService.CalendarProvider(user.ID)
+	if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Err(err).
+			Msg("Failed to load calendar provider")
+		b.sendMessage(ctx, chatID, "Failed to load your accounts. Try later.", "")
+		return
+	}
+
+	b.chatBot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      chatID,
+		Text:        providersText(provider, user.AllProviders) + "\nWhere should new events go?",
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: buttons},
+	})
+}
+
+// providersCallbackHandler stores the provider picked in /providers.
+func (b *Bot) providersCallbackHandler(ctx context.Context, botAPI *bot.Bot, update *models.Update) {
+	query := update.CallbackQuery
+	if query.Message.Message == nil {
+		b.answerCallback(ctx, query.ID, "", false)
+		return
+	}
+
+	chatID := query.Message.Message.Chat.ID
+	messageID := query.Message.Message.ID
+	choice := strings.TrimPrefix(query.Data, providersCallbackPrefix)
+
+	user, err := b.userService.GetUserByTelegramID(chatID)
+	if err != nil {
+		b.answerCallback(ctx, query.ID, "Failed to save the choice. Try later.", true)
+		return
+	}
+
+	provider, all := model.Provider(choice), choice == providersAll
+	if all {
+		// The default provider still decides the calendar shown on drafts.
+		if provider, err = b.userService.CalendarProvider(user.ID); err != nil {
+			b.answerCallback(ctx, query.ID, "Failed to save the choice. Try later.", true)
+			return
+		}
+	}
+
+	if err := b.userService.SetCalendarProviders(chatID, provider, all); err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Str("choice", choice).
+			Err(err).
+			Msg("Failed to save calendar providers")
+		b.answerCallback(ctx, query.ID, "Failed to save the choice. Try later.", true)
+		return
+	}
+
+	b.answerCallback(ctx, query.ID, "Saved.", false)
+	b.editMessage(ctx, chatID, messageID, providersText(provider, all), nil)
+}
+
+func providersText(provider model.Provider, all bool) string {
+	switch {
+	case all && provider == model.ProviderICS:
+		return "New events go to all linked calendars and are sent to you as .ics files."
+	case all:
+		return "New events go to all linked calendars."
+	case provider == model.ProviderICS:
+		return "New events are sent to you as .ics files only."
+	}
+	return "New events go to " + providerNames[provider] + " Calendar only."
+}
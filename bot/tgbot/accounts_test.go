@@ -0,0 +1,119 @@
+package tgbot_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/storage"
+	"github.com/ivgag/schedulr/tgbot"
+)
+
+func TestRelinkPrompt(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		link       string
+		wantOK     bool
+		wantText   string
+		wantButton string
+	}{
+		{
+			name:       "with a link",
+			err:        model.RelinkRequiredError{Provider: model.ProviderGoogle, Reason: "invalid_grant"},
+			link:       "https://accounts.example/consent",
+			wantOK:     true,
+			wantText:   "lost access to your Google calendar",
+			wantButton: "Relink Google Calendar",
+		},
+		{
+			name:     "without a link",
+			err:      model.RelinkRequiredError{Provider: model.ProviderCalDAV},
+			wantOK:   true,
+			wantText: "Send /linkcaldav to link it.",
+		},
+		{
+			name:       "wrapped",
+			err:        errors.Join(errors.New("create event"), model.RelinkRequiredError{Provider: model.ProviderMicrosoft}),
+			link:       "https://login.example/consent",
+			wantOK:     true,
+			wantText:   "lost access to your Microsoft calendar",
+			wantButton: "Relink Microsoft Calendar",
+		},
+		{
+			name: "other error",
+			err:  errors.New("calendar unavailable"),
+			link: "https://accounts.example/consent",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, ok := tgbot.RelinkPrompt(42, tt.err, tt.link)
+			if ok != tt.wantOK {
+				t.Fatalf("RelinkPrompt() ok = %v, want %v", ok, tt.wantOK)
+			} else if !ok {
+				return
+			}
+
+			if params.ChatID != int64(42) || !strings.Contains(params.Text, tt.wantText) {
+				t.Errorf("RelinkPrompt() = %v %q, want chat 42 and %q", params.ChatID, params.Text, tt.wantText)
+			}
+
+			keyboard, _ := params.ReplyMarkup.(*models.InlineKeyboardMarkup)
+			if tt.wantButton == "" {
+				if keyboard != nil {
+					t.Errorf("keyboard = %+v, want none", keyboard)
+				}
+				return
+			}
+			if keyboard == nil || len(keyboard.InlineKeyboard) != 1 || len(keyboard.InlineKeyboard[0]) != 1 {
+				t.Fatalf("keyboard = %+v, want a single button", params.ReplyMarkup)
+			}
+			if button := keyboard.InlineKeyboard[0][0]; button.Text != tt.wantButton || button.URL != tt.link {
+				t.Errorf("button = %q -> %q, want %q -> %q", button.Text, button.URL, tt.wantButton, tt.link)
+			}
+		})
+	}
+}
+
+func TestFormatAccountsStatus(t *testing.T) {
+	providers := []model.Provider{model.ProviderGoogle, model.ProviderMicrosoft, model.ProviderCalDAV}
+	brokenSince := time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		accounts []storage.LinkedAccount
+		want     string
+	}{
+		{
+			name: "nothing linked",
+			want: "Linked accounts:\n" +
+				"Google: not linked, link it with /linkgoogle\n" +
+				"Microsoft: not linked, link it with /linkmicrosoft\n" +
+				"CalDAV: not linked, link it with /linkcaldav\n",
+		},
+		{
+			name: "linked and broken",
+			accounts: []storage.LinkedAccount{
+				{Provider: model.ProviderGoogle, Status: storage.LinkedAccountActive},
+				{Provider: model.ProviderMicrosoft, Status: storage.LinkedAccountBroken, StatusChangedAt: brokenSince},
+			},
+			want: "Linked accounts:\n" +
+				"Google: linked\n" +
+				"Microsoft: access revoked since 2026-10-15, relink it with /linkmicrosoft\n" +
+				"CalDAV: not linked, link it with /linkcaldav\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tgbot.FormatAccountsStatus(tt.accounts, providers); got != tt.want {
+				t.Errorf("FormatAccountsStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/go-telegram/bot"
@@ -16,46 +16,69 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	// bufferPollInterval is how often the buffer is checked for chats that have gone quiet.
+	bufferPollInterval = 500 * time.Millisecond
+	// bufferWorkers bounds how many chats are processed at once.
+	bufferWorkers = 10
+)
+
 // TelegramBotConfig remains unchanged.
 type TelegramBotConfig struct {
-	Token string `mapstructure:"token"`
-	URL   string `mapstructure:"url"`
+	Token   string        `mapstructure:"token"`
+	URL     string        `mapstructure:"url"`
+	Webhook WebhookConfig `mapstructure:"webhook"`
 }
 
-// Bot wraps bot.Bot with service dependencies and buffering fields.
+// Bot wraps bot.Bot with its service dependencies.
 type Bot struct {
-	ctx             context.Context
-	cfg             *TelegramBotConfig
-	chatBot         *bot.Bot
-	userService     *service.UserService
-	eventService    *service.EventService
-	bufferedUpdates map[int64][]*models.Update // Keyed by chat ID.
-	bufferTimers    map[int64]*time.Timer      // Timers per chat.
-	bufferMutex     sync.Mutex                 // Mutex for bufferedUpdates and bufferTimers.
+	ctx           context.Context
+	cfg           *TelegramBotConfig
+	chatBot       *bot.Bot
+	userService   *service.UserService
+	eventService  *service.EventService
+	oauthStates   *service.OAuthStateService
+	feeds         *service.FeedService
+	messageBuffer *service.MessageBufferService
+	calDAVLinks   *service.CalDAVLinkService
+	ready         chan struct{} // Closed once the handlers are registered.
 }
 
-// NewBot initializes the bot and its buffers.
+// NewBot initializes the bot.
 func NewBot(
 	ctx context.Context,
 	cfg *TelegramBotConfig,
 	userService *service.UserService,
 	eventService *service.EventService,
+	oauthStates *service.OAuthStateService,
+	feeds *service.FeedService,
+	messageBuffer *service.MessageBufferService,
+	calDAVLinks *service.CalDAVLinkService,
 ) *Bot {
 	return &Bot{
-		ctx:             ctx,
-		cfg:             cfg,
-		userService:     userService,
-		eventService:    eventService,
-		bufferedUpdates: make(map[int64][]*models.Update),
-		bufferTimers:    make(map[int64]*time.Timer),
+		ctx:           ctx,
+		cfg:           cfg,
+		userService:   userService,
+		eventService:  eventService,
+		oauthStates:   oauthStates,
+		feeds:         feeds,
+		messageBuffer: messageBuffer,
+		calDAVLinks:   calDAVLinks,
+		ready:         make(chan struct{}),
 	}
 }
 
-// Start and other methods remain unchanged.
+// Start receives updates until the context of the bot is done: through WebhookHandler
+// in webhook mode, or by long polling otherwise.
 func (b *Bot) Start() error {
+	if b.WebhookEnabled() {
+		if err := validateWebhookConfig(b.cfg.Webhook); err != nil {
+			return err
+		}
+	}
+
+	// No debug mode: it logs every update in full, including the passwords sent to /linkcaldav.
 	opts := []bot.Option{
-		bot.WithDebug(),
-		bot.WithDebugHandler(debugHandler),
 		bot.WithErrorsHandler(errorsHandler),
 		bot.WithDefaultHandler(b.defaultHandler),
 	}
@@ -67,14 +90,57 @@ func (b *Bot) Start() error {
 	b.chatBot = chatBot
 
 	b.chatBot.RegisterHandler(bot.HandlerTypeMessageText, "/start", bot.MatchTypeExact, b.startHandler)
-	b.chatBot.RegisterHandler(bot.HandlerTypeMessageText, "/linkgoogle", bot.MatchTypeExact, b.linkGoogleAccountHandler)
+	for _, provider := range b.userService.Providers() {
+		if provider == model.ProviderCalDAV {
+			b.chatBot.RegisterHandler(bot.HandlerTypeMessageText, linkCalDAVCommand, bot.MatchTypeExact, b.linkCalDAVHandler)
+			continue
+		}
+		b.chatBot.RegisterHandler(bot.HandlerTypeMessageText, linkCommands[provider], bot.MatchTypeExact, b.linkAccountHandler(provider))
+	}
+	b.chatBot.RegisterHandler(bot.HandlerTypeMessageText, cancelCommand, bot.MatchTypeExact, b.cancelHandler)
+	b.chatBot.RegisterHandler(bot.HandlerTypeMessageText, "/status", bot.MatchTypeExact, b.statusHandler)
+	b.chatBot.RegisterHandler(bot.HandlerTypeMessageText, unlinkCommand, bot.MatchTypeExact, b.unlinkHandler)
+	b.chatBot.RegisterHandler(bot.HandlerTypeMessageText, "/undo", bot.MatchTypeExact, b.undoHandler)
+	b.chatBot.RegisterHandler(bot.HandlerTypeMessageText, calendarsCommand, bot.MatchTypeExact, b.calendarsHandler)
+	b.chatBot.RegisterHandler(bot.HandlerTypeMessageText, providersCommand, bot.MatchTypeExact, b.providersHandler)
+	b.chatBot.RegisterHandler(bot.HandlerTypeMessageText, feedCommand, bot.MatchTypeExact, b.feedHandler)
+	b.chatBot.RegisterHandler(bot.HandlerTypeMessageText, timezoneCommand, bot.MatchTypeExact, b.timezoneHandler)
+	b.chatBot.RegisterHandler(bot.HandlerTypeMessageText, timezoneCommand+" ", bot.MatchTypePrefix, b.timezoneHandler)
+	b.chatBot.RegisterHandler(bot.HandlerTypeCallbackQueryData, draftCallbackPrefix, bot.MatchTypePrefix, b.draftCallbackHandler)
+	b.chatBot.RegisterHandler(bot.HandlerTypeCallbackQueryData, unlinkCallbackPrefix, bot.MatchTypePrefix, b.unlinkCallbackHandler)
+	b.chatBot.RegisterHandler(bot.HandlerTypeCallbackQueryData, calendarsCallbackPrefix, bot.MatchTypePrefix, b.calendarsCallbackHandler)
+	b.chatBot.RegisterHandler(bot.HandlerTypeCallbackQueryData, providersCallbackPrefix, bot.MatchTypePrefix, b.providersCallbackHandler)
+	b.chatBot.RegisterHandler(bot.HandlerTypeCallbackQueryData, feedCallbackPrefix, bot.MatchTypePrefix, b.feedCallbackHandler)
+	b.chatBot.RegisterHandler(bot.HandlerTypeCallbackQueryData, draftCalendarCallbackPrefix, bot.MatchTypePrefix, b.draftCalendarCallbackHandler)
+	b.chatBot.RegisterHandler(bot.HandlerTypeCallbackQueryData, eventCallbackDelete, bot.MatchTypePrefix, b.eventCallbackHandler)
+
+	close(b.ready)
+
+	go b.notifyLinkOutcomes(b.ctx)
+	go b.processBufferedMessages(b.ctx)
+
+	if b.WebhookEnabled() {
+		if err := b.setWebhook(); err != nil {
+			return err
+		}
+		<-b.ctx.Done()
+		return nil
+	}
 
+	// A webhook left by an earlier webhook mode run would make polling fail.
+	b.deleteWebhook()
 	b.chatBot.Start(b.ctx)
 	return nil
 }
 
 // Stop terminates update processing.
 func (b *Bot) Stop() {
+	if b.WebhookEnabled() {
+		if b.cfg.Webhook.DeleteOnStop {
+			b.deleteWebhook()
+		}
+		return
+	}
 	b.chatBot.Close(b.ctx)
 }
 
@@ -91,88 +157,137 @@ func (b *Bot) startHandler(ctx context.Context, botAPI *bot.Bot, update *models.
 		b.sendMessage(ctx, chatID, err.Error(), "")
 		return
 	}
-	b.sendMessage(ctx, chatID, "Link your Calendar: /linkgoogle", "")
+	b.sendMessage(ctx, chatID, linkCommandsText(b.userService.Providers())+"\nUntil you link one, events are sent as .ics files.\nSet your time zone: /timezone\nChoose calendars: /calendars, /providers\nSubscribe to your events: /feed\nCheck your accounts: /status\nDisconnect an account: /unlink", "")
 }
 
-// linkGoogleAccountHandler returns the OAuth2 URL for linking a Google account.
-func (b *Bot) linkGoogleAccountHandler(ctx context.Context, botAPI *bot.Bot, update *models.Update) {
-	chatID := update.Message.Chat.ID
-	link, err := b.userService.GetOAuth2Url(
-		chatID,
-		func(err error) {
-			if err != nil {
-				b.sendMessage(ctx, chatID, err.Error(), "")
-			} else {
-				b.sendMessage(ctx, chatID, "Account linked successfully :)", "")
-			}
-		},
-		model.ProviderGoogle,
-	)
-	if err != nil {
-		b.sendMessage(ctx, chatID, err.Error(), "")
-		return
+// linkAccountHandler returns a handler that sends the OAuth2 URL for linking an account of the provider.
+func (b *Bot) linkAccountHandler(provider model.Provider) bot.HandlerFunc {
+	return func(ctx context.Context, botAPI *bot.Bot, update *models.Update) {
+		chatID := update.Message.Chat.ID
+		link, err := b.userService.GetOAuth2Url(chatID, provider)
+		if err != nil {
+			b.sendMessage(ctx, chatID, err.Error(), "")
+			return
+		}
+		b.sendMessage(ctx, chatID, "Link your "+providerNames[provider]+" Calendar: "+link, "")
 	}
-	b.sendMessage(ctx, chatID, "Link your Google Calendar: "+link, "")
 }
 
 // defaultHandler now checks if the message is forwarded and buffers it.
 func (b *Bot) defaultHandler(ctx context.Context, botAPI *bot.Bot, update *models.Update) {
+	if update.Message == nil {
+		return
+	}
+
+	// Answers to /linkcaldav come first, even when they reply to the bot's question.
+	if b.continueCalDAVLink(ctx, update) {
+		return
+	}
+
+	// Replies to the bot's own cards are corrections, not new events.
+	if reply := update.Message.ReplyToMessage; reply != nil && reply.From != nil && reply.From.ID == botAPI.ID() {
+		b.replyToCardHandler(ctx, update)
+		return
+	}
+
+	if update.Message.Location != nil {
+		b.locationHandler(ctx, update)
+		return
+	}
+
+	if isCalendarFile(update.Message.Document) {
+		b.importCalendarFile(ctx, update)
+		return
+	}
+
 	b.bufferUpdate(ctx, update)
 	// If the message has no text or caption, do nothing.
 }
 
-// bufferForwardedText buffers forwarded text messages by chat.
+// bufferUpdate adds the message to the durable buffer of the chat. The messages
+// are processed together once the chat is quiet, see processBufferedMessages.
 func (b *Bot) bufferUpdate(ctx context.Context, update *models.Update) {
 	chatID := update.Message.Chat.ID
-	b.bufferMutex.Lock()
-	defer b.bufferMutex.Unlock()
 
-	// Append the message to the buffer.
-	b.bufferedUpdates[chatID] = append(b.bufferedUpdates[chatID], update)
+	message := updateToMessage(update)
+	if message.Text == "" {
+		return
+	}
 
-	// Reset the timer if it already exists.
-	if timer, exists := b.bufferTimers[chatID]; exists {
-		timer.Stop()
+	if err := b.messageBuffer.Add(chatID, message); err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Int("messageID", message.MessageID).
+			Err(err).
+			Msg("Failed to buffer message")
+		b.sendMessage(ctx, chatID, "Failed to read the message. Try sending it again later.", "")
 	}
-	// Start a new timer. After 3 seconds of inactivity, process the buffered messages.
-	b.bufferTimers[chatID] = time.AfterFunc(3*time.Second, func() {
-		b.processBufferedMessages(ctx, chatID)
-	})
 }
 
-// processBufferedMessages processes all buffered messages for a chat.
-func (b *Bot) processBufferedMessages(ctx context.Context, chatID int64) {
-	b.bufferMutex.Lock()
+// processBufferedMessages claims the buffers of chats that have gone quiet and turns
+// their messages into drafts. Every instance of the bot runs it, each batch is
+// handled by the one that claims it.
+func (b *Bot) processBufferedMessages(ctx context.Context) {
+	workers := make(chan struct{}, bufferWorkers)
+	ticker := time.NewTicker(bufferPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 
-	messages := b.bufferedUpdates[chatID]
+		// Only as many batches are claimed as there are idle workers, so a slow
+		// batch neither holds up the others nor sits on a lease it cannot serve.
+		idle := cap(workers) - len(workers)
+		if idle == 0 {
+			continue
+		}
 
-	delete(b.bufferedUpdates, chatID)
-	delete(b.bufferTimers, chatID)
-	b.bufferMutex.Unlock()
+		batches, err := b.messageBuffer.ClaimDue(idle)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to claim buffered messages")
+			continue
+		}
 
-	if len(messages) == 0 {
-		return
+		for _, batch := range batches {
+			workers <- struct{}{}
+			go func() {
+				defer func() { <-workers }()
+				b.processBatch(ctx, batch)
+			}()
+		}
 	}
+}
 
-	textMessages := make([]model.TextMessage, len(messages))
-	for i, msg := range messages {
-		textMessages[i] = updateToMessage(msg)
-	}
+// processBatch extracts the events of the chat's buffered messages. The batch is
+// completed even if the extraction fails, as the user is told to try later.
+func (b *Bot) processBatch(ctx context.Context, batch storage.BufferedBatch) {
+	chatID := batch.ChatID
 
-	createdEvents, err := b.eventService.CreateEventsFromUserMessage(chatID, textMessages)
+	drafts, err := b.eventService.CreateDraftsFromUserMessage(chatID, batch.Messages)
 	if err != nil {
 		log.Error().
 			Int64("chatID", chatID).
 			Err(err).
-			Msg("Failed to create events")
-		b.sendMessage(ctx, chatID, "Failed to create events. Try later.", "")
-	} else if len(createdEvents) == 0 {
+			Msg("Failed to extract events")
+		b.sendMessage(ctx, chatID, "Failed to extract events. Try later.", "")
+	} else if len(drafts) == 0 {
 		b.sendMessage(ctx, chatID, "No events found in forwarded messages.", "")
 	} else {
-		for _, event := range createdEvents {
-			b.sendMessage(ctx, chatID, formatEventForTelegram(event), models.ParseModeMarkdownV1)
+		for _, draft := range drafts {
+			b.sendDraft(ctx, draft)
 		}
 	}
+
+	if err := b.messageBuffer.Complete(batch); err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Err(err).
+			Msg("Failed to complete buffered messages")
+	}
 }
 
 func (b *Bot) sendMessage(ctx context.Context, chatID int64, text string, parseMode models.ParseMode) {
@@ -186,6 +301,55 @@ func (b *Bot) sendMessage(ctx context.Context, chatID int64, text string, parseM
 	b.chatBot.SendMessage(ctx, params)
 }
 
+func (b *Bot) editMessage(
+	ctx context.Context,
+	chatID int64,
+	messageID int,
+	text string,
+	replyMarkup models.ReplyMarkup,
+) {
+	params := &bot.EditMessageTextParams{
+		ChatID:    chatID,
+		MessageID: messageID,
+		Text:      text,
+		ParseMode: models.ParseModeMarkdownV1,
+	}
+	if replyMarkup != nil {
+		params.ReplyMarkup = replyMarkup
+	}
+	b.chatBot.EditMessageText(ctx, params)
+}
+
+func (b *Bot) editReplyMarkup(ctx context.Context, chatID int64, messageID int, replyMarkup models.ReplyMarkup) {
+	b.chatBot.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+		ChatID:      chatID,
+		MessageID:   messageID,
+		ReplyMarkup: replyMarkup,
+	})
+}
+
+func (b *Bot) answerCallback(ctx context.Context, callbackQueryID string, text string, alert bool) {
+	b.chatBot.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: callbackQueryID,
+		Text:            text,
+		ShowAlert:       alert,
+	})
+}
+
+// parseCallbackData splits "<prefix><action>:<id>" into the action and the ID.
+func parseCallbackData(data string, prefix string) (string, int, error) {
+	action, id, found := strings.Cut(strings.TrimPrefix(data, prefix), ":")
+	if !found {
+		return "", 0, fmt.Errorf("malformed callback data: %s", data)
+	}
+
+	parsedID, err := strconv.Atoi(id)
+	if err != nil {
+		return "", 0, fmt.Errorf("malformed callback data: %s", data)
+	}
+	return action, parsedID, nil
+}
+
 func formatEventForTelegram(scheduledEvent model.ScheduledEvent) string {
 	event := scheduledEvent.Event
 
@@ -193,10 +357,18 @@ func formatEventForTelegram(scheduledEvent model.ScheduledEvent) string {
 	if event.Description != "" {
 		message += fmt.Sprintf("%s\n", event.Description)
 	}
-	message += fmt.Sprintf("*When:* %s - %s\n",
-		event.Start.Format(time.DateTime),
-		event.End.Format(time.DateTime),
-	)
+	message += fmt.Sprintf("*When:* %s\n", formatEventTime(&event))
+	if rule, ok, err := event.RRule(); err == nil && ok {
+		message += fmt.Sprintf("*Repeats:* %s\n", rule.Describe())
+
+		if len(event.ExceptionDates) > 0 {
+			dates := make([]string, len(event.ExceptionDates))
+			for i, date := range event.ExceptionDates {
+				dates[i] = date.Format(time.DateOnly)
+			}
+			message += fmt.Sprintf("*Except:* %s\n", strings.Join(dates, ", "))
+		}
+	}
 	if event.Location != "" {
 		message += fmt.Sprintf("*Where:* %s\n", event.Location)
 	}
@@ -206,8 +378,24 @@ func formatEventForTelegram(scheduledEvent model.ScheduledEvent) string {
 	return message
 }
 
-func debugHandler(format string, args ...interface{}) {
-	log.Debug().Msg(fmt.Sprintf(format, args...))
+func formatEventTime(event *model.Event) string {
+	if !event.AllDay {
+		when := fmt.Sprintf("%s - %s", event.Start.Format(time.DateTime), event.End.Format(time.DateTime))
+		if event.TimeZone != "" {
+			when += " (" + event.TimeZone + ")"
+		}
+		return when
+	}
+
+	days := event.Days()
+	if days == 1 {
+		return fmt.Sprintf("%s, all day", event.Start.Format(time.DateOnly))
+	}
+	return fmt.Sprintf("%s - %s, all day (%d days)",
+		event.Start.Format(time.DateOnly),
+		event.End.Format(time.DateOnly),
+		days,
+	)
 }
 
 func errorsHandler(err error) {
@@ -215,28 +403,31 @@ func errorsHandler(err error) {
 }
 
 func updateToMessage(update *models.Update) model.TextMessage {
-	var msgType model.MessageType
-	if update.Message.Text != "" {
-		msgType = model.UserMessage
-	} else if update.Message.Caption != "" {
-		msgType = model.ForwardedMessage
+	message := update.Message
+
+	var text string
+	var entities []models.MessageEntity
+	if message.Text != "" {
+		text = message.Text
+		entities = message.Entities
+	} else if message.Caption != "" {
+		text = message.Caption
+		entities = message.CaptionEntities
 	} else {
 		return model.TextMessage{}
 	}
 
 	var from string
-	var text string
-	var entities []models.MessageEntity
+	if message.From != nil {
+		from = message.From.Username
+	}
 
-	switch msgType {
-	case model.UserMessage:
-		from = update.Message.From.Username
-		text = update.Message.Text
-		entities = update.Message.Entities
-	case model.ForwardedMessage:
-		from = update.Message.From.Username
-		text = update.Message.Caption
-		entities = update.Message.CaptionEntities
+	msgType := model.UserMessage
+	forwardedFrom := ""
+	date := time.Unix(int64(message.Date), 0)
+	if message.ForwardOrigin != nil {
+		msgType = model.ForwardedMessage
+		forwardedFrom, date = forwardOrigin(message.ForwardOrigin, date)
 	}
 
 	var matterEntities = make([]models.MessageEntity, 0)
@@ -249,10 +440,51 @@ func updateToMessage(update *models.Update) model.TextMessage {
 	}
 
 	return model.TextMessage{
-		From:        from,
-		Text:        FormatMessageText(text, matterEntities),
-		MessageType: msgType,
+		MessageID:     message.ID,
+		From:          from,
+		Text:          FormatMessageText(text, matterEntities),
+		MessageType:   msgType,
+		ForwardedFrom: forwardedFrom,
+		Date:          date,
+	}
+}
+
+// forwardOrigin returns the name of the original author of a forwarded message
+// and the date it was originally posted, falling back to the given date.
+func forwardOrigin(origin *models.MessageOrigin, date time.Time) (string, time.Time) {
+	var name string
+	var originalDate int
+
+	switch origin.Type {
+	case models.MessageOriginTypeUser:
+		user := origin.MessageOriginUser.SenderUser
+		name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+		if name == "" {
+			name = user.Username
+		}
+		originalDate = origin.MessageOriginUser.Date
+	case models.MessageOriginTypeHiddenUser:
+		name = origin.MessageOriginHiddenUser.SenderUserName
+		originalDate = origin.MessageOriginHiddenUser.Date
+	case models.MessageOriginTypeChat:
+		name = chatName(origin.MessageOriginChat.SenderChat)
+		originalDate = origin.MessageOriginChat.Date
+	case models.MessageOriginTypeChannel:
+		name = chatName(origin.MessageOriginChannel.Chat)
+		originalDate = origin.MessageOriginChannel.Date
+	}
+
+	if originalDate != 0 {
+		date = time.Unix(int64(originalDate), 0)
+	}
+	return name, date
+}
+
+func chatName(chat models.Chat) string {
+	if chat.Title != "" {
+		return chat.Title
 	}
+	return chat.Username
 }
 
 func FormatMessageText(text string, entities []models.MessageEntity) string {
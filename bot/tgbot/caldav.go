@@ -0,0 +1,168 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/service"
+	"github.com/ivgag/schedulr/storage"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	linkCalDAVCommand = "/linkcaldav"
+	cancelCommand     = "/cancel"
+)
+
+// linkCalDAVHandler starts collecting the CalDAV server and credentials.
+// It only works in private chats, as the password is sent as a message.
+func (b *Bot) linkCalDAVHandler(ctx context.Context, botAPI *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+	if update.Message.Chat.Type != models.ChatTypePrivate {
+		b.sendMessage(ctx, chatID, "For your safety, link a CalDAV account in a private chat with the bot.", "")
+		return
+	}
+
+	if err := b.calDAVLinks.Start(chatID); err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Err(err).
+			Msg("Failed to start CalDAV link")
+		b.sendMessage(ctx, chatID, "Failed to start linking. Try later.", "")
+		return
+	}
+	b.sendMessage(
+		ctx,
+		chatID,
+		"Send the address of your CalDAV server, for example:\n"+
+			"Yandex: https://caldav.yandex.ru\n"+
+			"Nextcloud: https://cloud.example.com/remote.php/dav\n"+
+			"Fastmail: https://caldav.fastmail.com\n"+
+			"iCloud: https://caldav.icloud.com\n"+
+			"Send "+cancelCommand+" to stop.",
+		"",
+	)
+}
+
+// cancelHandler stops the conversation in progress.
+func (b *Bot) cancelHandler(ctx context.Context, botAPI *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+	stopped, err := b.calDAVLinks.Stop(chatID)
+	if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Err(err).
+			Msg("Failed to cancel CalDAV link")
+		b.sendMessage(ctx, chatID, "Failed to cancel. Try later.", "")
+		return
+	}
+	if stopped {
+		b.sendMessage(ctx, chatID, "Cancelled.", "")
+		return
+	}
+	b.sendMessage(ctx, chatID, "Nothing to cancel.", "")
+}
+
+// continueCalDAVLink handles the user's answer if a /linkcaldav conversation is in progress.
+// It returns false if the message is not part of one.
+func (b *Bot) continueCalDAVLink(ctx context.Context, update *models.Update) bool {
+	chatID := update.Message.Chat.ID
+	link, err := b.calDAVLinks.Get(chatID)
+	var notFound model.NotFoundError
+	if errors.As(err, &notFound) {
+		return false
+	} else if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Err(err).
+			Msg("Failed to get CalDAV link")
+		return false
+	}
+
+	text := strings.TrimSpace(update.Message.Text)
+	if text == "" {
+		b.sendMessage(ctx, chatID, "Send the answer as text, or "+cancelCommand+" to stop.", "")
+		return true
+	}
+
+	switch link.Step {
+	case storage.CalDAVStepServer:
+		parsed, err := url.Parse(text)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			b.sendMessage(ctx, chatID, "This does not look like a server address. Send a URL starting with https://", "")
+			return true
+		}
+
+		link.ServerURL = text
+		link.Step = storage.CalDAVStepUsername
+		if !b.updateCalDAVLink(ctx, link) {
+			return true
+		}
+		b.sendMessage(ctx, chatID, "Send your username, usually your email address.", "")
+	case storage.CalDAVStepUsername:
+		link.Username = text
+		link.Step = storage.CalDAVStepPassword
+		if !b.updateCalDAVLink(ctx, link) {
+			return true
+		}
+		b.sendMessage(
+			ctx,
+			chatID,
+			"Send the password. Use an app password rather than your main one. "+
+				"The message is deleted as soon as it is read.",
+			"",
+		)
+	case storage.CalDAVStepPassword:
+		if _, err := b.calDAVLinks.Stop(chatID); err != nil {
+			log.Error().
+				Int64("chatID", chatID).
+				Err(err).
+				Msg("Failed to stop CalDAV link")
+		}
+		b.chatBot.DeleteMessage(ctx, &bot.DeleteMessageParams{ChatID: chatID, MessageID: update.Message.ID})
+
+		b.sendMessage(ctx, chatID, "Checking the account…", "")
+		err = b.userService.LinkCalDAVAccount(chatID, link.ServerURL, link.Username, text)
+		b.replyCalDAVLinked(ctx, chatID, err)
+	}
+	return true
+}
+
+// updateCalDAVLink saves the answers collected so far. It tells the user and
+// returns false if they could not be saved.
+func (b *Bot) updateCalDAVLink(ctx context.Context, link storage.CalDAVLink) bool {
+	if err := b.calDAVLinks.Update(link); err != nil {
+		log.Error().
+			Int64("chatID", link.ChatID).
+			Err(err).
+			Msg("Failed to update CalDAV link")
+		b.sendMessage(ctx, link.ChatID, "Failed to save the answer. Try again.", "")
+		return false
+	}
+	return true
+}
+
+func (b *Bot) replyCalDAVLinked(ctx context.Context, chatID int64, err error) {
+	switch {
+	case err == nil:
+		b.sendMessage(ctx, chatID, "CalDAV account linked successfully :)", "")
+	case errors.Is(err, service.ErrCalDAVUnauthorized):
+		b.sendMessage(ctx, chatID, "The server rejected the username or password. Try again with "+linkCalDAVCommand, "")
+	case errors.Is(err, service.ErrCalDAVForbiddenURL):
+		b.sendMessage(ctx, chatID, "This server address is not allowed. Use a public HTTPS address.", "")
+	case errors.Is(err, service.ErrCalDAVNoCalendar):
+		b.sendMessage(ctx, chatID, "No calendar for events was found on this server. Check the address.", "")
+	default:
+		log.Error().
+			Int64("chatID", chatID).
+			Str("provider", string(model.ProviderCalDAV)).
+			Err(err).
+			Msg("Failed to link CalDAV account")
+		b.sendMessage(ctx, chatID, "Failed to reach the CalDAV server. Check the address and try again later.", "")
+	}
+}
@@ -0,0 +1,393 @@
+package tgbot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/storage"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	calendarsCommand        = "/calendars"
+	calendarsCallbackPrefix = "calendars:"
+	calendarsActionMenu     = "menu"
+	calendarsActionPick     = "pick"
+	calendarsActionSet      = "set"
+	// calendarsDefaultTarget stands for the default calendar in callback data.
+	calendarsDefaultTarget = "default"
+	// calendarsNoCalendar removes the preference of an event type in callback data.
+	calendarsNoCalendar = "-"
+
+	draftCalendarCallbackPrefix = "draftcal:"
+)
+
+var eventTypeNames = map[string]string{
+	"event":    "Events",
+	"reminder": "Reminders",
+	"meeting":  "Meetings",
+	"birthday": "Birthdays",
+	"holiday":  "Holidays",
+	"other":    "Other",
+}
+
+// calendarsHandler shows the calendars chosen for each linked account.
+func (b *Bot) calendarsHandler(ctx context.Context, botAPI *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	accounts, err := b.userService.LinkedAccounts(chatID)
+	if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Err(err).
+			Msg("Failed to load linked accounts")
+		b.sendMessage(ctx, chatID, "Failed to load your accounts. Try later.", "")
+		return
+	}
+
+	preferences, err := b.eventService.CalendarPreferences(chatID)
+	if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Err(err).
+			Msg("Failed to load calendar preferences")
+		b.sendMessage(ctx, chatID, "Failed to load your calendars. Try later.", "")
+		return
+	}
+
+	sent := false
+	for _, account := range accounts {
+		if account.Status != storage.LinkedAccountActive {
+			continue
+		}
+
+		b.chatBot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      chatID,
+			Text:        formatCalendarPreferences(account.Provider, preferences),
+			ParseMode:   models.ParseModeMarkdownV1,
+			ReplyMarkup: calendarsMenuKeyboard(account.Provider),
+		})
+		sent = true
+	}
+
+	if !sent {
+		b.sendMessage(ctx, chatID, "Link a calendar first.\n"+linkCommandsText(b.userService.Providers()), "")
+	}
+}
+
+// calendarsCallbackHandler walks through the menu of /calendars:
+// "menu:<provider>", "pick:<provider>:<target>" and "set:<provider>:<target>:<calendar key>".
+func (b *Bot) calendarsCallbackHandler(ctx context.Context, botAPI *bot.Bot, update *models.Update) {
+	query := update.CallbackQuery
+	if query.Message.Message == nil {
+		b.answerCallback(ctx, query.ID, "", false)
+		return
+	}
+
+	chatID := query.Message.Message.Chat.ID
+	messageID := query.Message.Message.ID
+
+	parts := strings.Split(strings.TrimPrefix(query.Data, calendarsCallbackPrefix), ":")
+	if len(parts) < 2 {
+		b.answerCallback(ctx, query.ID, "Unknown action.", false)
+		return
+	}
+	action, provider := parts[0], model.Provider(parts[1])
+
+	switch {
+	case action == calendarsActionMenu:
+		b.showCalendarsMenu(ctx, chatID, messageID, provider)
+		b.answerCallback(ctx, query.ID, "", false)
+	case action == calendarsActionPick && len(parts) == 3:
+		calendars, ok := b.listCalendars(ctx, chatID, query.ID, provider)
+		if !ok {
+			return
+		}
+
+		target := parts[2]
+		b.answerCallback(ctx, query.ID, "", false)
+		b.editMessage(
+			ctx,
+			chatID,
+			messageID,
+			"Pick the calendar for "+strings.ToLower(calendarTargetName(target))+":",
+			calendarsPickKeyboard(provider, target, calendars),
+		)
+	case action == calendarsActionSet && len(parts) == 4:
+		calendars, ok := b.listCalendars(ctx, chatID, query.ID, provider)
+		if !ok {
+			return
+		}
+
+		target, key := parts[2], parts[3]
+		var calendar model.Calendar
+		if key != calendarsNoCalendar {
+			var found bool
+			calendar, found = findCalendar(calendars, key)
+			if !found {
+				b.answerCallback(ctx, query.ID, "This calendar no longer exists. Send "+calendarsCommand+" again.", true)
+				return
+			}
+		}
+
+		eventType := target
+		if target == calendarsDefaultTarget {
+			eventType = ""
+		}
+		if err := b.eventService.SetCalendarPreference(chatID, provider, eventType, calendar); err != nil {
+			log.Error().
+				Int64("chatID", chatID).
+				Str("provider", string(provider)).
+				Err(err).
+				Msg("Failed to save calendar preference")
+			b.answerCallback(ctx, query.ID, "Failed to save the calendar. Try later.", true)
+			return
+		}
+
+		b.answerCallback(ctx, query.ID, "Saved.", false)
+		b.showCalendarsMenu(ctx, chatID, messageID, provider)
+	default:
+		b.answerCallback(ctx, query.ID, "Unknown action.", false)
+	}
+}
+
+func (b *Bot) showCalendarsMenu(ctx context.Context, chatID int64, messageID int, provider model.Provider) {
+	preferences, err := b.eventService.CalendarPreferences(chatID)
+	if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Err(err).
+			Msg("Failed to load calendar preferences")
+		return
+	}
+	b.editMessage(ctx, chatID, messageID, formatCalendarPreferences(provider, preferences), calendarsMenuKeyboard(provider))
+}
+
+// listCalendars loads the calendars of the provider, answering the callback if it fails.
+func (b *Bot) listCalendars(
+	ctx context.Context,
+	chatID int64,
+	callbackQueryID string,
+	provider model.Provider,
+) ([]model.Calendar, bool) {
+	calendars, err := b.eventService.ListCalendars(chatID, provider)
+	if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Str("provider", string(provider)).
+			Err(err).
+			Msg("Failed to list calendars")
+		if b.sendRelinkPrompt(ctx, chatID, err) {
+			b.answerCallback(ctx, callbackQueryID, "Link your account again to see its calendars.", true)
+			return nil, false
+		}
+		b.answerCallback(ctx, callbackQueryID, "Failed to load your calendars. Try later.", true)
+		return nil, false
+	} else if len(calendars) == 0 {
+		b.answerCallback(ctx, callbackQueryID, "No calendars you can add events to were found.", true)
+		return nil, false
+	}
+	return calendars, true
+}
+
+func formatCalendarPreferences(provider model.Provider, preferences []storage.CalendarPreference) string {
+	defaultName := "the default calendar of the account"
+	routes := make(map[string]string)
+	for _, preference := range preferences {
+		if preference.Provider != provider {
+			continue
+		} else if preference.EventType == "" {
+			defaultName = escapeMarkdown(preference.CalendarName)
+		} else {
+			routes[preference.EventType] = escapeMarkdown(preference.CalendarName)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("*" + providerNames[provider] + " Calendar*\n")
+	sb.WriteString("New events go to " + defaultName + ".\n")
+	for _, eventType := range model.EventTypes {
+		if name, ok := routes[eventType]; ok {
+			sb.WriteString(eventTypeNames[eventType] + " go to " + name + ".\n")
+		}
+	}
+	sb.WriteString("\nPick what to change:")
+	return sb.String()
+}
+
+func calendarsMenuKeyboard(provider model.Provider) *models.InlineKeyboardMarkup {
+	buttons := [][]models.InlineKeyboardButton{{{
+		Text:         calendarTargetName(calendarsDefaultTarget),
+		CallbackData: calendarsCallbackData(calendarsActionPick, provider, calendarsDefaultTarget),
+	}}}
+
+	var row []models.InlineKeyboardButton
+	for _, eventType := range model.EventTypes {
+		row = append(row, models.InlineKeyboardButton{
+			Text:         eventTypeNames[eventType],
+			CallbackData: calendarsCallbackData(calendarsActionPick, provider, eventType),
+		})
+		if len(row) == 3 {
+			buttons = append(buttons, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		buttons = append(buttons, row)
+	}
+
+	return &models.InlineKeyboardMarkup{InlineKeyboard: buttons}
+}
+
+// calendarsPickKeyboard lists the calendars by their keys, as calendar IDs
+// do not fit into the 64 bytes of callback data.
+func calendarsPickKeyboard(provider model.Provider, target string, calendars []model.Calendar) *models.InlineKeyboardMarkup {
+	var buttons [][]models.InlineKeyboardButton
+	for _, calendar := range calendars {
+		buttons = append(buttons, []models.InlineKeyboardButton{{
+			Text:         calendarButtonText(calendar),
+			CallbackData: calendarsCallbackData(calendarsActionSet, provider, target, calendarKey(calendar.ID)),
+		}})
+	}
+
+	if target != calendarsDefaultTarget {
+		buttons = append(buttons, []models.InlineKeyboardButton{{
+			Text:         "Same as default",
+			CallbackData: calendarsCallbackData(calendarsActionSet, provider, target, calendarsNoCalendar),
+		}})
+	}
+	buttons = append(buttons, []models.InlineKeyboardButton{{
+		Text:         "Back",
+		CallbackData: calendarsCallbackData(calendarsActionMenu, provider),
+	}})
+
+	return &models.InlineKeyboardMarkup{InlineKeyboard: buttons}
+}
+
+func calendarsCallbackData(action string, provider model.Provider, args ...string) string {
+	return calendarsCallbackPrefix + strings.Join(append([]string{action, string(provider)}, args...), ":")
+}
+
+// calendarKey is a short hash of the calendar ID for callback data. Unlike the position
+// of the calendar in the list, it still points to the same calendar if the list changes.
+func calendarKey(calendarID string) string {
+	sum := sha256.Sum256([]byte(calendarID))
+	return base64.RawURLEncoding.EncodeToString(sum[:6])
+}
+
+// findCalendar returns the calendar whose ID has the key.
+func findCalendar(calendars []model.Calendar, key string) (model.Calendar, bool) {
+	for _, calendar := range calendars {
+		if calendarKey(calendar.ID) == key {
+			return calendar, true
+		}
+	}
+	return model.Calendar{}, false
+}
+
+func calendarTargetName(target string) string {
+	if target == calendarsDefaultTarget {
+		return "Default"
+	}
+	return eventTypeNames[target]
+}
+
+func calendarButtonText(calendar model.Calendar) string {
+	if calendar.Primary {
+		return calendar.Name + " (primary)"
+	}
+	return calendar.Name
+}
+
+// showDraftCalendars replaces the buttons of the draft card with the calendars it can be created in.
+func (b *Bot) showDraftCalendars(ctx context.Context, chatID int64, messageID int, queryID string, draftID int) {
+	calendars, err := b.eventService.DraftCalendars(chatID, draftID)
+	if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Int("draftID", draftID).
+			Err(err).
+			Msg("Failed to list calendars for draft")
+		if b.sendRelinkPrompt(ctx, chatID, err) {
+			b.answerCallback(ctx, queryID, "Link your account again to see its calendars.", true)
+			return
+		}
+		b.answerCallback(ctx, queryID, draftErrorText(err, "Failed to load your calendars. Try later."), true)
+		return
+	} else if len(calendars) == 0 {
+		b.answerCallback(ctx, queryID, "There are no calendars to pick from. Link a calendar to choose one.", true)
+		return
+	}
+
+	var buttons [][]models.InlineKeyboardButton
+	for _, calendar := range calendars {
+		buttons = append(buttons, []models.InlineKeyboardButton{{
+			Text:         calendarButtonText(calendar),
+			CallbackData: draftCalendarCallbackPrefix + calendarKey(calendar.ID) + ":" + strconv.Itoa(draftID),
+		}})
+	}
+	buttons = append(buttons, []models.InlineKeyboardButton{{
+		Text:         "Back",
+		CallbackData: draftCallbackData(draftActionBack, draftID),
+	}})
+
+	b.answerCallback(ctx, queryID, "", false)
+	b.editReplyMarkup(ctx, chatID, messageID, &models.InlineKeyboardMarkup{InlineKeyboard: buttons})
+}
+
+// draftCalendarCallbackHandler moves the draft to the calendar picked on its card.
+func (b *Bot) draftCalendarCallbackHandler(ctx context.Context, botAPI *bot.Bot, update *models.Update) {
+	query := update.CallbackQuery
+	if query.Message.Message == nil {
+		b.answerCallback(ctx, query.ID, "This draft is too old to be changed.", true)
+		return
+	}
+
+	chatID := query.Message.Message.Chat.ID
+	messageID := query.Message.Message.ID
+
+	key, draftID, err := parseCallbackData(query.Data, draftCalendarCallbackPrefix)
+	if err != nil {
+		b.answerCallback(ctx, query.ID, "Unknown action.", false)
+		return
+	}
+
+	calendars, err := b.eventService.DraftCalendars(chatID, draftID)
+	if err != nil {
+		b.answerCallback(ctx, query.ID, draftErrorText(err, "Failed to load your calendars. Try later."), true)
+		return
+	}
+
+	calendar, found := findCalendar(calendars, key)
+	if !found {
+		b.answerCallback(ctx, query.ID, "This calendar no longer exists.", true)
+		return
+	}
+
+	draft, err := b.eventService.SetDraftCalendar(chatID, draftID, calendar)
+	if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Int("draftID", draftID).
+			Err(err).
+			Msg("Failed to change the calendar of the draft")
+		b.answerCallback(ctx, query.ID, draftErrorText(err, "Failed to change the calendar. Try later."), true)
+		return
+	}
+
+	b.answerCallback(ctx, query.ID, "Calendar changed.", false)
+	b.editMessage(ctx, chatID, messageID, formatDraftForTelegram(draft), draftKeyboard(draft.ID))
+}
+
+var markdownEscaper = strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[")
+
+// escapeMarkdown escapes the characters that have a meaning in Telegram's legacy Markdown.
+func escapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
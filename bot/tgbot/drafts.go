@@ -0,0 +1,195 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/service"
+	"github.com/ivgag/schedulr/storage"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	draftCallbackPrefix = "draft:"
+	draftActionCreate   = "create"
+	// draftActionForce creates the draft even though it looks like a duplicate.
+	draftActionForce    = "force"
+	draftActionEdit     = "edit"
+	draftActionDiscard  = "discard"
+	draftActionCalendar = "calendar"
+	draftActionBack     = "back"
+)
+
+func (b *Bot) sendDraft(ctx context.Context, draft storage.EventDraft) {
+	msg, err := b.chatBot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      draft.ChatID,
+		Text:        formatDraftForTelegram(draft),
+		ParseMode:   models.ParseModeMarkdownV1,
+		ReplyMarkup: draftKeyboard(draft.ID),
+	})
+	if err != nil {
+		log.Error().
+			Int("draftID", draft.ID).
+			Err(err).
+			Msg("Failed to send draft")
+		return
+	}
+
+	if err := b.eventService.AttachDraftMessage(draft.ID, msg.ID); err != nil {
+		log.Error().
+			Int("draftID", draft.ID).
+			Err(err).
+			Msg("Failed to attach message to draft")
+	}
+}
+
+func draftKeyboard(draftID int) *models.InlineKeyboardMarkup {
+	return &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{
+				{Text: "Create", CallbackData: draftCallbackData(draftActionCreate, draftID)},
+				{Text: "Edit", CallbackData: draftCallbackData(draftActionEdit, draftID)},
+				{Text: "Discard", CallbackData: draftCallbackData(draftActionDiscard, draftID)},
+			},
+			{
+				{Text: "Calendar", CallbackData: draftCallbackData(draftActionCalendar, draftID)},
+			},
+		},
+	}
+}
+
+func draftCallbackData(action string, draftID int) string {
+	return draftCallbackPrefix + action + ":" + strconv.Itoa(draftID)
+}
+
+func formatDraftForTelegram(draft storage.EventDraft) string {
+	text := "*Draft*\n" + formatEventForTelegram(model.ScheduledEvent{Event: draft.Event})
+	if draft.CalendarName != "" {
+		text += "*Calendar:* " + escapeMarkdown(draft.CalendarName) + "\n"
+	}
+	return text
+}
+
+// draftCallbackHandler handles the Create / Edit / Discard / Calendar buttons of a draft card
+// and the Create anyway button shown when the draft looks like a duplicate.
+func (b *Bot) draftCallbackHandler(ctx context.Context, botAPI *bot.Bot, update *models.Update) {
+	query := update.CallbackQuery
+	if query.Message.Message == nil {
+		b.answerCallback(ctx, query.ID, "This draft is too old to be changed.", true)
+		return
+	}
+
+	chatID := query.Message.Message.Chat.ID
+	messageID := query.Message.Message.ID
+
+	action, draftID, err := parseCallbackData(query.Data, draftCallbackPrefix)
+	if err != nil {
+		log.Warn().
+			Str("data", query.Data).
+			Err(err).
+			Msg("Failed to parse draft callback")
+		b.answerCallback(ctx, query.ID, "Unknown action.", false)
+		return
+	}
+
+	switch action {
+	case draftActionCreate, draftActionForce:
+		results, err := b.eventService.ConfirmDraft(chatID, draftID, action == draftActionForce)
+		var duplicate service.DuplicateEventError
+		if errors.As(err, &duplicate) {
+			b.answerCallback(ctx, query.ID, "", false)
+			b.showDuplicate(ctx, chatID, messageID, draftID, duplicate.Existing)
+			return
+		} else if err != nil {
+			log.Error().
+				Int64("chatID", chatID).
+				Int("draftID", draftID).
+				Err(err).
+				Msg("Failed to create event from draft")
+			if b.sendRelinkPrompt(ctx, chatID, err) {
+				b.answerCallback(ctx, query.ID, "Link your account again to create the event.", true)
+				return
+			}
+			b.answerCallback(ctx, query.ID, draftErrorText(err, "Failed to create the event. Try later."), true)
+			return
+		}
+
+		b.answerCallback(ctx, query.ID, "Event created.", false)
+		b.editMessage(ctx, chatID, messageID, formatEventCard(results), eventKeyboard())
+		b.sendRelinkPrompts(ctx, chatID, results)
+		b.sendEventFiles(ctx, chatID, messageID, results)
+	case draftActionEdit:
+		b.answerCallback(
+			ctx,
+			query.ID,
+			"Reply to this draft with what should be changed, e.g. \"starts at 19:30\" or \"location is the city park\".",
+			true,
+		)
+	case draftActionDiscard:
+		draft, err := b.eventService.DiscardDraft(chatID, draftID)
+		if err != nil {
+			b.answerCallback(ctx, query.ID, draftErrorText(err, "Failed to discard the draft. Try later."), true)
+			return
+		}
+
+		b.answerCallback(ctx, query.ID, "Draft discarded.", false)
+		b.editMessage(ctx, chatID, messageID, "_Discarded:_ "+draft.Event.Title, nil)
+	case draftActionCalendar:
+		b.showDraftCalendars(ctx, chatID, messageID, query.ID, draftID)
+	case draftActionBack:
+		b.answerCallback(ctx, query.ID, "", false)
+		b.editReplyMarkup(ctx, chatID, messageID, draftKeyboard(draftID))
+	default:
+		b.answerCallback(ctx, query.ID, "Unknown action.", false)
+	}
+}
+
+// showDuplicate asks the user whether the draft should be created even though
+// the calendar seems to have the event already.
+func (b *Bot) showDuplicate(ctx context.Context, chatID int64, messageID int, draftID int, existing model.ScheduledEvent) {
+	draft, err := b.eventService.GetDraftByMessage(chatID, messageID)
+	if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Int("draftID", draftID).
+			Err(err).
+			Msg("Failed to get draft")
+		return
+	}
+
+	text := formatDraftForTelegram(draft) +
+		"\n⚠️ *Looks like you already have this in " + providerNames[existing.Provider] + " Calendar:*\n" +
+		formatEventForTelegram(existing) +
+		"\nCreate it anyway?"
+	b.editMessage(ctx, chatID, messageID, text, duplicateKeyboard(draftID))
+}
+
+func duplicateKeyboard(draftID int) *models.InlineKeyboardMarkup {
+	return &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{
+				{Text: "Create anyway", CallbackData: draftCallbackData(draftActionForce, draftID)},
+				{Text: "Discard", CallbackData: draftCallbackData(draftActionDiscard, draftID)},
+			},
+		},
+	}
+}
+
+// draftErrorText returns a user-facing text for errors that the user can act on,
+// falling back to the generic text for everything else.
+func draftErrorText(err error, fallback string) string {
+	var notFound model.NotFoundError
+	if errors.As(err, &notFound) {
+		return "This draft no longer exists."
+	}
+
+	if errors.Is(err, service.ErrDraftAlreadyProcessed) {
+		return "This draft was already processed."
+	}
+
+	return fallback
+}
@@ -0,0 +1,256 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/service"
+	"github.com/ivgag/schedulr/storage"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	eventCallbackDelete        = "event:delete"
+	eventCallbackDeleteConfirm = "event:delete:confirm"
+	eventCallbackDeleteCancel  = "event:delete:cancel"
+)
+
+func eventKeyboard() *models.InlineKeyboardMarkup {
+	return &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{
+				{Text: "Delete", CallbackData: eventCallbackDelete},
+			},
+		},
+	}
+}
+
+func deleteConfirmationKeyboard() *models.InlineKeyboardMarkup {
+	return &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{
+				{Text: "Yes, delete", CallbackData: eventCallbackDeleteConfirm},
+				{Text: "Keep", CallbackData: eventCallbackDeleteCancel},
+			},
+		},
+	}
+}
+
+// eventCallbackHandler handles the Delete button of a created event card.
+func (b *Bot) eventCallbackHandler(ctx context.Context, botAPI *bot.Bot, update *models.Update) {
+	query := update.CallbackQuery
+	if query.Message.Message == nil {
+		b.answerCallback(ctx, query.ID, "This event is too old to be changed.", true)
+		return
+	}
+
+	chatID := query.Message.Message.Chat.ID
+	messageID := query.Message.Message.ID
+
+	switch query.Data {
+	case eventCallbackDelete:
+		b.answerCallback(ctx, query.ID, "", false)
+		b.editReplyMarkup(ctx, chatID, messageID, deleteConfirmationKeyboard())
+	case eventCallbackDeleteCancel:
+		b.answerCallback(ctx, query.ID, "", false)
+		b.editReplyMarkup(ctx, chatID, messageID, eventKeyboard())
+	case eventCallbackDeleteConfirm:
+		deleted, err := b.eventService.DeleteEventByMessage(chatID, messageID)
+		var notFound model.NotFoundError
+		if errors.As(err, &notFound) {
+			b.answerCallback(ctx, query.ID, "This event no longer exists.", true)
+			return
+		} else if err != nil {
+			log.Error().
+				Int64("chatID", chatID).
+				Int("messageID", messageID).
+				Err(err).
+				Msg("Failed to delete event")
+			if b.sendRelinkPrompt(ctx, chatID, err) {
+				b.answerCallback(ctx, query.ID, "Link your account again to delete the event.", true)
+				return
+			}
+			b.answerCallback(ctx, query.ID, "Failed to delete the event. Try later.", true)
+			return
+		}
+
+		if deleted.Provider == model.ProviderICS {
+			// The file was already added to the calendar of the device.
+			b.answerCallback(ctx, query.ID, "Event deleted. Remove it from the calendar of your device as well.", true)
+		} else {
+			b.answerCallback(ctx, query.ID, "Event deleted.", false)
+		}
+		b.editMessage(ctx, chatID, messageID, "_Deleted:_ "+deleted.Event.Title, nil)
+	default:
+		b.answerCallback(ctx, query.ID, "Unknown action.", false)
+	}
+}
+
+// undoHandler removes everything created from the user's most recent batch of messages.
+func (b *Bot) undoHandler(ctx context.Context, botAPI *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	result, err := b.eventService.UndoLastBatch(chatID)
+	var notFound model.NotFoundError
+	if errors.As(err, &notFound) {
+		b.sendMessage(ctx, chatID, "Nothing to undo.", "")
+		return
+	}
+
+	for _, event := range result.DeletedEvents {
+		if event.CardMessageID != 0 {
+			b.editMessage(ctx, chatID, event.CardMessageID, "_Deleted:_ "+event.Event.Title, nil)
+		}
+	}
+	for _, draft := range result.DiscardedDrafts {
+		if draft.MessageID != 0 {
+			b.editMessage(ctx, chatID, draft.MessageID, "_Discarded:_ "+draft.Event.Title, nil)
+		}
+	}
+
+	if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Err(err).
+			Msg("Failed to undo the last batch")
+		if b.sendRelinkPrompt(ctx, chatID, err) {
+			return
+		}
+		b.sendMessage(ctx, chatID, "Failed to undo some of the events. Try later.", "")
+		return
+	}
+
+	b.sendMessage(
+		ctx,
+		chatID,
+		fmt.Sprintf(
+			"Undone: %d event(s) deleted, %d draft(s) discarded.",
+			len(result.DeletedEvents),
+			len(result.DiscardedDrafts),
+		),
+		"",
+	)
+}
+
+// replyToCardHandler applies an instruction sent as a reply to a card posted by the bot:
+// pending drafts are revised, created events are patched in the calendar.
+func (b *Bot) replyToCardHandler(ctx context.Context, update *models.Update) {
+	chatID := update.Message.Chat.ID
+	cardID := update.Message.ReplyToMessage.ID
+
+	instruction := updateToMessage(update).Text
+	if instruction == "" {
+		b.sendMessage(ctx, chatID, "Reply with a text describing what should be changed.", "")
+		return
+	}
+
+	var notFound model.NotFoundError
+
+	draft, err := b.eventService.GetDraftByMessage(chatID, cardID)
+	if err == nil && draft.Status == storage.DraftStatusPending {
+		b.reviseDraft(ctx, draft, instruction)
+		return
+	} else if err != nil && !errors.As(err, &notFound) {
+		log.Error().
+			Int64("chatID", chatID).
+			Int("messageID", cardID).
+			Err(err).
+			Msg("Failed to find draft for reply")
+		b.sendMessage(ctx, chatID, "Failed to update the event. Try later.", "")
+		return
+	}
+
+	results, err := b.eventService.EditEventByMessage(chatID, cardID, instruction)
+	if errors.As(err, &notFound) {
+		b.sendMessage(ctx, chatID, "Only drafts and created events can be edited by replying to them.", "")
+		return
+	} else if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Int("messageID", cardID).
+			Err(err).
+			Msg("Failed to edit event")
+		if b.sendRelinkPrompt(ctx, chatID, err) {
+			return
+		}
+		b.sendMessage(ctx, chatID, "Failed to update the event. Try later.", "")
+		return
+	}
+
+	b.editMessage(ctx, chatID, cardID, formatEventCard(results), eventKeyboard())
+	b.sendRelinkPrompts(ctx, chatID, results)
+	b.sendEventFiles(ctx, chatID, cardID, results)
+	b.sendMessage(ctx, chatID, "Event updated.", "")
+}
+
+// formatEventCard shows the event written to one or more calendars, together with
+// the providers that failed to take it.
+func formatEventCard(results []service.ProviderResult) string {
+	if len(results) == 1 {
+		return formatEventForTelegram(results[0].Event)
+	}
+
+	var written []string
+	var failures []string
+	var event model.Event
+	for _, result := range results {
+		name := providerNames[result.Provider]
+		switch {
+		case result.Err != nil:
+			failures = append(failures, "*Not in "+name+":* "+providerFailureText(result.Err)+"\n")
+		case result.Event.Link != "":
+			event = result.Event.Event
+			written = append(written, fmt.Sprintf("[%s](%s)", name, result.Event.Link))
+		default:
+			event = result.Event.Event
+			written = append(written, name)
+		}
+	}
+
+	text := formatEventForTelegram(model.ScheduledEvent{Event: event})
+	text += "*Calendars:* " + strings.Join(written, ", ") + "\n"
+	return text + strings.Join(failures, "")
+}
+
+func providerFailureText(err error) string {
+	var relinkErr model.RelinkRequiredError
+	if errors.As(err, &relinkErr) {
+		return "access was revoked, link the account again"
+	}
+	return "the calendar failed to respond"
+}
+
+// sendRelinkPrompts offers to relink the providers that lost access while the others succeeded.
+func (b *Bot) sendRelinkPrompts(ctx context.Context, chatID int64, results []service.ProviderResult) {
+	for _, result := range results {
+		if result.Err != nil {
+			log.Error().
+				Int64("chatID", chatID).
+				Str("provider", string(result.Provider)).
+				Err(result.Err).
+				Msg("Failed to write event to provider")
+			b.sendRelinkPrompt(ctx, chatID, result.Err)
+		}
+	}
+}
+
+func (b *Bot) reviseDraft(ctx context.Context, draft storage.EventDraft, instruction string) {
+	revised, err := b.eventService.ReviseDraft(draft.ChatID, draft.ID, instruction)
+	if err != nil {
+		log.Error().
+			Int64("chatID", draft.ChatID).
+			Int("draftID", draft.ID).
+			Err(err).
+			Msg("Failed to revise draft")
+		b.sendMessage(ctx, draft.ChatID, draftErrorText(err, "Failed to update the draft. Try later."), "")
+		return
+	}
+
+	b.editMessage(ctx, draft.ChatID, draft.MessageID, formatDraftForTelegram(revised), draftKeyboard(revised.ID))
+	b.sendMessage(ctx, draft.ChatID, "Draft updated.", "")
+}
@@ -0,0 +1,99 @@
+package tgbot
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	feedCommand        = "/feed"
+	feedCallbackPrefix = "feed:"
+	feedActionRotate   = "rotate"
+	feedActionDisable  = "disable"
+)
+
+func feedKeyboard() *models.InlineKeyboardMarkup {
+	return &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{
+				{Text: "New link", CallbackData: feedCallbackPrefix + feedActionRotate},
+				{Text: "Turn off", CallbackData: feedCallbackPrefix + feedActionDisable},
+			},
+		},
+	}
+}
+
+// feedText shows the feed URL as code, so that it can be copied with a tap.
+func feedText(url string) string {
+	return "Subscribe to this URL in Apple Calendar, Thunderbird or any other calendar app " +
+		"to see every event Schedulr has created for you:\n`" + url + "`\n" +
+		"Anyone with the link can see your events. Get a new link if it leaks."
+}
+
+// feedHandler sends the URL of the user's calendar feed, turning the feed on if needed.
+func (b *Bot) feedHandler(ctx context.Context, botAPI *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	url, err := b.feeds.FeedURL(chatID)
+	if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Err(err).
+			Msg("Failed to get feed URL")
+		b.sendMessage(ctx, chatID, "Failed to get your feed. Try later.", "")
+		return
+	}
+
+	b.chatBot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      chatID,
+		Text:        feedText(url),
+		ParseMode:   models.ParseModeMarkdownV1,
+		ReplyMarkup: feedKeyboard(),
+	})
+}
+
+// feedCallbackHandler replaces the feed URL or turns the feed off, so that the old URL stops working.
+func (b *Bot) feedCallbackHandler(ctx context.Context, botAPI *bot.Bot, update *models.Update) {
+	query := update.CallbackQuery
+	if query.Message.Message == nil {
+		b.answerCallback(ctx, query.ID, "", false)
+		return
+	}
+
+	chatID := query.Message.Message.Chat.ID
+	messageID := query.Message.Message.ID
+
+	switch action := strings.TrimPrefix(query.Data, feedCallbackPrefix); action {
+	case feedActionRotate:
+		url, err := b.feeds.RotateFeedURL(chatID)
+		if err != nil {
+			log.Error().
+				Int64("chatID", chatID).
+				Err(err).
+				Msg("Failed to rotate feed URL")
+			b.answerCallback(ctx, query.ID, "Failed to create a new link. Try later.", true)
+			return
+		}
+
+		b.answerCallback(ctx, query.ID, "The old link no longer works.", false)
+		b.editMessage(ctx, chatID, messageID, feedText(url), feedKeyboard())
+	case feedActionDisable:
+		if err := b.feeds.DisableFeed(chatID); err != nil {
+			log.Error().
+				Int64("chatID", chatID).
+				Err(err).
+				Msg("Failed to turn feed off")
+			b.answerCallback(ctx, query.ID, "Failed to turn the feed off. Try later.", true)
+			return
+		}
+
+		b.answerCallback(ctx, query.ID, "Feed turned off.", false)
+		b.editMessage(ctx, chatID, messageID, "Your feed is off, its link no longer works. Send "+feedCommand+" to get a new one.", nil)
+	default:
+		b.answerCallback(ctx, query.ID, "Unknown action.", false)
+	}
+}
@@ -6,6 +6,7 @@ toolchain go1.23.6
 
 replace (
 	github.com/ivgag/schedulr/ai => ../ai
+	github.com/ivgag/schedulr/ical => ../ical
 	github.com/ivgag/schedulr/model => ../model
 	github.com/ivgag/schedulr/service => ../service
 	github.com/ivgag/schedulr/storage => ../storage
@@ -35,10 +36,12 @@ require (
 	github.com/googleapis/enterprise-certificate-proxy v0.3.4 // indirect
 	github.com/googleapis/gax-go/v2 v2.14.1 // indirect
 	github.com/ivgag/schedulr/ai v0.0.0 // indirect
+	github.com/ivgag/schedulr/ical v0.0.0 // indirect
 	github.com/joho/godotenv v1.5.1 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.19 // indirect
 	github.com/sashabaranov/go-openai v1.37.0 // indirect
+	github.com/zsefvlol/timezonemapper v1.0.0 // indirect
 	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
 	go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.58.0 // indirect
 	go.opentelemetry.io/otel v1.34.0 // indirect
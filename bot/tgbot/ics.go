@@ -0,0 +1,162 @@
+package tgbot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/ivgag/schedulr/model"
+	"github.com/ivgag/schedulr/service"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxFileNameLength keeps the name of a file readable in the chat.
+	maxFileNameLength = 60
+	// maxCalendarFileSize is the largest iCalendar file imported.
+	maxCalendarFileSize = 1 << 20
+)
+
+// isCalendarFile tells whether the document is an iCalendar file,
+// such as an invite forwarded from a mail app.
+func isCalendarFile(document *models.Document) bool {
+	return document != nil && (strings.EqualFold(document.MimeType, "text/calendar") ||
+		strings.EqualFold(path.Ext(document.FileName), ".ics"))
+}
+
+// importCalendarFile turns the events of an iCalendar file the user has sent into drafts.
+// Files skip the AI, their events are taken as they are.
+func (b *Bot) importCalendarFile(ctx context.Context, update *models.Update) {
+	chatID := update.Message.Chat.ID
+	document := update.Message.Document
+
+	if document.FileSize > maxCalendarFileSize {
+		b.sendMessage(ctx, chatID, "The calendar file is too large to import.", "")
+		return
+	}
+
+	data, err := b.downloadFile(ctx, document.FileID)
+	if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Str("fileID", document.FileID).
+			Err(err).
+			Msg("Failed to download calendar file")
+		b.sendMessage(ctx, chatID, "Failed to download the calendar file. Try later.", "")
+		return
+	}
+
+	drafts, err := b.eventService.CreateDraftsFromICS(chatID, update.Message.ID, data)
+	if errors.Is(err, service.ErrInvalidCalendarFile) {
+		log.Warn().
+			Int64("chatID", chatID).
+			Err(err).
+			Msg("Failed to read calendar file")
+		b.sendMessage(ctx, chatID, "Schedulr could not read this calendar file:\n"+err.Error(), "")
+		return
+	} else if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Err(err).
+			Msg("Failed to import calendar file")
+		b.sendMessage(ctx, chatID, "Failed to import the calendar file. Try later.", "")
+		return
+	} else if len(drafts) == 0 {
+		b.sendMessage(ctx, chatID, "No events found in the calendar file.", "")
+		return
+	}
+
+	for _, draft := range drafts {
+		b.sendDraft(ctx, draft)
+	}
+}
+
+// downloadFile fetches a file the user has sent, up to maxCalendarFileSize bytes.
+func (b *Bot) downloadFile(ctx context.Context, fileID string) ([]byte, error) {
+	file, err := b.chatBot.GetFile(ctx, &bot.GetFileParams{FileID: fileID})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.chatBot.FileDownloadLink(file), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCalendarFileSize+1))
+	if err != nil {
+		return nil, err
+	} else if len(data) > maxCalendarFileSize {
+		return nil, errors.New("file is too large")
+	}
+	return data, nil
+}
+
+// sendEventFiles sends the events written as iCalendar files in reply to their card.
+// Phones open them in their calendar app.
+func (b *Bot) sendEventFiles(ctx context.Context, chatID int64, cardID int, results []service.ProviderResult) {
+	for _, result := range results {
+		if result.Err != nil || result.Provider != model.ProviderICS {
+			continue
+		}
+
+		data, err := service.ICSDocument(result.Event)
+		if err != nil {
+			log.Error().
+				Int64("chatID", chatID).
+				Str("eventID", result.Event.ID).
+				Err(err).
+				Msg("Failed to encode event file")
+			b.sendMessage(ctx, chatID, "Failed to create the calendar file. Try later.", "")
+			continue
+		}
+
+		_, err = b.chatBot.SendDocument(ctx, &bot.SendDocumentParams{
+			ChatID: chatID,
+			Document: &models.InputFileUpload{
+				Filename: eventFileName(result.Event.Event.Title),
+				Data:     bytes.NewReader(data),
+			},
+			Caption:         "Open the file to add the event to your calendar.",
+			ReplyParameters: &models.ReplyParameters{MessageID: cardID, AllowSendingWithoutReply: true},
+		})
+		if err != nil {
+			log.Error().
+				Int64("chatID", chatID).
+				Str("eventID", result.Event.ID).
+				Err(err).
+				Msg("Failed to send event file")
+		}
+	}
+}
+
+// eventFileName turns the title into a file name that is safe on every platform.
+func eventFileName(title string) string {
+	name := strings.Join(strings.FieldsFunc(title, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), "-")
+
+	if runes := []rune(name); len(runes) > maxFileNameLength {
+		name = strings.TrimRight(string(runes[:maxFileNameLength]), "-")
+	}
+	if name == "" {
+		name = "event"
+	}
+	return name + ".ics"
+}
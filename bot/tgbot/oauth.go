@@ -0,0 +1,87 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/ivgag/schedulr/storage"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	linkNotificationInterval = 3 * time.Second
+	linkNotificationBatch    = 50
+	staleOAuthStateInterval  = time.Hour
+)
+
+// notifyLinkOutcomes tells users how their account linking went. The outcomes
+// are read from the database, so that they are delivered even if the callback
+// was handled by another instance or before a restart.
+func (b *Bot) notifyLinkOutcomes(ctx context.Context) {
+	ticker := time.NewTicker(linkNotificationInterval)
+	defer ticker.Stop()
+
+	lastCleanup := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		states, err := b.oauthStates.ClaimNotifications(linkNotificationBatch)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to load account linking outcomes")
+			continue
+		}
+
+		for _, state := range states {
+			if err := b.notifyLinkOutcome(ctx, state); err != nil {
+				b.releaseLinkOutcome(state, err)
+			}
+		}
+
+		if time.Since(lastCleanup) > staleOAuthStateInterval {
+			if err := b.oauthStates.DeleteStale(); err != nil {
+				log.Error().Err(err).Msg("Failed to delete stale authorization requests")
+			}
+			lastCleanup = time.Now()
+		}
+	}
+}
+
+func (b *Bot) notifyLinkOutcome(ctx context.Context, state storage.OAuthState) error {
+	user, err := b.userService.GetUserByID(state.UserID)
+	if err != nil {
+		return err
+	}
+
+	text := "Account linked successfully :)"
+	if state.Status != storage.OAuthStateCompleted {
+		text = "Failed to link the account: " + state.Error
+	}
+	_, err = b.chatBot.SendMessage(ctx, &bot.SendMessageParams{ChatID: user.TelegramID, Text: text})
+	return err
+}
+
+// releaseLinkOutcome puts back an outcome that could not be delivered, so that it is
+// retried on the next tick. Users who blocked the bot are not retried.
+func (b *Bot) releaseLinkOutcome(state storage.OAuthState, err error) {
+	log.Error().
+		Int("userID", state.UserID).
+		Err(err).
+		Msg("Failed to notify the user about account linking")
+
+	if errors.Is(err, bot.ErrorForbidden) {
+		return
+	}
+	if err := b.oauthStates.ReleaseNotification(state.State); err != nil {
+		log.Error().
+			Int("userID", state.UserID).
+			Err(err).
+			Msg("Failed to release the account linking outcome")
+	}
+}
@@ -0,0 +1,82 @@
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/ivgag/schedulr/service"
+	"github.com/rs/zerolog/log"
+)
+
+const timezoneCommand = "/timezone"
+
+// timezoneHandler sets the user's time zone from "/timezone Europe/Berlin",
+// or shows the current one and offers to share a location to infer it.
+func (b *Bot) timezoneHandler(ctx context.Context, botAPI *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	name := strings.TrimSpace(strings.TrimPrefix(update.Message.Text, timezoneCommand))
+	if name != "" {
+		location, err := b.userService.SetTimeZone(chatID, name)
+		b.replyTimeZoneSet(ctx, chatID, location, err)
+		return
+	}
+
+	user, err := b.userService.GetUserByTelegramID(chatID)
+	if err != nil {
+		b.sendMessage(ctx, chatID, err.Error(), "")
+		return
+	}
+
+	current := "Your time zone is not set, the server's one is used."
+	if user.TimeZone != "" {
+		current = "Your time zone is " + user.TimeZone + "."
+	}
+
+	b.chatBot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text: current + "\n" +
+			"Send /timezone followed by its name, e.g. /timezone Europe/Berlin, or share your location.",
+		ReplyMarkup: &models.ReplyKeyboardMarkup{
+			Keyboard: [][]models.KeyboardButton{
+				{{Text: "Share location", RequestLocation: true}},
+			},
+			ResizeKeyboard:  true,
+			OneTimeKeyboard: true,
+		},
+	})
+}
+
+// locationHandler infers the user's time zone from a shared location.
+func (b *Bot) locationHandler(ctx context.Context, update *models.Update) {
+	chatID := update.Message.Chat.ID
+	location := update.Message.Location
+
+	tz, err := b.userService.SetTimeZoneFromLocation(chatID, location.Latitude, location.Longitude)
+	b.replyTimeZoneSet(ctx, chatID, tz, err)
+}
+
+func (b *Bot) replyTimeZoneSet(ctx context.Context, chatID int64, location *time.Location, err error) {
+	var text string
+	if errors.Is(err, service.ErrUnknownTimeZone) {
+		text = "Unknown time zone. Use a name like Europe/Berlin or America/New_York."
+	} else if err != nil {
+		log.Error().
+			Int64("chatID", chatID).
+			Err(err).
+			Msg("Failed to set time zone")
+		text = "Failed to set the time zone. Try later."
+	} else {
+		text = "Time zone set to " + location.String() + "."
+	}
+
+	b.chatBot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      chatID,
+		Text:        text,
+		ReplyMarkup: &models.ReplyKeyboardRemove{RemoveKeyboard: true},
+	})
+}
@@ -0,0 +1,125 @@
+package tgbot
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+	// maxWebhookBodySize is far above the size of any update Telegram sends.
+	maxWebhookBodySize = 1 << 20
+	// webhookRequestTimeout bounds the setWebhook and deleteWebhook calls.
+	webhookRequestTimeout = 10 * time.Second
+)
+
+// WebhookConfig makes Telegram push updates to the REST server instead of the bot
+// polling for them, so that several replicas can run side by side.
+type WebhookConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// URL is the public address of the REST server, e.g. "https://schedulr.example".
+	URL string `mapstructure:"url"`
+	// SecretToken is sent by Telegram with every update, 1-256 characters of A-Z, a-z, 0-9, _ and -.
+	SecretToken string `mapstructure:"secret_token"`
+	// DeleteOnStop removes the webhook on shutdown. It is off by default, as the
+	// other replicas keep receiving updates through it; switching back to polling
+	// removes the webhook on start anyway.
+	DeleteOnStop bool `mapstructure:"delete_on_stop"`
+}
+
+// WebhookEnabled tells whether updates arrive through WebhookHandler.
+func (b *Bot) WebhookEnabled() bool {
+	return b.cfg.Webhook.Enabled
+}
+
+// WebhookPath is the path of the REST server Telegram sends updates to. It is derived
+// from the bot token, so it stays secret and is the same on every replica.
+func (b *Bot) WebhookPath() string {
+	sum := sha256.Sum256([]byte("webhook:" + b.cfg.Token))
+	return "/telegram/" + hex.EncodeToString(sum[:16])
+}
+
+// WebhookHandler dispatches the updates Telegram sends to the handlers of the bot.
+// Updates without the secret token are rejected.
+func (b *Bot) WebhookHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		secret := []byte(r.Header.Get(webhookSecretHeader))
+		if subtle.ConstantTimeCompare(secret, []byte(b.cfg.Webhook.SecretToken)) != 1 {
+			log.Warn().
+				Str("remoteAddr", r.RemoteAddr).
+				Msg("Rejected webhook request with an invalid secret token")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		select {
+		case <-b.ready:
+		default:
+			// Telegram retries the update once the bot has started.
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		update := &models.Update{}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBodySize)).Decode(update); err != nil {
+			log.Error().Err(err).Msg("Failed to decode webhook update")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// The handlers outlive the request, so they run with the context of the bot.
+		b.chatBot.ProcessUpdate(b.ctx, update)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// validateWebhookConfig checks the settings Telegram would reject only on setWebhook.
+func validateWebhookConfig(cfg WebhookConfig) error {
+	if !strings.HasPrefix(cfg.URL, "https://") {
+		return errors.New("webhook URL must be an HTTPS URL")
+	}
+
+	token := cfg.SecretToken
+	if len(token) == 0 || len(token) > 256 {
+		return errors.New("webhook secret token must have 1-256 characters")
+	}
+	for _, c := range token {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return errors.New("webhook secret token may only contain A-Z, a-z, 0-9, _ and -")
+		}
+	}
+	return nil
+}
+
+// setWebhook points Telegram at WebhookPath of this server.
+func (b *Bot) setWebhook() error {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookRequestTimeout)
+	defer cancel()
+
+	_, err := b.chatBot.SetWebhook(ctx, &bot.SetWebhookParams{
+		URL:         strings.TrimRight(b.cfg.Webhook.URL, "/") + b.WebhookPath(),
+		SecretToken: b.cfg.Webhook.SecretToken,
+	})
+	return err
+}
+
+// deleteWebhook stops Telegram from sending updates, leaving the pending ones for the next start.
+func (b *Bot) deleteWebhook() {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookRequestTimeout)
+	defer cancel()
+
+	if _, err := b.chatBot.DeleteWebhook(ctx, &bot.DeleteWebhookParams{}); err != nil {
+		log.Error().Err(err).Msg("Failed to delete the webhook")
+	}
+}
@@ -0,0 +1 @@
+drop table event_drafts;
@@ -0,0 +1,13 @@
+create table event_drafts (
+    id serial primary key,
+    user_id int not null references users(id),
+    chat_id bigint not null,
+    message_id int,
+    provider varchar(50) not null,
+    event jsonb not null,
+    status varchar(20) not null default 'pending',
+    created_at timestamp not null default (timezone('utc', now())),
+    updated_at timestamp not null default (timezone('utc', now()))
+);
+
+create index event_drafts_chat_id_message_id_idx on event_drafts(chat_id, message_id);
@@ -0,0 +1,13 @@
+create table event_messages (
+    id serial primary key,
+    user_id int not null references users(id),
+    chat_id bigint not null,
+    message_id int not null,
+    provider varchar(50) not null,
+    provider_event_id text not null,
+    calendar_id text not null,
+    event jsonb not null,
+    created_at timestamp not null default (timezone('utc', now())),
+    updated_at timestamp not null default (timezone('utc', now())),
+    unique (chat_id, message_id)
+);
@@ -0,0 +1,3 @@
+alter table event_drafts drop column source_message_ids;
+
+drop table scheduled_events;
@@ -0,0 +1,28 @@
+create table scheduled_events (
+    id serial primary key,
+    user_id int not null references users(id),
+    source_chat_id bigint not null,
+    card_message_id int,
+    source_message_ids jsonb not null default '[]',
+    provider varchar(50) not null,
+    provider_event_id text not null,
+    calendar_id text not null,
+    link text not null default '',
+    event jsonb not null,
+    created_at timestamp not null default (timezone('utc', now())),
+    updated_at timestamp not null default (timezone('utc', now())),
+    constraint scheduled_events_provider_event_key unique (user_id, provider, calendar_id, provider_event_id)
+);
+
+create index scheduled_events_source_chat_id_card_message_id_idx on scheduled_events(source_chat_id, card_message_id);
+
+-- event_messages is kept, so that migrating down does not lose the events created before.
+insert into scheduled_events(
+    user_id, source_chat_id, card_message_id, provider, provider_event_id, calendar_id, event, created_at, updated_at
+)
+select user_id, chat_id, message_id, provider, provider_event_id, calendar_id, event, created_at, updated_at
+from event_messages
+order by id
+on conflict do nothing;
+
+alter table event_drafts add column source_message_ids jsonb not null default '[]';
@@ -0,0 +1,5 @@
+drop index scheduled_events_batch_id_idx;
+drop index event_drafts_user_id_batch_id_idx;
+
+alter table scheduled_events drop column batch_id;
+alter table event_drafts drop column batch_id;
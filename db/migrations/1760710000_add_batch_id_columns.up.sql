@@ -0,0 +1,5 @@
+alter table event_drafts add column batch_id varchar(36) not null default '';
+alter table scheduled_events add column batch_id varchar(36) not null default '';
+
+create index event_drafts_user_id_batch_id_idx on event_drafts(user_id, batch_id);
+create index scheduled_events_batch_id_idx on scheduled_events(batch_id);
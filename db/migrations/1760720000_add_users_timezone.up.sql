@@ -0,0 +1 @@
+alter table users add column timezone varchar(64) not null default '';
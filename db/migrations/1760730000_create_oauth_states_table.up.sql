@@ -0,0 +1,15 @@
+create table oauth_states (
+    state varchar(64) primary key,
+    user_id int not null,
+    provider varchar(50) not null,
+    code_verifier varchar(128) not null default '',
+    status varchar(20) not null default 'pending',
+    error text not null default '',
+    expires_at timestamp not null,
+    completed_at timestamp,
+    notified_at timestamp,
+    created_at timestamp not null default (timezone('utc', now())),
+    foreign key (user_id) references users(id) on delete cascade
+);
+
+create index oauth_states_unnotified_idx on oauth_states(status) where notified_at is null;
@@ -0,0 +1,2 @@
+alter table linked_accounts alter column access_token type varchar(255);
+alter table linked_accounts alter column refresh_token type varchar(255);
@@ -0,0 +1,2 @@
+alter table linked_accounts alter column access_token type text;
+alter table linked_accounts alter column refresh_token type text;
@@ -0,0 +1,3 @@
+alter table linked_accounts drop column status_changed_at;
+alter table linked_accounts drop column status_reason;
+alter table linked_accounts drop column status;
@@ -0,0 +1,3 @@
+alter table linked_accounts add column status varchar(20) not null default 'active';
+alter table linked_accounts add column status_reason text not null default '';
+alter table linked_accounts add column status_changed_at timestamp;
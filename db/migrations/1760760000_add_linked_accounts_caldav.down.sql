@@ -0,0 +1,2 @@
+alter table linked_accounts drop column username;
+alter table linked_accounts drop column server_url;
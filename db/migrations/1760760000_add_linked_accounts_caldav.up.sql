@@ -0,0 +1,2 @@
+alter table linked_accounts add column server_url text not null default '';
+alter table linked_accounts add column username text not null default '';
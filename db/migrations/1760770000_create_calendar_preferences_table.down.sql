@@ -0,0 +1,4 @@
+alter table event_drafts drop column calendar_name;
+alter table event_drafts drop column calendar_id;
+
+drop table calendar_preferences;
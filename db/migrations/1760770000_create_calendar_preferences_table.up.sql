@@ -0,0 +1,14 @@
+create table calendar_preferences (
+    id serial primary key,
+    user_id int not null references users(id),
+    provider varchar(50) not null,
+    event_type varchar(50) not null default '',
+    calendar_id text not null,
+    calendar_name text not null default '',
+    created_at timestamp not null default (timezone('utc', now())),
+    updated_at timestamp not null default (timezone('utc', now())),
+    unique (user_id, provider, event_type)
+);
+
+alter table event_drafts add column calendar_id text not null default '';
+alter table event_drafts add column calendar_name text not null default '';
@@ -0,0 +1,2 @@
+alter table users drop column all_providers;
+alter table users drop column default_provider;
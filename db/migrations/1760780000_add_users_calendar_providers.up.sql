@@ -0,0 +1,2 @@
+alter table users add column default_provider varchar(50) not null default '';
+alter table users add column all_providers boolean not null default false;
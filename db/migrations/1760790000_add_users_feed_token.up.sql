@@ -0,0 +1 @@
+alter table users add column feed_token varchar(64) unique;
@@ -0,0 +1,2 @@
+drop table buffered_messages;
+drop table message_buffers;
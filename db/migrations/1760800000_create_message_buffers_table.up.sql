@@ -0,0 +1,16 @@
+create table message_buffers (
+    chat_id bigint primary key,
+    due_at timestamp not null,
+    claimed_until timestamp
+);
+
+create index message_buffers_due_idx on message_buffers(due_at);
+
+create table buffered_messages (
+    id serial primary key,
+    chat_id bigint not null references message_buffers(chat_id),
+    message jsonb not null,
+    created_at timestamp not null default (timezone('utc', now()))
+);
+
+create index buffered_messages_chat_id_idx on buffered_messages(chat_id);
@@ -0,0 +1 @@
+drop table caldav_links;
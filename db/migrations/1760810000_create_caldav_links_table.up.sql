@@ -0,0 +1,7 @@
+create table caldav_links (
+    chat_id bigint primary key,
+    step int not null,
+    server_url text not null default '',
+    username text not null default '',
+    expires_at timestamp not null
+);
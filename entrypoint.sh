@@ -5,4 +5,4 @@ echo "Migrating the database..."
 migrate -path=/migrations -database "$DATABASE_URL" up
 
 echo "Starting the application..."
-exec ./app
\ No newline at end of file
+exec ./app "$@"
\ No newline at end of file